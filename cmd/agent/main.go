@@ -82,13 +82,29 @@ func main() {
 		)
 	}
 
+	// CPU and memory offered to tasks, all of the node's unless configured
+	resources := agent.DetectHostResources()
+	if cfg.Agent.CPUCores > 0 {
+		resources.CPUCores = cfg.Agent.CPUCores
+	}
+	if cfg.Agent.MemoryMB > 0 {
+		resources.MemoryMB = cfg.Agent.MemoryMB
+	}
+	log.Info("Host resources",
+		zap.Int("cpu_cores", resources.CPUCores),
+		zap.Int64("memory_mb", resources.MemoryMB),
+	)
+
 	// Initialize gRPC client
 	client := agent.NewClient(
 		cfg.Agent.ID,
+		cfg.Agent.Address,
 		cfg.Scheduler.MasterAddress,
 		cfg.Scheduler.StandbyAddress,
 		log,
 	)
+	client.SetLabels(cfg.Agent.Labels)
+	client.SetResources(resources)
 
 	// Connect to scheduler
 	ctx, cancel := context.WithCancel(context.Background())
@@ -116,6 +132,12 @@ func main() {
 		cfg.Executor.WorkDir,
 		log,
 	)
+	executor.SetTimeoutGracePeriod(time.Duration(cfg.Executor.TimeoutGracePeriod) * time.Second)
+	if cfg.Executor.CgroupRoot != "" {
+		if err := executor.SetCgroupRoot(cfg.Executor.CgroupRoot); err != nil {
+			log.Warn("Task CPU and memory limits disabled", zap.Error(err))
+		}
+	}
 
 	// Start heartbeat
 	heartbeatInterval := time.Duration(cfg.Agent.HeartbeatInterval) * time.Second
@@ -137,7 +159,7 @@ func main() {
 			)
 
 			// Report to scheduler
-			if err := client.ReportTaskFinished(ctx, result.TaskID, result.Status, result.Error); err != nil {
+			if err := client.ReportTaskFinished(ctx, result); err != nil {
 				log.Error("Failed to report task finished",
 					zap.String("task_id", result.TaskID),
 					zap.Error(err),
@@ -12,6 +12,7 @@ import (
 	"github.com/chicogong/dgpu-scheduler/pkg/api"
 	"github.com/chicogong/dgpu-scheduler/pkg/config"
 	"github.com/chicogong/dgpu-scheduler/pkg/logger"
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
 	"github.com/chicogong/dgpu-scheduler/pkg/scheduler"
 	"go.uber.org/zap"
 )
@@ -80,6 +81,57 @@ func main() {
 	// Initialize scheduling engine
 	engine := scheduler.NewEngine(stateManager, log)
 
+	placement, err := scheduler.NewPlacementPolicy(cfg.Scheduler.PlacementPolicy)
+	if err != nil {
+		log.Fatal("Invalid placement policy", zap.Error(err))
+	}
+	engine.SetPlacementPolicy(placement)
+	engine.SetPreemption(scheduler.PreemptionConfig{
+		Enabled:     cfg.Preemption.Enabled,
+		GracePeriod: time.Duration(cfg.Preemption.GracePeriod) * time.Second,
+	})
+	engine.SetBackfill(scheduler.BackfillConfig{
+		Enabled:        cfg.Backfill.Enabled,
+		DefaultRuntime: time.Duration(cfg.Backfill.DefaultRuntime) * time.Second,
+	})
+	engine.SetFairShare(scheduler.FairShareConfig{
+		Enabled:  cfg.FairShare.Enabled,
+		HalfLife: time.Duration(cfg.FairShare.HalfLife) * time.Second,
+		Weights:  cfg.FairShare.Weights,
+	})
+	engine.SetBorrowing(scheduler.BorrowingConfig{
+		Enabled:     cfg.Quota.Borrowing,
+		Limit:       cfg.Quota.BorrowLimit,
+		ReclaimMode: cfg.Quota.ReclaimMode,
+		GracePeriod: time.Duration(cfg.Quota.ReclaimGracePeriod) * time.Second,
+	})
+	engine.SetAgentTimeout(time.Duration(cfg.Agent.HeartbeatTimeout) * time.Second)
+	if len(cfg.PriorityClasses) > 0 {
+		classes := make([]models.PriorityClass, 0, len(cfg.PriorityClasses))
+		for _, class := range cfg.PriorityClasses {
+			classes = append(classes, models.PriorityClass{
+				Name:        models.Priority(class.Name),
+				Value:       class.Value,
+				Pool:        models.QuotaPool(class.Pool),
+				Preempt:     class.Preempt,
+				Preemptible: class.Preemptible,
+			})
+		}
+		engine.SetPriorityClasses(classes)
+	}
+	engine.SetAging(scheduler.AgingConfig{
+		Enabled:  cfg.Aging.Enabled,
+		Interval: time.Duration(cfg.Aging.Interval) * time.Second,
+		Step:     cfg.Aging.Step,
+		MaxBoost: cfg.Aging.MaxBoost,
+	})
+	engine.SetGPUSharing(scheduler.GPUSharingConfig{
+		Models: cfg.GPUSharing.Models,
+	})
+	engine.SetAdmission(scheduler.AdmissionConfig{
+		Mode: cfg.Admission.Mode,
+	})
+
 	// Start scheduling loop
 	scheduleInterval := time.Duration(cfg.Scheduler.ScheduleInterval) * time.Second
 	engine.Start(scheduleInterval)
@@ -16,9 +16,12 @@ import (
 // Client is the gRPC client for agent-scheduler communication
 type Client struct {
 	agentID         string
+	address         string
 	masterAddr      string
 	standbyAddr     string
 	currentAddr     string
+	labels          map[string]string
+	resources       HostResources
 	conn            *grpc.ClientConn
 	client          proto.SchedulerServiceClient
 	logger          *logger.Logger
@@ -27,10 +30,12 @@ type Client struct {
 	stopCh          chan struct{}
 }
 
-// NewClient creates a new gRPC client
-func NewClient(agentID, masterAddr, standbyAddr string, log *logger.Logger) *Client {
+// NewClient creates a new gRPC client. address is where other agents can
+// reach this node, used as MASTER_ADDR for distributed tasks.
+func NewClient(agentID, address, masterAddr, standbyAddr string, log *logger.Logger) *Client {
 	return &Client{
 		agentID:     agentID,
+		address:     address,
 		masterAddr:  masterAddr,
 		standbyAddr: standbyAddr,
 		currentAddr: masterAddr, // Start with master
@@ -39,6 +44,17 @@ func NewClient(agentID, masterAddr, standbyAddr string, log *logger.Logger) *Cli
 	}
 }
 
+// SetLabels sets the node labels advertised when registering
+func (c *Client) SetLabels(labels map[string]string) {
+	c.labels = labels
+}
+
+// SetResources sets the CPU cores and memory offered to tasks when
+// registering
+func (c *Client) SetResources(resources HostResources) {
+	c.resources = resources
+}
+
 // Connect connects to the scheduler
 func (c *Client) Connect(ctx context.Context) error {
 	c.logger.Info("Connecting to scheduler", zap.String("address", c.currentAddr))
@@ -64,17 +80,27 @@ func (c *Client) Register(ctx context.Context, gpus []models.GPU) error {
 	protoGPUs := make([]*proto.GPU, len(gpus))
 	for i, gpu := range gpus {
 		protoGPUs[i] = &proto.GPU{
-			Id:          gpu.ID,
-			DeviceIndex: int32(gpu.DeviceIndex),
-			Model:       gpu.Model,
-			Memory:      gpu.Memory,
+			Id:                gpu.ID,
+			DeviceIndex:       int32(gpu.DeviceIndex),
+			Model:             gpu.Model,
+			Memory:            gpu.Memory,
+			ComputeCapability: gpu.ComputeCap,
+		}
+		if len(gpu.Links) > 0 {
+			protoGPUs[i].Links = make(map[int32]string, len(gpu.Links))
+			for peer, link := range gpu.Links {
+				protoGPUs[i].Links[int32(peer)] = link
+			}
 		}
 	}
 
 	req := &proto.RegisterRequest{
-		AgentId: c.agentID,
-		Address: c.currentAddr,
-		Gpus:    protoGPUs,
+		AgentId:  c.agentID,
+		Address:  c.address,
+		Gpus:     protoGPUs,
+		Labels:   c.labels,
+		CpuCores: int32(c.resources.CPUCores),
+		MemoryMb: c.resources.MemoryMB,
 	}
 
 	resp, err := c.client.RegisterAgent(ctx, req)
@@ -151,6 +177,9 @@ func (c *Client) sendHeartbeat(gpus []models.GPU) error {
 		GpuStatus: gpuStatuses,
 		Timestamp: time.Now().Unix(),
 	}
+	if c.executor != nil {
+		req.RunningTasks = c.executor.GetRunningTasks()
+	}
 
 	if err := c.heartbeatStream.Send(req); err != nil {
 		return fmt.Errorf("failed to send heartbeat: %w", err)
@@ -188,6 +217,22 @@ func (c *Client) heartbeatReceiver(ctx context.Context) {
 				// TODO: Implement failover to standby
 			}
 
+			// Stop tasks the scheduler no longer runs on this agent
+			for _, stop := range resp.StopTasks {
+				c.logger.Info("Stopping task",
+					zap.String("task_id", stop.TaskId),
+					zap.String("reason", stop.Reason),
+					zap.Bool("checkpoint", stop.Checkpoint),
+				)
+				grace := time.Duration(stop.GracePeriod) * time.Second
+				if err := c.executor.StopTask(stop.TaskId, grace, stop.Checkpoint); err != nil {
+					c.logger.Warn("Failed to stop task",
+						zap.String("task_id", stop.TaskId),
+						zap.Error(err),
+					)
+				}
+			}
+
 			// Handle new tasks
 			if len(resp.Tasks) > 0 {
 				c.logger.Info("Received tasks from scheduler",
@@ -196,14 +241,32 @@ func (c *Client) heartbeatReceiver(ctx context.Context) {
 
 				// Execute each task
 				for _, protoTask := range resp.Tasks {
+					// Running tasks are resent on every heartbeat
+					if c.executor.IsRunning(protoTask.Id) {
+						continue
+					}
+
 					// Convert proto task to models.Task
 					task := &models.Task{
-						ID:       protoTask.Id,
-						Priority: models.Priority(protoTask.Priority),
-						GPUCount: int(protoTask.GpuCount),
-						Command:  protoTask.Command,
-						Env:      protoTask.Env,
-						Status:   models.TaskStatusRunning,
+						ID:         protoTask.Id,
+						Priority:   models.Priority(protoTask.Priority),
+						GPUCount:   int(protoTask.GpuCount),
+						Command:    protoTask.Command,
+						Env:        protoTask.Env,
+						MaxRuntime: int(protoTask.MaxRuntime),
+						CPU:        protoTask.Cpu,
+						MemoryMB:   protoTask.MemoryMb,
+						Status:     models.TaskStatusRunning,
+					}
+					if protoTask.Deadline > 0 {
+						deadline := time.Unix(protoTask.Deadline, 0)
+						task.Deadline = &deadline
+					}
+					if protoTask.GpuSharePercent > 0 {
+						task.GPUShare = &models.GPUShare{
+							Percent:  int(protoTask.GpuSharePercent),
+							MemoryMB: protoTask.GpuShareMemoryMb,
+						}
 					}
 
 					// Extract GPU IDs
@@ -222,7 +285,14 @@ func (c *Client) heartbeatReceiver(ctx context.Context) {
 							zap.Error(err),
 						)
 						// Report failure to scheduler
-						if err := c.ReportTaskFinished(ctx, task.ID, "failed", err.Error()); err != nil {
+						result := TaskResult{
+							TaskID:   task.ID,
+							Status:   "failed",
+							Error:    err.Error(),
+							ExitCode: -1,
+							Failure:  string(models.FailureExit),
+						}
+						if err := c.ReportTaskFinished(ctx, result); err != nil {
 							c.logger.Error("Failed to report task failure",
 								zap.String("task_id", task.ID),
 								zap.Error(err),
@@ -236,12 +306,15 @@ func (c *Client) heartbeatReceiver(ctx context.Context) {
 }
 
 // ReportTaskFinished reports task completion to scheduler
-func (c *Client) ReportTaskFinished(ctx context.Context, taskID, status, errorMsg string) error {
+func (c *Client) ReportTaskFinished(ctx context.Context, result TaskResult) error {
 	req := &proto.TaskFinishedRequest{
-		TaskId:    taskID,
-		Status:    status,
-		Error:     errorMsg,
+		TaskId:    result.TaskID,
+		Status:    result.Status,
+		Error:     result.Error,
 		Timestamp: time.Now().Unix(),
+		AgentId:   c.agentID,
+		ExitCode:  int32(result.ExitCode),
+		Failure:   result.Failure,
 	}
 
 	resp, err := c.client.TaskFinished(ctx, req)
@@ -254,8 +327,8 @@ func (c *Client) ReportTaskFinished(ctx context.Context, taskID, status, errorMs
 	}
 
 	c.logger.Info("Task finished reported",
-		zap.String("task_id", taskID),
-		zap.String("status", status),
+		zap.String("task_id", result.TaskID),
+		zap.String("status", result.Status),
 	)
 
 	return nil
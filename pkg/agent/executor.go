@@ -1,12 +1,17 @@
 package agent
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/chicogong/dgpu-scheduler/pkg/logger"
 	"github.com/chicogong/dgpu-scheduler/pkg/models"
@@ -19,14 +24,66 @@ type TaskExecutor struct {
 	workDir      string
 	logger       *logger.Logger
 	runningTasks sync.Map // task_id -> *exec.Cmd
+	stopping     sync.Map // task_id -> *exec.Cmd being stopped
 	taskResults  chan TaskResult
+
+	// timeoutGrace is the time tasks get between SIGTERM and SIGKILL
+	// once they exceed their max runtime or deadline
+	timeoutGrace time.Duration
+
+	// cgroupRoot holds the cgroups limiting tasks' CPU and memory, empty
+	// if tasks run unlimited
+	cgroupRoot string
 }
 
 // TaskResult represents the result of a task execution
 type TaskResult struct {
-	TaskID string
-	Status string
-	Error  string
+	TaskID   string
+	Status   string
+	Error    string
+	ExitCode int    // -1 if the process didn't exit on its own
+	Failure  string // failure class of failed tasks
+}
+
+// gpuErrorMarkers are log messages showing a task failed because of its
+// GPUs rather than its own code
+var gpuErrorMarkers = []string{
+	"CUDA error",
+	"CUDA out of memory",
+	"cudaErrorECCUncorrectable",
+	"uncorrectable ECC error",
+	"NVRM: Xid",
+	"GPU is lost",
+	"NCCL WARN Cuda failure",
+}
+
+// gpuErrorTail is how much of the end of a task's log is searched for GPU
+// errors
+const gpuErrorTail = 64 * 1024
+
+// classifyFailure returns the failure class of a failed task from the end
+// of its log
+func classifyFailure(logFile string) string {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return string(models.FailureExit)
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > gpuErrorTail {
+		_, _ = f.Seek(-gpuErrorTail, io.SeekEnd)
+	}
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		return string(models.FailureExit)
+	}
+
+	for _, marker := range gpuErrorMarkers {
+		if bytes.Contains(tail, []byte(marker)) {
+			return string(models.FailureGPUError)
+		}
+	}
+	return string(models.FailureExit)
 }
 
 // NewTaskExecutor creates a new task executor
@@ -39,6 +96,26 @@ func NewTaskExecutor(method, workDir string, log *logger.Logger) *TaskExecutor {
 	}
 }
 
+// SetTimeoutGracePeriod sets the time tasks exceeding their time limit get
+// to exit after SIGTERM before they are killed
+func (e *TaskExecutor) SetTimeoutGracePeriod(grace time.Duration) {
+	e.timeoutGrace = grace
+}
+
+// timeLimit returns when a task started now has to finish, the earlier of
+// its max runtime and its deadline, and why. ok is false if the task has no
+// time limit.
+func timeLimit(task *models.Task, now time.Time) (limit time.Time, reason string, ok bool) {
+	if task.MaxRuntime > 0 {
+		max := time.Duration(task.MaxRuntime) * time.Second
+		limit, reason, ok = now.Add(max), fmt.Sprintf("exceeded max runtime of %s", max), true
+	}
+	if task.Deadline != nil && (!ok || task.Deadline.Before(limit)) {
+		limit, reason, ok = *task.Deadline, fmt.Sprintf("exceeded deadline %s", task.Deadline.Format(time.RFC3339)), true
+	}
+	return limit, reason, ok
+}
+
 // ExecuteTask executes a task
 func (e *TaskExecutor) ExecuteTask(ctx context.Context, task *models.Task, gpuIDs []string) error {
 	e.logger.Info("Executing task",
@@ -74,9 +151,41 @@ func (e *TaskExecutor) executeAsProcess(ctx context.Context, task *models.Task,
 	command := parts[0]
 	args := parts[1:]
 
-	// Create command
-	cmd := exec.CommandContext(ctx, command, args...)
+	// Tasks exceeding their time limit get SIGTERM, then SIGKILL after the
+	// grace period
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	limit, limitReason, hasLimit := timeLimit(task, time.Now())
+	if hasLimit {
+		runCtx, cancel = context.WithDeadline(ctx, limit)
+	}
+
+	// Create command. Tasks only time out if the limit reached them while
+	// they still ran, not when they exited right before it.
+	var timedOut atomic.Bool
+	cmd := exec.CommandContext(runCtx, command, args...)
 	cmd.Dir = e.workDir
+	if hasLimit {
+		cmd.Cancel = func() error {
+			if ctx.Err() != nil {
+				// The agent is shutting down
+				return cmd.Process.Kill()
+			}
+
+			var err error
+			if e.timeoutGrace > 0 {
+				err = cmd.Process.Signal(syscall.SIGTERM)
+			} else {
+				err = cmd.Process.Kill()
+			}
+			if err == nil {
+				timedOut.Store(true)
+			}
+			return err
+		}
+		if e.timeoutGrace > 0 {
+			cmd.WaitDelay = e.timeoutGrace
+		}
+	}
 
 	// Set environment variables
 	cmd.Env = os.Environ()
@@ -91,6 +200,7 @@ func (e *TaskExecutor) executeAsProcess(ctx context.Context, task *models.Task,
 		}
 	}
 	cmd.Env = append(cmd.Env, fmt.Sprintf("CUDA_VISIBLE_DEVICES=%s", strings.Join(deviceIndices, ",")))
+	cmd.Env = append(cmd.Env, sharingEnv(task)...)
 
 	// Add custom environment variables
 	for key, value := range task.Env {
@@ -101,6 +211,7 @@ func (e *TaskExecutor) executeAsProcess(ctx context.Context, task *models.Task,
 	logFile := fmt.Sprintf("%s/%s.log", e.workDir, task.ID)
 	logWriter, err := os.Create(logFile)
 	if err != nil {
+		cancel()
 		return fmt.Errorf("failed to create log file: %w", err)
 	}
 	defer logWriter.Close()
@@ -108,20 +219,34 @@ func (e *TaskExecutor) executeAsProcess(ctx context.Context, task *models.Task,
 	cmd.Stdout = logWriter
 	cmd.Stderr = logWriter
 
+	// Tasks asking for CPU or memory are held to it so they can't starve
+	// the other tasks on the node
+	cgroup, err := e.newTaskCgroup(cmd, task)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to limit task resources: %w", err)
+	}
+
 	// Store running task
 	e.runningTasks.Store(task.ID, cmd)
-	defer e.runningTasks.Delete(task.ID)
 
 	// Start task
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	cgroup.started()
+	if err != nil {
+		cancel()
+		cgroup.remove()
+		e.runningTasks.Delete(task.ID)
 		e.logger.Error("Failed to start task",
 			zap.String("task_id", task.ID),
 			zap.Error(err),
 		)
 		e.taskResults <- TaskResult{
-			TaskID: task.ID,
-			Status: "failed",
-			Error:  err.Error(),
+			TaskID:   task.ID,
+			Status:   "failed",
+			Error:    err.Error(),
+			ExitCode: -1,
+			Failure:  string(models.FailureExit),
 		}
 		return err
 	}
@@ -134,15 +259,30 @@ func (e *TaskExecutor) executeAsProcess(ctx context.Context, task *models.Task,
 	// Wait for task to complete in background
 	go func() {
 		err := cmd.Wait()
+		cancel()
+		cgroup.remove()
+		e.runningTasks.Delete(task.ID)
+		e.stopping.Delete(task.ID)
 
 		var status string
 		var errorMsg string
+		var failure string
 
-		if err != nil {
+		// A task exiting cleanly on SIGTERM still timed out
+		if timedOut.Load() {
+			status = "timeout"
+			errorMsg = limitReason
+			e.logger.Warn("Task timed out",
+				zap.String("task_id", task.ID),
+				zap.String("reason", limitReason),
+			)
+		} else if err != nil {
 			status = "failed"
 			errorMsg = err.Error()
+			failure = classifyFailure(logFile)
 			e.logger.Error("Task failed",
 				zap.String("task_id", task.ID),
+				zap.String("failure", failure),
 				zap.Error(err),
 			)
 		} else {
@@ -153,15 +293,33 @@ func (e *TaskExecutor) executeAsProcess(ctx context.Context, task *models.Task,
 		}
 
 		e.taskResults <- TaskResult{
-			TaskID: task.ID,
-			Status: status,
-			Error:  errorMsg,
+			TaskID:   task.ID,
+			Status:   status,
+			Error:    errorMsg,
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Failure:  failure,
 		}
 	}()
 
 	return nil
 }
 
+// sharingEnv limits a task running on a slice of a shared GPU through the
+// node's CUDA MPS daemon. The task sees its GPU as device 0.
+func sharingEnv(task *models.Task) []string {
+	if task.GPUShare == nil {
+		return nil
+	}
+
+	env := []string{
+		fmt.Sprintf("CUDA_MPS_ACTIVE_THREAD_PERCENTAGE=%d", task.GPUShare.Percent),
+	}
+	if task.GPUShare.MemoryMB > 0 {
+		env = append(env, fmt.Sprintf("CUDA_MPS_PINNED_DEVICE_MEM_LIMIT=0=%dM", task.GPUShare.MemoryMB))
+	}
+	return env
+}
+
 // executeAsDocker executes task in a Docker container
 func (e *TaskExecutor) executeAsDocker(ctx context.Context, task *models.Task, gpuIDs []string) error {
 	// Docker execution is similar to process but with docker run command
@@ -176,24 +334,63 @@ func (e *TaskExecutor) GetTaskResults() <-chan TaskResult {
 	return e.taskResults
 }
 
-// StopTask stops a running task
-func (e *TaskExecutor) StopTask(taskID string) error {
+// StopTask stops a running task. The process gets SIGTERM, or SIGUSR1 to
+// checkpoint and exit, and is killed if it is still running after the
+// grace period.
+func (e *TaskExecutor) StopTask(taskID string, grace time.Duration, checkpoint bool) error {
 	val, exists := e.runningTasks.Load(taskID)
 	if !exists {
 		return fmt.Errorf("task not found: %s", taskID)
 	}
 
 	cmd := val.(*exec.Cmd)
-	if cmd.Process != nil {
+	if cmd.Process == nil {
+		return nil
+	}
+
+	// Stop requests are repeated on every heartbeat until the task exits
+	if current, loaded := e.stopping.LoadOrStore(taskID, cmd); loaded && current == cmd {
+		return nil
+	}
+
+	if grace <= 0 {
 		if err := cmd.Process.Kill(); err != nil {
 			return fmt.Errorf("failed to kill task: %w", err)
 		}
 		e.logger.Info("Task stopped", zap.String("task_id", taskID))
+		return nil
 	}
 
+	sig := syscall.SIGTERM
+	if checkpoint {
+		sig = syscall.SIGUSR1
+	}
+	if err := cmd.Process.Signal(sig); err != nil {
+		return fmt.Errorf("failed to terminate task: %w", err)
+	}
+	e.logger.Info("Task terminating",
+		zap.String("task_id", taskID),
+		zap.String("signal", sig.String()),
+		zap.Duration("grace_period", grace),
+	)
+
+	time.AfterFunc(grace, func() {
+		// Only kill the same process if it is still running
+		if current, exists := e.runningTasks.Load(taskID); exists && current == val {
+			_ = cmd.Process.Kill()
+			e.logger.Warn("Task killed after grace period", zap.String("task_id", taskID))
+		}
+	})
+
 	return nil
 }
 
+// IsRunning reports whether a task is currently running on this agent
+func (e *TaskExecutor) IsRunning(taskID string) bool {
+	_, exists := e.runningTasks.Load(taskID)
+	return exists
+}
+
 // GetRunningTasks returns the list of running task IDs
 func (e *TaskExecutor) GetRunningTasks() []string {
 	tasks := make([]string, 0)
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/logger"
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestTimeLimit(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	soon := now.Add(time.Minute)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		task  *models.Task
+		want  time.Time
+		limit bool
+	}{
+		{"none", &models.Task{}, time.Time{}, false},
+		{"max runtime", &models.Task{MaxRuntime: 600}, now.Add(10 * time.Minute), true},
+		{"deadline", &models.Task{Deadline: &later}, later, true},
+		{"earlier deadline", &models.Task{MaxRuntime: 600, Deadline: &soon}, soon, true},
+		{"earlier max runtime", &models.Task{MaxRuntime: 600, Deadline: &later}, now.Add(10 * time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		got, _, ok := timeLimit(tt.task, now)
+		if ok != tt.limit || !got.Equal(tt.want) {
+			t.Errorf("%s: got %v (limit %v), want %v (limit %v)", tt.name, got, ok, tt.want, tt.limit)
+		}
+	}
+}
+
+func TestExecutorKillsTaskOverMaxRuntime(t *testing.T) {
+	log, _ := logger.New(logger.Config{
+		Level:  "error",
+		Format: "json",
+		Output: "stderr",
+	})
+	executor := NewTaskExecutor("process", t.TempDir(), log)
+	executor.SetTimeoutGracePeriod(time.Second)
+
+	task := &models.Task{ID: "hung", Command: "sleep 30", MaxRuntime: 1}
+	if err := executor.ExecuteTask(context.Background(), task, nil); err != nil {
+		t.Fatalf("Failed to execute task: %v", err)
+	}
+
+	select {
+	case result := <-executor.GetTaskResults():
+		if result.Status != "timeout" {
+			t.Errorf("Expected timeout status, got %s (%s)", result.Status, result.Error)
+		}
+		if executor.IsRunning("hung") {
+			t.Error("Expected the task to be gone")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Task was not killed")
+	}
+}
+
+func TestExecutorReportsTimeoutOnlyWhenKilled(t *testing.T) {
+	log, _ := logger.New(logger.Config{
+		Level:  "error",
+		Format: "json",
+		Output: "stderr",
+	})
+	// Without a grace period tasks over their limit are killed right away
+	executor := NewTaskExecutor("process", t.TempDir(), log)
+
+	tests := []struct {
+		task   *models.Task
+		status string
+	}{
+		{task: &models.Task{ID: "quick", Command: "true", MaxRuntime: 1}, status: "success"},
+		{task: &models.Task{ID: "hung", Command: "sleep 30", MaxRuntime: 1}, status: "timeout"},
+	}
+	for _, tt := range tests {
+		if err := executor.ExecuteTask(context.Background(), tt.task, nil); err != nil {
+			t.Fatalf("Failed to execute %s: %v", tt.task.ID, err)
+		}
+		select {
+		case result := <-executor.GetTaskResults():
+			if result.Status != tt.status {
+				t.Errorf("Expected %s to end as %s, got %s (%s)", tt.task.ID, tt.status, result.Status, result.Error)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Task %s did not end", tt.task.ID)
+		}
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	gpuLog := filepath.Join(dir, "gpu.log")
+	_ = os.WriteFile(gpuLog, []byte("step 10\nRuntimeError: CUDA error: an illegal memory access was encountered\n"), 0644)
+	if got := classifyFailure(gpuLog); got != string(models.FailureGPUError) {
+		t.Errorf("Expected a GPU error, got %s", got)
+	}
+
+	exitLog := filepath.Join(dir, "exit.log")
+	_ = os.WriteFile(exitLog, []byte("ValueError: bad config\n"), 0644)
+	if got := classifyFailure(exitLog); got != string(models.FailureExit) {
+		t.Errorf("Expected a plain exit failure, got %s", got)
+	}
+}
+
+func TestSharingEnv(t *testing.T) {
+	if env := sharingEnv(&models.Task{}); env != nil {
+		t.Errorf("Expected no MPS limits for exclusive tasks, got %v", env)
+	}
+
+	env := sharingEnv(&models.Task{GPUShare: &models.GPUShare{Percent: 25, MemoryMB: 4096}})
+	want := []string{
+		"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE=25",
+		"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT=0=4096M",
+	}
+	if len(env) != len(want) || env[0] != want[0] || env[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, env)
+	}
+}
+
+func TestCgroupLimits(t *testing.T) {
+	if limits := cgroupLimits(&models.Task{}); len(limits) != 0 {
+		t.Errorf("Expected no limits for tasks without requests, got %v", limits)
+	}
+
+	limits := cgroupLimits(&models.Task{CPU: 2.5, MemoryMB: 4096})
+	if limits["cpu.max"] != "250000 100000" {
+		t.Errorf("Expected 2.5 cores per period, got %q", limits["cpu.max"])
+	}
+	if limits["memory.max"] != "4294967296" {
+		t.Errorf("Expected 4GiB in bytes, got %q", limits["memory.max"])
+	}
+}
+
+func TestMissingControllers(t *testing.T) {
+	if missing := missingControllers("cpuset cpu io memory pids\n"); len(missing) != 0 {
+		t.Errorf("Expected no missing controllers, got %v", missing)
+	}
+	missing := missingControllers("cpuset io pids\n")
+	if len(missing) != 2 || missing[0] != "cpu" || missing[1] != "memory" {
+		t.Errorf("Expected cpu and memory missing, got %v", missing)
+	}
+}
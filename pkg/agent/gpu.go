@@ -47,18 +47,44 @@ func (d *GPUDetector) detectWithNVML() ([]models.GPU, error) {
 
 // detectWithNvidiaSMI detects GPUs using nvidia-smi command
 func (d *GPUDetector) detectWithNvidiaSMI() ([]models.GPU, error) {
-	// nvidia-smi --query-gpu=index,name,memory.total --format=csv,noheader,nounits
+	// nvidia-smi --query-gpu=index,name,memory.total,compute_cap --format=csv,noheader,nounits
 	cmd := exec.Command("nvidia-smi",
-		"--query-gpu=index,name,memory.total",
+		"--query-gpu=index,name,memory.total,compute_cap",
 		"--format=csv,noheader,nounits",
 	)
 
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to run nvidia-smi: %w", err)
+		// Older drivers don't know the compute_cap field
+		cmd = exec.Command("nvidia-smi",
+			"--query-gpu=index,name,memory.total",
+			"--format=csv,noheader,nounits",
+		)
+		if output, err = cmd.Output(); err != nil {
+			return nil, fmt.Errorf("failed to run nvidia-smi: %w", err)
+		}
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	gpus := d.parseGPUQuery(string(output))
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("no GPUs detected")
+	}
+
+	// Interconnect topology is optional, schedulers treat missing links
+	// as unknown
+	if links, err := d.detectTopology(); err == nil {
+		for i := range gpus {
+			gpus[i].Links = links[gpus[i].DeviceIndex]
+		}
+	}
+
+	return gpus, nil
+}
+
+// parseGPUQuery parses the output of `nvidia-smi --query-gpu` for
+// index,name,memory.total and optionally compute_cap
+func (d *GPUDetector) parseGPUQuery(output string) []models.GPU {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
 	gpus := make([]models.GPU, 0, len(lines))
 
 	for _, line := range lines {
@@ -88,15 +114,93 @@ func (d *GPUDetector) detectWithNvidiaSMI() ([]models.GPU, error) {
 			CurrentTask: nil,
 			UpdatedAt:   time.Now(),
 		}
+		if len(parts) > 3 {
+			if computeCap := strings.TrimSpace(parts[3]); computeCap != "[N/A]" {
+				gpu.ComputeCap = computeCap
+			}
+		}
 
 		gpus = append(gpus, gpu)
 	}
 
-	if len(gpus) == 0 {
-		return nil, fmt.Errorf("no GPUs detected")
+	return gpus
+}
+
+// detectTopology reads the GPU interconnect matrix from `nvidia-smi topo -m`
+func (d *GPUDetector) detectTopology() (map[int]map[int]string, error) {
+	cmd := exec.Command("nvidia-smi", "topo", "-m")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run nvidia-smi topo: %w", err)
 	}
 
-	return gpus, nil
+	return parseTopology(string(output)), nil
+}
+
+// parseTopology parses the `nvidia-smi topo -m` matrix into
+// device index -> peer device index -> link type (e.g. "NV2", "PIX", "SYS")
+func parseTopology(output string) map[int]map[int]string {
+	links := make(map[int]map[int]string)
+	var columns []string
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Header row lists GPU (and NIC) columns before the affinity columns
+		if columns == nil {
+			if !strings.HasPrefix(fields[0], "GPU") {
+				continue
+			}
+			for _, field := range fields {
+				if !strings.HasPrefix(field, "GPU") && !strings.HasPrefix(field, "NIC") {
+					break
+				}
+				columns = append(columns, field)
+			}
+			continue
+		}
+
+		// Matrix rows start with the device name, legend lines don't
+		index, ok := parseDeviceName(fields[0])
+		if !ok {
+			if !strings.HasPrefix(fields[0], "NIC") {
+				break
+			}
+			continue
+		}
+		if len(fields) < len(columns)+1 {
+			continue
+		}
+
+		for i, column := range columns {
+			peer, ok := parseDeviceName(column)
+			if !ok || peer == index {
+				continue
+			}
+			if links[index] == nil {
+				links[index] = make(map[int]string)
+			}
+			links[index][peer] = fields[i+1]
+		}
+	}
+
+	return links
+}
+
+// parseDeviceName parses a "GPU<n>" matrix label into its device index
+func parseDeviceName(name string) (int, bool) {
+	if !strings.HasPrefix(name, "GPU") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(name, "GPU"))
+	if err != nil {
+		return 0, false
+	}
+	return index, true
 }
 
 // GetGPUStatus gets current status of all GPUs
@@ -0,0 +1,60 @@
+package agent
+
+import "testing"
+
+func TestParseTopology(t *testing.T) {
+	output := "\tGPU0\tGPU1\tGPU2\tNIC0\tCPU Affinity\tNUMA Affinity\n" +
+		"GPU0\t X \tNV2\tSYS\tPIX\t0-19\t0\n" +
+		"GPU1\tNV2\t X \tPHB\tSYS\t0-19\t0\n" +
+		"GPU2\tSYS\tPHB\t X \tSYS\t20-39\t1\n" +
+		"NIC0\tPIX\tSYS\tSYS\t X \n" +
+		"\n" +
+		"Legend:\n" +
+		"  X    = Self\n" +
+		"  NV#  = Connection traversing a bonded set of # NVLinks\n"
+
+	links := parseTopology(output)
+
+	if len(links) != 3 {
+		t.Fatalf("Expected links for 3 GPUs, got %d", len(links))
+	}
+	if links[0][1] != "NV2" {
+		t.Errorf("Expected GPU0-GPU1 link NV2, got %q", links[0][1])
+	}
+	if links[1][2] != "PHB" {
+		t.Errorf("Expected GPU1-GPU2 link PHB, got %q", links[1][2])
+	}
+	if _, exists := links[0][0]; exists {
+		t.Error("Expected no self link for GPU0")
+	}
+	if len(links[2]) != 2 {
+		t.Errorf("Expected GPU2 to have 2 peer links, got %d", len(links[2]))
+	}
+}
+
+func TestParseGPUQuery(t *testing.T) {
+	d := NewGPUDetector("nvidia-smi", "node-1")
+	output := "0, NVIDIA A100-SXM4-80GB, 81920, 8.0\n" +
+		"1, Tesla V100-SXM2-32GB, 32768, [N/A]\n" +
+		"bad line\n"
+
+	gpus := d.parseGPUQuery(output)
+
+	if len(gpus) != 2 {
+		t.Fatalf("Expected 2 GPUs, got %d", len(gpus))
+	}
+	if gpus[0].ID != "node-1-gpu-0" || gpus[0].Model != "NVIDIA A100-SXM4-80GB" || gpus[0].Memory != 81920 {
+		t.Errorf("Unexpected first GPU: %+v", gpus[0])
+	}
+	if gpus[0].ComputeCap != "8.0" {
+		t.Errorf("Expected compute capability 8.0, got %q", gpus[0].ComputeCap)
+	}
+	if gpus[1].ComputeCap != "" {
+		t.Errorf("Expected unknown compute capability, got %q", gpus[1].ComputeCap)
+	}
+
+	// Older drivers report no compute capability column
+	if gpus := d.parseGPUQuery("0, Tesla T4, 15360"); len(gpus) != 1 || gpus[0].ComputeCap != "" {
+		t.Errorf("Expected a GPU without compute capability, got %+v", gpus)
+	}
+}
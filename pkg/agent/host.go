@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// HostResources is the CPU and memory a node offers to tasks
+type HostResources struct {
+	CPUCores int
+	MemoryMB int64 // 0 if unknown
+}
+
+// DetectHostResources detects the node's CPU cores and memory. The memory
+// is read from /proc/meminfo and left unknown where there is none.
+func DetectHostResources() HostResources {
+	host := HostResources{CPUCores: runtime.NumCPU()}
+
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return host
+	}
+	defer f.Close()
+
+	if memory, err := parseMemTotal(f); err == nil {
+		host.MemoryMB = memory
+	}
+	return host
+}
+
+// parseMemTotal returns the MemTotal of /proc/meminfo in MB
+func parseMemTotal(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// MemTotal:       65842412 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal: %w", err)
+		}
+		return kb / 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemTotal in meminfo")
+}
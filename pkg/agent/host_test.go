@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemTotal(t *testing.T) {
+	meminfo := "MemTotal:       65842412 kB\n" +
+		"MemFree:        12345678 kB\n" +
+		"MemAvailable:   40000000 kB\n"
+
+	memory, err := parseMemTotal(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatalf("Failed to parse meminfo: %v", err)
+	}
+	if memory != 64299 {
+		t.Errorf("Expected 64299MB, got %d", memory)
+	}
+
+	if _, err := parseMemTotal(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Error("Expected an error without MemTotal")
+	}
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// cgroupPeriod is the CPU period of task cgroups, in microseconds
+const cgroupPeriod = 100000
+
+// cgroupControllers are the controllers task cgroups are limited by
+var cgroupControllers = []string{"cpu", "memory"}
+
+// missingControllers returns the cgroup controllers tasks need that are
+// missing from a cgroup.controllers file
+func missingControllers(available string) []string {
+	fields := strings.Fields(available)
+	missing := make([]string, 0)
+	for _, controller := range cgroupControllers {
+		if !slices.Contains(fields, controller) {
+			missing = append(missing, controller)
+		}
+	}
+	return missing
+}
+
+// cgroupLimits returns the cgroup v2 files holding a task to the CPU and
+// memory it asked for, with their contents
+func cgroupLimits(task *models.Task) map[string]string {
+	limits := make(map[string]string)
+	if task.CPU > 0 {
+		quota := int64(math.Ceil(task.CPU * cgroupPeriod))
+		limits["cpu.max"] = fmt.Sprintf("%d %d", quota, cgroupPeriod)
+	}
+	if task.MemoryMB > 0 {
+		limits["memory.max"] = strconv.FormatInt(task.MemoryMB*1024*1024, 10)
+	}
+	return limits
+}
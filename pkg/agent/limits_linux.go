@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// SetCgroupRoot makes tasks asking for CPU or memory run in a cgroup of
+// their own under root, a cgroup v2 directory the agent may write to,
+// enabling the CPU and memory controllers for its children. The controllers
+// have to be delegated to root by its parent.
+func (e *TaskExecutor) SetCgroupRoot(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup %s, the agent needs write access to a delegated cgroup: %w", root, err)
+	}
+
+	available, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("%s is not a cgroup v2 directory: %w", root, err)
+	}
+	if missing := missingControllers(string(available)); len(missing) > 0 {
+		return fmt.Errorf("the %s controllers are not delegated to %s: enable them in its parent's "+
+			"cgroup.subtree_control, e.g. with Delegate=yes in the agent's systemd unit",
+			strings.Join(missing, " and "), root)
+	}
+
+	control := filepath.Join(root, "cgroup.subtree_control")
+	if err := os.WriteFile(control, []byte("+cpu +memory"), 0644); err != nil {
+		return fmt.Errorf("failed to enable cgroup controllers: %w", err)
+	}
+	e.cgroupRoot = root
+	return nil
+}
+
+// taskCgroup is the cgroup a task runs in
+type taskCgroup struct {
+	dir string
+	fd  *os.File
+}
+
+// newTaskCgroup creates a cgroup holding a task to the CPU and memory it
+// asked for and makes cmd start in it. It returns nil if the task isn't
+// limited.
+func (e *TaskExecutor) newTaskCgroup(cmd *exec.Cmd, task *models.Task) (*taskCgroup, error) {
+	limits := cgroupLimits(task)
+	if e.cgroupRoot == "" || len(limits) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Join(e.cgroupRoot, task.ID)
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	for file, value := range limits {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+			_ = os.Remove(dir)
+			return nil, fmt.Errorf("failed to set %s: %w", file, err)
+		}
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		_ = os.Remove(dir)
+		return nil, fmt.Errorf("failed to open cgroup: %w", err)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+	return &taskCgroup{dir: dir, fd: fd}, nil
+}
+
+// started releases what starting the task in the cgroup needed
+func (c *taskCgroup) started() {
+	if c != nil {
+		_ = c.fd.Close()
+	}
+}
+
+// remove kills what the task left running in the cgroup and removes it,
+// once more a second later if the killed processes were still exiting
+func (c *taskCgroup) remove() {
+	if c == nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(c.dir, "cgroup.kill"), []byte("1"), 0644)
+	if err := os.Remove(c.dir); err != nil && !os.IsNotExist(err) {
+		time.AfterFunc(time.Second, func() { _ = os.Remove(c.dir) })
+	}
+}
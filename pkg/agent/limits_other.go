@@ -0,0 +1,26 @@
+//go:build !linux
+
+package agent
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// SetCgroupRoot fails outside Linux, where tasks run unlimited
+func (e *TaskExecutor) SetCgroupRoot(root string) error {
+	return errors.New("task CPU and memory limits need Linux cgroups")
+}
+
+// taskCgroup stands in for the cgroups tasks run in on Linux
+type taskCgroup struct{}
+
+func (e *TaskExecutor) newTaskCgroup(cmd *exec.Cmd, task *models.Task) (*taskCgroup, error) {
+	return nil, nil
+}
+
+func (c *taskCgroup) started() {}
+
+func (c *taskCgroup) remove() {}
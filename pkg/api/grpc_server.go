@@ -15,6 +15,10 @@ import (
 	"google.golang.org/grpc"
 )
 
+// stopGracePeriod is how long agents wait between SIGTERM and SIGKILL when
+// stopping a task
+const stopGracePeriod = 10 * time.Second
+
 // GRPCServer implements the gRPC server for scheduler-agent communication
 type GRPCServer struct {
 	proto.UnimplementedSchedulerServiceServer
@@ -77,6 +81,8 @@ func (s *GRPCServer) RegisterAgent(ctx context.Context, req *proto.RegisterReque
 		zap.String("agent_id", req.AgentId),
 		zap.String("address", req.Address),
 		zap.Int("gpu_count", len(req.Gpus)),
+		zap.Int32("cpu_cores", req.CpuCores),
+		zap.Int64("memory_mb", req.MemoryMb),
 	)
 
 	// Convert proto GPUs to model GPUs
@@ -88,21 +94,32 @@ func (s *GRPCServer) RegisterAgent(ctx context.Context, req *proto.RegisterReque
 			DeviceIndex: int(protoGPU.DeviceIndex),
 			Model:       protoGPU.Model,
 			Memory:      protoGPU.Memory,
+			ComputeCap:  protoGPU.ComputeCapability,
 			Status:      models.GPUStatusIdle,
 			UpdatedAt:   time.Now(),
 		}
+		if len(protoGPU.Links) > 0 {
+			gpus[i].Links = make(map[int]string, len(protoGPU.Links))
+			for peer, link := range protoGPU.Links {
+				gpus[i].Links[int(peer)] = link
+			}
+		}
 	}
 
 	// Register agent
 	agent := &models.Agent{
 		ID:            req.AgentId,
 		Address:       req.Address,
+		Labels:        req.Labels,
+		CPUCores:      int(req.CpuCores),
+		MemoryMB:      req.MemoryMb,
 		GPUs:          gpus,
 		LastHeartbeat: time.Now(),
 		Status:        models.AgentStatusOnline,
 	}
 
 	s.state.RegisterAgent(agent)
+	s.engine.Notify(scheduler.EventAgentRegistered)
 
 	s.logger.Info("Agent registered successfully",
 		zap.String("agent_id", req.AgentId),
@@ -135,7 +152,7 @@ func (s *GRPCServer) Heartbeat(stream proto.SchedulerService_HeartbeatServer) er
 		agentID = req.AgentId
 
 		// Update agent heartbeat
-		if err := s.state.UpdateAgentHeartbeat(agentID); err != nil {
+		if err := s.state.UpdateAgentHeartbeat(agentID, req.RunningTasks); err != nil {
 			s.logger.Warn("Failed to update agent heartbeat",
 				zap.String("agent_id", agentID),
 				zap.Error(err),
@@ -143,6 +160,7 @@ func (s *GRPCServer) Heartbeat(stream proto.SchedulerService_HeartbeatServer) er
 		}
 
 		// Update GPU status
+		changed := false
 		for _, gpuStatus := range req.GpuStatus {
 			status := models.GPUStatusIdle
 			if gpuStatus.Status == "busy" {
@@ -151,45 +169,30 @@ func (s *GRPCServer) Heartbeat(stream proto.SchedulerService_HeartbeatServer) er
 				status = models.GPUStatusOffline
 			}
 
-			if err := s.state.UpdateGPUStatus(gpuStatus.Id, status); err != nil {
+			updated, err := s.state.ReportGPUStatus(gpuStatus.Id, status)
+			if err != nil {
 				s.logger.Debug("Failed to update GPU status",
 					zap.String("gpu_id", gpuStatus.Id),
 					zap.Error(err),
 				)
 			}
+			changed = changed || updated
+		}
+		if changed {
+			s.engine.Notify(scheduler.EventGPUStatusChanged)
 		}
 
-		// Get tasks assigned to this agent
+		// Get tasks assigned to this agent and tasks it should stop
 		state := s.state.GetState()
-		agentTasks := []*proto.Task{}
-
-		for _, task := range state.Tasks {
-			// Only send running tasks that are assigned to this agent
-			if task.Status == models.TaskStatusRunning && len(task.AssignedGPUs) > 0 {
-				// Check if any of the assigned GPUs belong to this agent
-				for _, gpuID := range task.AssignedGPUs {
-					if gpu, exists := state.GPUs[gpuID]; exists && gpu.NodeID == agentID {
-						// Convert to proto task
-						protoTask := &proto.Task{
-							Id:           task.ID,
-							Priority:     string(task.Priority),
-							GpuCount:     int32(task.GPUCount),
-							Command:      task.Command,
-							Env:          task.Env,
-							AssignedGpus: task.AssignedGPUs,
-						}
-						agentTasks = append(agentTasks, protoTask)
-						break // Only add the task once
-					}
-				}
-			}
-		}
+		agentTasks := s.agentTasks(state, agentID)
+		stopTasks := s.stopTasks(state, agentID, req.RunningTasks)
 
 		// Send response
 		resp := &proto.HeartbeatResponse{
 			IsMaster:  s.isMaster,
 			Tasks:     agentTasks,
 			Timestamp: time.Now().Unix(),
+			StopTasks: stopTasks,
 		}
 
 		if err := stream.Send(resp); err != nil {
@@ -202,6 +205,154 @@ func (s *GRPCServer) Heartbeat(stream proto.SchedulerService_HeartbeatServer) er
 	}
 }
 
+// agentTasks returns the running tasks (or gang members) placed on an agent
+func (s *GRPCServer) agentTasks(state *scheduler.State, agentID string) []*proto.Task {
+	agentTasks := []*proto.Task{}
+
+	for _, task := range state.Tasks {
+		// Only send running tasks that are assigned to this agent
+		if task.Status != models.TaskStatusRunning || len(task.AssignedGPUs) == 0 {
+			continue
+		}
+
+		// Distributed tasks send each agent only its own member
+		if task.IsDistributed() {
+			for i := range task.GangMembers {
+				member := &task.GangMembers[i]
+				if member.NodeID != agentID || member.Status != models.TaskStatusRunning {
+					continue
+				}
+				agentTasks = append(agentTasks, s.gangMemberTask(state, task, member))
+			}
+			continue
+		}
+
+		// Check if any of the assigned GPUs belong to this agent
+		for _, gpuID := range task.AssignedGPUs {
+			if gpu, exists := state.GPUs[gpuID]; exists && gpu.NodeID == agentID {
+				// Convert to proto task
+				protoTask := &proto.Task{
+					Id:           task.ID,
+					Priority:     string(task.Priority),
+					GpuCount:     int32(task.GPUCount),
+					Command:      task.Command,
+					Env:          task.Env,
+					AssignedGpus: task.AssignedGPUs,
+					MaxRuntime:   int64(task.MaxRuntime),
+					Deadline:     unixOrZero(task.Deadline),
+					Cpu:          task.CPU,
+					MemoryMb:     task.MemoryMB,
+				}
+				if tenant, shared := scheduler.TenantOf(gpu, task.ID); shared {
+					protoTask.GpuSharePercent = int32(tenant.Percent)
+					protoTask.GpuShareMemoryMb = tenant.MemoryMB
+				}
+				agentTasks = append(agentTasks, protoTask)
+				break // Only add the task once
+			}
+		}
+	}
+
+	return agentTasks
+}
+
+// gangMemberTask converts one member of a distributed task to a proto task
+// carrying the member's GPUs and rendezvous environment
+func (s *GRPCServer) gangMemberTask(state *scheduler.State, task *models.Task, member *models.GangMember) *proto.Task {
+	masterAddr := ""
+	if master, exists := state.Agents[task.GangMembers[0].NodeID]; exists {
+		masterAddr = master.Address
+	}
+
+	env := make(map[string]string, len(task.Env)+8)
+	for key, value := range task.Env {
+		env[key] = value
+	}
+	for key, value := range scheduler.RendezvousEnv(task, member, masterAddr) {
+		env[key] = value
+	}
+
+	return &proto.Task{
+		Id:           task.ID,
+		Priority:     string(task.Priority),
+		GpuCount:     int32(len(member.GPUs)),
+		Command:      task.Command,
+		Env:          env,
+		AssignedGpus: member.GPUs,
+		MaxRuntime:   int64(task.MaxRuntime),
+		Deadline:     unixOrZero(task.Deadline),
+		Cpu:          task.CPU,
+		MemoryMb:     task.MemoryMB,
+	}
+}
+
+// unixOrZero converts an optional time to unix seconds, 0 if unset
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+// stopTasks returns the tasks an agent should stop: those the scheduler
+// asked to stop (e.g. preemption victims) and those it no longer has
+// running on that agent (e.g. the surviving members of a failed gang)
+func (s *GRPCServer) stopTasks(state *scheduler.State, agentID string, running []string) []*proto.StopTask {
+	stopTasks := []*proto.StopTask{}
+
+	for _, taskID := range running {
+		task, exists := state.Tasks[taskID]
+		if exists && s.isRunningOn(state, task, agentID) {
+			// Tasks being preempted or reclaimed are stopped with their own grace
+			// period
+			if task.StopRequest != nil {
+				stopTasks = append(stopTasks, &proto.StopTask{
+					TaskId:      taskID,
+					Reason:      task.StopRequest.Reason,
+					GracePeriod: int32(task.StopRequest.GracePeriod),
+					Checkpoint:  task.StopRequest.Checkpoint,
+				})
+			}
+			continue
+		}
+
+		reason := "task not found"
+		if exists {
+			reason = fmt.Sprintf("task is %s", task.Status)
+		}
+		stopTasks = append(stopTasks, &proto.StopTask{
+			TaskId:      taskID,
+			Reason:      reason,
+			GracePeriod: int32(stopGracePeriod.Seconds()),
+		})
+	}
+
+	return stopTasks
+}
+
+// isRunningOn reports whether a task should currently be running on an agent
+func (s *GRPCServer) isRunningOn(state *scheduler.State, task *models.Task, agentID string) bool {
+	if task.Status != models.TaskStatusRunning {
+		return false
+	}
+
+	if task.IsDistributed() {
+		for _, member := range task.GangMembers {
+			if member.NodeID == agentID {
+				return member.Status == models.TaskStatusRunning
+			}
+		}
+		return false
+	}
+
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := state.GPUs[gpuID]; exists && gpu.NodeID == agentID {
+			return true
+		}
+	}
+	return false
+}
+
 // TaskFinished handles task completion notification
 func (s *GRPCServer) TaskFinished(ctx context.Context, req *proto.TaskFinishedRequest) (*proto.TaskFinishedResponse, error) {
 	s.logger.Info("Task finished",
@@ -216,6 +367,8 @@ func (s *GRPCServer) TaskFinished(ctx context.Context, req *proto.TaskFinishedRe
 		status = models.TaskStatusSuccess
 	case "failed":
 		status = models.TaskStatusFailed
+	case "timeout":
+		status = models.TaskStatusTimeout
 	default:
 		return &proto.TaskFinishedResponse{
 			Success: false,
@@ -223,14 +376,26 @@ func (s *GRPCServer) TaskFinished(ctx context.Context, req *proto.TaskFinishedRe
 		}, nil
 	}
 
-	// Error message
-	var errorMsg *string
+	exit := scheduler.TaskExit{Status: status}
 	if req.Error != "" {
-		errorMsg = &req.Error
+		exit.Error = &req.Error
+	}
+	if req.ExitCode >= 0 {
+		exitCode := int(req.ExitCode)
+		exit.ExitCode = &exitCode
+	}
+	if status == models.TaskStatusFailed && req.Failure == string(models.FailureGPUError) {
+		exit.Failure = models.FailureGPUError
 	}
 
-	// Release task resources
-	if err := s.engine.ReleaseTask(req.TaskId, status, errorMsg); err != nil {
+	// Release task resources, distributed tasks finish member by member
+	task, err := s.state.GetTask(req.TaskId)
+	if err == nil && task.IsDistributed() {
+		err = s.engine.ReleaseGangMember(req.TaskId, req.AgentId, exit)
+	} else {
+		err = s.engine.ReleaseTask(req.TaskId, exit)
+	}
+	if err != nil {
 		s.logger.Error("Failed to release task",
 			zap.String("task_id", req.TaskId),
 			zap.Error(err),
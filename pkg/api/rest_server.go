@@ -2,8 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chicogong/dgpu-scheduler/pkg/logger"
@@ -41,11 +46,43 @@ func (s *RESTServer) Start(address string) error {
 	mux.HandleFunc("/api/v1/tasks", s.handleTasks)
 	mux.HandleFunc("/api/v1/tasks/", s.handleTaskByID)
 
+	// Task array endpoints
+	mux.HandleFunc("/api/v1/arrays", s.handleArrays)
+	mux.HandleFunc("/api/v1/arrays/", s.handleArrayByID)
+
+	// Workflow endpoints
+	mux.HandleFunc("/api/v1/workflows", s.handleWorkflows)
+	mux.HandleFunc("/api/v1/workflows/", s.handleWorkflowByID)
+
+	// Scheduled task endpoints
+	mux.HandleFunc("/api/v1/schedules", s.handleSchedules)
+	mux.HandleFunc("/api/v1/schedules/", s.handleScheduleByID)
+
 	// GPU endpoints
 	mux.HandleFunc("/api/v1/gpus", s.handleGPUs)
 
+	// Advance reservation endpoints
+	mux.HandleFunc("/api/v1/reservations", s.handleReservations)
+	mux.HandleFunc("/api/v1/reservations/", s.handleReservationByID)
+
+	// Agent maintenance endpoints
+	mux.HandleFunc("/api/v1/agents", s.handleAgents)
+	mux.HandleFunc("/api/v1/agents/", s.handleAgentByID)
+
 	// Quota endpoints
 	mux.HandleFunc("/api/v1/quota", s.handleQuota)
+	mux.HandleFunc("/api/v1/quotas", s.handleQuotas)
+	mux.HandleFunc("/api/v1/quotas/", s.handleTeamQuota)
+	mux.HandleFunc("/api/v1/org-quotas/", s.handleOrgQuota)
+
+	// Fair-share endpoints
+	mux.HandleFunc("/api/v1/teams", s.handleTeams)
+
+	// Priority class endpoints
+	mux.HandleFunc("/api/v1/priority-classes", s.handlePriorityClasses)
+
+	// Scheduling loop statistics
+	mux.HandleFunc("/api/v1/scheduler/stats", s.handleSchedulerStats)
 
 	// Health check
 	mux.HandleFunc("/health", s.handleHealth)
@@ -86,73 +123,378 @@ func (s *RESTServer) handleTasks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// createTask creates a new task
-func (s *RESTServer) createTask(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Priority string            `json:"priority"`
-		GPUCount int               `json:"gpu_count"`
-		GPUModel *string           `json:"gpu_model,omitempty"`
-		Command  string            `json:"command"`
-		Env      map[string]string `json:"env,omitempty"`
+// taskRequest is the JSON body describing a task to create
+type taskRequest struct {
+	Type            string              `json:"type,omitempty"`
+	Team            string              `json:"team,omitempty"`
+	Project         string              `json:"project,omitempty"`
+	User            string              `json:"user,omitempty"`
+	Priority        string              `json:"priority"`
+	GPUCount        int                 `json:"gpu_count"`
+	Nodes           int                 `json:"nodes,omitempty"`
+	GPUsPerNode     int                 `json:"gpus_per_node,omitempty"`
+	MasterPort      int                 `json:"master_port,omitempty"`
+	GPUModel        *string             `json:"gpu_model,omitempty"`
+	GPUModels       []string            `json:"gpu_models,omitempty"`
+	MinMemoryMB     int64               `json:"min_memory_mb,omitempty"`
+	MinComputeCap   string              `json:"min_compute_capability,omitempty"`
+	GPUShare        *models.GPUShare    `json:"gpu_share,omitempty"`
+	CPU             float64             `json:"cpu,omitempty"`
+	MemoryMB        int64               `json:"memory_mb,omitempty"`
+	Labels          map[string]string   `json:"labels,omitempty"`
+	NodeSelector    map[string]string   `json:"node_selector,omitempty"`
+	Affinity        *models.Affinity    `json:"affinity,omitempty"`
+	Tolerations     []models.Toleration `json:"tolerations,omitempty"`
+	ReservationID   string              `json:"reservation_id,omitempty"`
+	PlacementPolicy string              `json:"placement_policy,omitempty"`
+	AllowCrossNode  bool                `json:"allow_cross_node,omitempty"`
+	Command         string              `json:"command"`
+	Env             map[string]string   `json:"env,omitempty"`
+	ExpectedRuntime int                 `json:"expected_runtime,omitempty"`
+	MaxRuntime      int                 `json:"max_runtime,omitempty"`
+	Deadline        *time.Time          `json:"deadline,omitempty"`
+	Retry           *retryRequest       `json:"retry,omitempty"`
+	// ExpectCapacity accepts a task the cluster can't run yet, with
+	// warnings, for capacity expected to join later
+	ExpectCapacity bool `json:"expect_capacity,omitempty"`
+}
+
+// taskResponse is a task as returned by the API, with its current place in
+// the queue
+type taskResponse struct {
+	*models.Task
+	EffectivePriority int `json:"effective_priority"`
+	WaitTime          int `json:"wait_time,omitempty"` // seconds
+}
+
+// newTaskResponse adds a task's effective priority and wait time
+func (s *RESTServer) newTaskResponse(task *models.Task, now time.Time) *taskResponse {
+	return &taskResponse{
+		Task:              task,
+		EffectivePriority: s.engine.EffectivePriority(task, now),
+		WaitTime:          int(scheduler.WaitTime(task, now).Seconds()),
+	}
+}
+
+// maxRetryAttempts bounds the attempts a retry policy may allow
+const maxRetryAttempts = 100
+
+// retryRequest is the retry policy of a task to create
+type retryRequest struct {
+	MaxAttempts int      `json:"max_attempts"`
+	Backoff     int      `json:"backoff,omitempty"`
+	MaxBackoff  int      `json:"max_backoff,omitempty"`
+	RetryOn     []string `json:"retry_on,omitempty"`
+}
+
+// newRetryPolicy validates a retry policy request
+func newRetryPolicy(req *retryRequest) (*models.RetryPolicy, error) {
+	if req.MaxAttempts < 1 || req.MaxAttempts > maxRetryAttempts {
+		return nil, fmt.Errorf("Retry max attempts must be between 1 and %d", maxRetryAttempts)
+	}
+	if req.Backoff < 0 || req.MaxBackoff < 0 {
+		return nil, errors.New("Retry backoff must not be negative")
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendError(w, http.StatusBadRequest, "Invalid request body")
-		return
+	policy := &models.RetryPolicy{
+		MaxAttempts: req.MaxAttempts,
+		Backoff:     req.Backoff,
+		MaxBackoff:  req.MaxBackoff,
+	}
+	for _, class := range req.RetryOn {
+		switch failure := models.FailureClass(class); failure {
+		case models.FailureExit, models.FailureAgentLost, models.FailurePreempted, models.FailureGPUError:
+			policy.RetryOn = append(policy.RetryOn, failure)
+		default:
+			return nil, errors.New("Retry classes must be 'exit', 'agent_lost', 'preempted' or 'gpu_error'")
+		}
+	}
+	return policy, nil
+}
+
+// maxAffinityWeight bounds the weight of a preferred affinity rule
+const maxAffinityWeight = 100
+
+// validateAffinity checks a task's affinity rules
+func validateAffinity(affinity *models.Affinity) error {
+	for _, rule := range affinity.Node {
+		if len(rule.Expressions) == 0 {
+			return errors.New("Node affinity rules require expressions")
+		}
+		for _, expr := range rule.Expressions {
+			if expr.Key == "" {
+				return errors.New("Label expressions require a key")
+			}
+			switch expr.Operator {
+			case models.LabelOpIn, models.LabelOpNotIn:
+				if len(expr.Values) == 0 {
+					return fmt.Errorf("Operator %s requires values", expr.Operator)
+				}
+			case models.LabelOpExists, models.LabelOpDoesNotExist:
+				if len(expr.Values) > 0 {
+					return fmt.Errorf("Operator %s takes no values", expr.Operator)
+				}
+			default:
+				return errors.New("Operator must be 'In', 'NotIn', 'Exists' or 'DoesNotExist'")
+			}
+		}
+		if rule.Weight < 0 || rule.Weight > maxAffinityWeight {
+			return fmt.Errorf("Affinity weight must be between 0 and %d", maxAffinityWeight)
+		}
+	}
+
+	for _, rule := range slices.Concat(affinity.Task, affinity.TaskAnti) {
+		if len(rule.Selector) == 0 {
+			return errors.New("Task affinity rules require a selector")
+		}
+		if rule.Weight < 0 || rule.Weight > maxAffinityWeight {
+			return fmt.Errorf("Affinity weight must be between 0 and %d", maxAffinityWeight)
+		}
+	}
+	return nil
+}
+
+// validateTolerations checks a task's taint tolerations
+func validateTolerations(tolerations []models.Toleration) error {
+	for _, toleration := range tolerations {
+		if toleration.Key == "" {
+			return errors.New("Tolerations require a key")
+		}
+		switch toleration.Operator {
+		case "", models.TolerationOpEqual:
+		case models.TolerationOpExists:
+			if toleration.Value != "" {
+				return errors.New("Operator Exists takes no value")
+			}
+		default:
+			return errors.New("Toleration operator must be 'Equal' or 'Exists'")
+		}
 	}
+	return nil
+}
 
+// newTask validates a task request and builds the pending task
+func (s *RESTServer) newTask(req *taskRequest) (*models.Task, error) {
 	// Validate request
 	if req.Command == "" {
-		s.sendError(w, http.StatusBadRequest, "Command is required")
-		return
+		return nil, errors.New("Command is required")
+	}
+
+	taskType := models.TaskType(req.Type)
+	var gang *models.GangSpec
+	switch taskType {
+	case "", models.TaskTypeSingle:
+		taskType = models.TaskTypeSingle
+	case models.TaskTypeDistributed:
+		if req.Nodes <= 0 || req.GPUsPerNode <= 0 {
+			return nil, errors.New("Distributed tasks require positive nodes and gpus_per_node")
+		}
+		if req.GPUCount != 0 && req.GPUCount != req.Nodes*req.GPUsPerNode {
+			return nil, errors.New("GPU count must equal nodes * gpus_per_node")
+		}
+		if req.MasterPort < 0 || req.MasterPort > 65535 {
+			return nil, errors.New("Master port must be between 0 and 65535")
+		}
+		gang = &models.GangSpec{
+			Nodes:       req.Nodes,
+			GPUsPerNode: req.GPUsPerNode,
+			MasterPort:  req.MasterPort,
+		}
+		req.GPUCount = req.Nodes * req.GPUsPerNode
+	default:
+		return nil, errors.New("Type must be 'single' or 'distributed'")
+	}
+
+	if req.GPUShare != nil {
+		if taskType != models.TaskTypeSingle || req.GPUCount > 1 {
+			return nil, errors.New("Tasks with a GPU share run on a single GPU")
+		}
+		if req.GPUShare.Percent < 0 || req.GPUShare.Percent > 99 || req.GPUShare.MemoryMB < 0 {
+			return nil, errors.New("GPU share percent must be between 1 and 99 and memory must not be negative")
+		}
+		if req.GPUShare.Percent == 0 && req.GPUShare.MemoryMB == 0 {
+			return nil, errors.New("GPU share requires percent or memory_mb")
+		}
+		req.GPUCount = 1
 	}
+
 	if req.GPUCount <= 0 {
-		s.sendError(w, http.StatusBadRequest, "GPU count must be positive")
-		return
+		return nil, errors.New("GPU count must be positive")
 	}
 
 	priority := models.Priority(req.Priority)
-	if priority != models.PriorityHigh && priority != models.PriorityLow {
-		s.sendError(w, http.StatusBadRequest, "Priority must be 'high' or 'low'")
-		return
+	if _, exists := s.engine.PriorityClass(priority); !exists {
+		return nil, fmt.Errorf("Unknown priority class: %s", priority)
+	}
+
+	if req.GPUModel != nil && len(req.GPUModels) > 0 {
+		return nil, errors.New("Specify either gpu_model or gpu_models")
+	}
+	seen := make(map[string]bool, len(req.GPUModels))
+	for _, model := range req.GPUModels {
+		if model == "" || seen[model] {
+			return nil, errors.New("GPU models must be non-empty and unique")
+		}
+		seen[model] = true
+	}
+	if req.MinMemoryMB < 0 {
+		return nil, errors.New("Min memory must not be negative")
+	}
+	if req.MinComputeCap != "" {
+		if _, _, err := scheduler.ParseComputeCapability(req.MinComputeCap); err != nil {
+			return nil, errors.New("Min compute capability must look like '8.0'")
+		}
+	}
+	if req.CPU < 0 || req.MemoryMB < 0 {
+		return nil, errors.New("CPU and memory must not be negative")
+	}
+
+	if req.Affinity != nil {
+		if err := validateAffinity(req.Affinity); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateTolerations(req.Tolerations); err != nil {
+		return nil, err
+	}
+
+	if req.ReservationID != "" {
+		reservation, err := s.state.GetReservation(req.ReservationID)
+		if err != nil {
+			return nil, fmt.Errorf("Unknown reservation: %s", req.ReservationID)
+		}
+		if req.GPUCount > reservation.GPUCount {
+			return nil, fmt.Errorf("Reservation %s holds only %d GPUs", reservation.ID, reservation.GPUCount)
+		}
+	}
+
+	if req.PlacementPolicy != "" {
+		if _, err := scheduler.NewPlacementPolicy(req.PlacementPolicy); err != nil {
+			return nil, errors.New("Placement policy must be 'binpack', 'spread' or 'random'")
+		}
+	}
+
+	if req.ExpectedRuntime < 0 {
+		return nil, errors.New("Expected runtime must not be negative")
+	}
+	if req.MaxRuntime < 0 {
+		return nil, errors.New("Max runtime must not be negative")
+	}
+	if req.Deadline != nil && !req.Deadline.After(time.Now()) {
+		return nil, errors.New("Deadline must be in the future")
+	}
+
+	var retry *models.RetryPolicy
+	if req.Retry != nil {
+		policy, err := newRetryPolicy(req.Retry)
+		if err != nil {
+			return nil, err
+		}
+		retry = policy
+	}
+
+	team := req.Team
+	if team == "" {
+		team = models.DefaultTeam
 	}
 
 	// Create task
-	task := &models.Task{
-		ID:        generateTaskID(),
-		Priority:  priority,
-		GPUCount:  req.GPUCount,
-		GPUModel:  req.GPUModel,
-		Command:   req.Command,
-		Env:       req.Env,
-		Status:    models.TaskStatusPending,
-		CreatedAt: time.Now(),
+	return &models.Task{
+		ID:              generateTaskID(),
+		Type:            taskType,
+		Team:            team,
+		Project:         req.Project,
+		User:            req.User,
+		Priority:        priority,
+		GPUCount:        req.GPUCount,
+		Gang:            gang,
+		GPUModel:        req.GPUModel,
+		GPUModels:       req.GPUModels,
+		MinMemoryMB:     req.MinMemoryMB,
+		MinComputeCap:   req.MinComputeCap,
+		GPUShare:        req.GPUShare,
+		CPU:             req.CPU,
+		MemoryMB:        req.MemoryMB,
+		Labels:          req.Labels,
+		NodeSelector:    req.NodeSelector,
+		Affinity:        req.Affinity,
+		Tolerations:     req.Tolerations,
+		ReservationID:   req.ReservationID,
+		PlacementPolicy: req.PlacementPolicy,
+		AllowCrossNode:  req.AllowCrossNode,
+		Command:         req.Command,
+		Env:             req.Env,
+		ExpectedRuntime: req.ExpectedRuntime,
+		MaxRuntime:      req.MaxRuntime,
+		Deadline:        req.Deadline,
+		Retry:           retry,
+		Status:          models.TaskStatusPending,
+		CreatedAt:       time.Now(),
+	}, nil
+}
+
+// createTask creates a new task
+func (s *RESTServer) createTask(w http.ResponseWriter, r *http.Request) {
+	var req taskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
 	}
 
-	s.state.AddTask(task)
+	task, err := s.newTask(&req)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	warnings, err := s.engine.Admit(task, req.ExpectCapacity)
+	if err != nil {
+		s.sendError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
 
-	// Trigger scheduling
-	s.engine.TriggerSchedule()
+	s.state.AddTask(task)
+	s.engine.Notify(scheduler.EventTaskAdded)
 
 	s.logger.Info("Task created",
 		zap.String("task_id", task.ID),
+		zap.String("type", string(task.Type)),
+		zap.String("team", task.Team),
 		zap.String("priority", string(task.Priority)),
 	)
+	s.logAdmissionWarnings(zap.String("task_id", task.ID), warnings)
 
-	s.sendJSON(w, http.StatusCreated, map[string]interface{}{
+	s.sendJSON(w, http.StatusCreated, withWarnings(map[string]interface{}{
 		"task_id":    task.ID,
 		"status":     task.Status,
 		"created_at": task.CreatedAt,
-	})
+	}, warnings))
+}
+
+// logAdmissionWarnings logs the problems of a request accepted without the
+// capacity to run it
+func (s *RESTServer) logAdmissionWarnings(id zap.Field, warnings []string) {
+	if len(warnings) > 0 {
+		s.logger.Warn("Accepted tasks the cluster can't run yet", id, zap.Strings("warnings", warnings))
+	}
+}
+
+// withWarnings adds the admission warnings, if any, to a response
+func withWarnings(response map[string]interface{}, warnings []string) map[string]interface{} {
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	return response
 }
 
 // listTasks lists all tasks
 func (s *RESTServer) listTasks(w http.ResponseWriter, r *http.Request) {
 	state := s.state.GetState()
 
-	tasks := make([]*models.Task, 0, len(state.Tasks))
+	now := time.Now()
+	tasks := make([]*taskResponse, 0, len(state.Tasks))
 	for _, task := range state.Tasks {
-		tasks = append(tasks, task)
+		tasks = append(tasks, s.newTaskResponse(task, now))
 	}
 
 	s.sendJSON(w, http.StatusOK, map[string]interface{}{
@@ -164,12 +506,31 @@ func (s *RESTServer) listTasks(w http.ResponseWriter, r *http.Request) {
 // handleTaskByID handles task operations by ID
 func (s *RESTServer) handleTaskByID(w http.ResponseWriter, r *http.Request) {
 	// Extract task ID from path
-	taskID := r.URL.Path[len("/api/v1/tasks/"):]
+	taskID, action, _ := strings.Cut(r.URL.Path[len("/api/v1/tasks/"):], "/")
 	if taskID == "" {
 		s.sendError(w, http.StatusBadRequest, "Task ID is required")
 		return
 	}
 
+	if action != "" {
+		if action != "explain" {
+			s.sendError(w, http.StatusNotFound, "Unknown task action")
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		explanation, err := s.engine.Explain(taskID)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Task not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, explanation)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		s.getTask(w, r, taskID)
@@ -188,151 +549,1164 @@ func (s *RESTServer) getTask(w http.ResponseWriter, r *http.Request, taskID stri
 		return
 	}
 
-	s.sendJSON(w, http.StatusOK, task)
+	s.sendJSON(w, http.StatusOK, s.newTaskResponse(task, time.Now()))
 }
 
 // deleteTask cancels a task
 func (s *RESTServer) deleteTask(w http.ResponseWriter, r *http.Request, taskID string) {
-	task, err := s.state.GetTask(taskID)
-	if err != nil {
+	if err := s.engine.CancelTask(taskID); err != nil {
+		if errors.Is(err, scheduler.ErrTaskFinished) {
+			s.sendError(w, http.StatusConflict, "Task already finished")
+			return
+		}
 		s.sendError(w, http.StatusNotFound, "Task not found")
 		return
 	}
 
-	if task.Status == models.TaskStatusRunning {
-		s.sendError(w, http.StatusBadRequest, "Cannot cancel running task")
-		return
-	}
-
-	// TODO: Actually cancel the task
 	s.sendJSON(w, http.StatusOK, map[string]string{
 		"message": "Task cancelled",
 	})
 }
 
-// handleGPUs handles GPU listing
-func (s *RESTServer) handleGPUs(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// maxArraySize bounds the number of tasks a task array expands into
+const maxArraySize = 10000
+
+// arrayRequest is the JSON body describing a task array to create. The
+// template command may use {{index}} and {{<parameter>}} placeholders.
+type arrayRequest struct {
+	Name        string              `json:"name,omitempty"`
+	Template    taskRequest         `json:"template"`
+	Range       *arrayRange         `json:"range,omitempty"`
+	Parameters  []map[string]string `json:"parameters,omitempty"`
+	MaxParallel int                 `json:"max_parallel,omitempty"`
+}
+
+// arrayRange is an inclusive range of array indexes
+type arrayRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Step  int `json:"step,omitempty"`
+}
+
+// newTaskArray validates a task array request and expands it into tasks.
+// Each task gets DGPU_ARRAY_INDEX and its parameters in its environment.
+func (s *RESTServer) newTaskArray(req *arrayRequest) (*models.TaskArray, []*models.Task, error) {
+	if _, err := s.newTask(&req.Template); err != nil {
+		return nil, nil, fmt.Errorf("Template: %s", err)
+	}
+	if req.MaxParallel < 0 {
+		return nil, nil, errors.New("Max parallel must not be negative")
+	}
+	if (req.Range == nil) == (len(req.Parameters) == 0) {
+		return nil, nil, errors.New("Exactly one of range or parameters is required")
 	}
 
-	state := s.state.GetState()
+	// Array indexes and the parameters of each index
+	var indexes []int
+	params := make([]map[string]string, 0)
+	if req.Range != nil {
+		step := req.Range.Step
+		if step == 0 {
+			step = 1
+		}
+		if step < 0 || req.Range.End < req.Range.Start {
+			return nil, nil, errors.New("Range must have end >= start and a positive step")
+		}
+		if (req.Range.End-req.Range.Start)/step+1 > maxArraySize {
+			return nil, nil, fmt.Errorf("Task arrays are limited to %d tasks", maxArraySize)
+		}
+		for i := req.Range.Start; i <= req.Range.End; i += step {
+			indexes = append(indexes, i)
+			params = append(params, nil)
+		}
+	} else {
+		if len(req.Parameters) > maxArraySize {
+			return nil, nil, fmt.Errorf("Task arrays are limited to %d tasks", maxArraySize)
+		}
+		for i, p := range req.Parameters {
+			indexes = append(indexes, i)
+			params = append(params, p)
+		}
+	}
 
-	gpus := make([]*models.GPU, 0, len(state.GPUs))
-	var idle, busy, offline int
+	array := &models.TaskArray{
+		ID:          generateArrayID(),
+		Name:        req.Name,
+		Tasks:       make([]string, 0, len(indexes)),
+		MaxParallel: req.MaxParallel,
+		CreatedAt:   time.Now(),
+	}
 
-	for _, gpu := range state.GPUs {
-		gpus = append(gpus, gpu)
-		switch gpu.Status {
-		case models.GPUStatusIdle:
-			idle++
-		case models.GPUStatusBusy:
-			busy++
-		case models.GPUStatusOffline:
-			offline++
+	tasks := make([]*models.Task, 0, len(indexes))
+	for i, index := range indexes {
+		task, _ := s.newTask(&req.Template)
+		task.ID = fmt.Sprintf("%s-%d", array.ID, index)
+		task.ArrayID = array.ID
+		task.ArrayIndex = index
+		task.Command = expandArrayCommand(req.Template.Command, index, params[i])
+
+		task.Env = make(map[string]string, len(req.Template.Env)+len(params[i])+1)
+		for key, value := range req.Template.Env {
+			task.Env[key] = value
+		}
+		for key, value := range params[i] {
+			task.Env[key] = value
 		}
+		task.Env["DGPU_ARRAY_INDEX"] = strconv.Itoa(index)
+
+		array.Tasks = append(array.Tasks, task.ID)
+		tasks = append(tasks, task)
 	}
 
-	s.sendJSON(w, http.StatusOK, map[string]interface{}{
-		"total":   len(gpus),
-		"idle":    idle,
-		"busy":    busy,
-		"offline": offline,
-		"gpus":    gpus,
-	})
+	return array, tasks, nil
 }
 
-// handleQuota handles quota operations
-func (s *RESTServer) handleQuota(w http.ResponseWriter, r *http.Request) {
+// expandArrayCommand fills the {{index}} and {{<parameter>}} placeholders
+// of a task array's command template
+func expandArrayCommand(command string, index int, params map[string]string) string {
+	pairs := []string{"{{index}}", strconv.Itoa(index)}
+	for key, value := range params {
+		pairs = append(pairs, "{{"+key+"}}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(command)
+}
+
+// handleArrays handles task array creation and listing
+func (s *RESTServer) handleArrays(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
+	case http.MethodPost:
+		s.createTaskArray(w, r)
 	case http.MethodGet:
-		s.getQuota(w, r)
-	case http.MethodPut:
-		s.updateQuota(w, r)
+		arrays := s.state.ListTaskArraySummaries()
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"arrays": arrays,
+			"total":  len(arrays),
+		})
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-// getQuota gets current quota
-func (s *RESTServer) getQuota(w http.ResponseWriter, r *http.Request) {
-	state := s.state.GetState()
-
-	s.sendJSON(w, http.StatusOK, map[string]interface{}{
-		"total_gpus": state.Quota.TotalGPUs,
-		"online": map[string]int{
-			"quota":     state.Quota.OnlineQuota,
-			"used":      state.Quota.OnlineUsed,
-			"available": state.Quota.OnlineQuota - state.Quota.OnlineUsed,
-		},
-		"batch": map[string]int{
-			"quota":     state.Quota.BatchQuota,
-			"used":      state.Quota.BatchUsed,
-			"available": state.Quota.BatchQuota - state.Quota.BatchUsed,
-		},
-	})
-}
-
-// updateQuota updates quota configuration
-func (s *RESTServer) updateQuota(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		OnlinePercent float64 `json:"online_percent"`
-	}
-
+// createTaskArray creates a task array and queues its tasks
+func (s *RESTServer) createTaskArray(w http.ResponseWriter, r *http.Request) {
+	var req arrayRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.sendError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.OnlinePercent < 0 || req.OnlinePercent > 1 {
-		s.sendError(w, http.StatusBadRequest, "Online percent must be between 0 and 1")
+	array, tasks, err := s.newTaskArray(&req)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	batchPercent := 1.0 - req.OnlinePercent
-	s.state.SetQuota(req.OnlinePercent, batchPercent)
+	// The tasks only differ in their command and environment
+	warnings, err := s.engine.Admit(tasks[0], req.Template.ExpectCapacity)
+	if err != nil {
+		s.sendError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Template: %s", err))
+		return
+	}
 
-	s.sendJSON(w, http.StatusOK, map[string]string{
-		"message": "Quota updated",
-	})
-}
+	s.state.AddTaskArray(array, tasks)
+	s.engine.Notify(scheduler.EventTaskAdded)
 
-// handleHealth handles health check
-func (s *RESTServer) handleHealth(w http.ResponseWriter, r *http.Request) {
-	s.sendJSON(w, http.StatusOK, map[string]string{
-		"status": "healthy",
-	})
-}
+	s.logger.Info("Task array created",
+		zap.String("array_id", array.ID),
+		zap.Int("task_count", len(tasks)),
+		zap.Int("max_parallel", array.MaxParallel),
+	)
+	s.logAdmissionWarnings(zap.String("array_id", array.ID), warnings)
 
-// loggingMiddleware logs all requests
-func (s *RESTServer) loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		s.logger.Info("HTTP request",
-			zap.String("method", r.Method),
-			zap.String("path", r.URL.Path),
-			zap.Duration("duration", time.Since(start)),
-		)
-	})
+	s.sendJSON(w, http.StatusCreated, withWarnings(map[string]interface{}{
+		"array_id":   array.ID,
+		"task_count": len(tasks),
+		"created_at": array.CreatedAt,
+	}, warnings))
 }
 
-// corsMiddleware adds CORS headers
-func (s *RESTServer) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// handleArrayByID handles task array operations by ID
+func (s *RESTServer) handleArrayByID(w http.ResponseWriter, r *http.Request) {
+	arrayID := r.URL.Path[len("/api/v1/arrays/"):]
+	if arrayID == "" {
+		s.sendError(w, http.StatusBadRequest, "Array ID is required")
+		return
+	}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+	switch r.Method {
+	case http.MethodGet:
+		summary, err := s.state.GetTaskArraySummary(arrayID)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Task array not found")
 			return
 		}
+		s.sendJSON(w, http.StatusOK, summary)
 
-		next.ServeHTTP(w, r)
-	})
-}
+	case http.MethodDelete:
+		cancelled, err := s.engine.CancelTaskArray(arrayID)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Task array not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"message":   "Task array cancelled",
+			"cancelled": cancelled,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// workflowRequest is the JSON body describing a workflow to create
+type workflowRequest struct {
+	Name  string                `json:"name,omitempty"`
+	Tasks []workflowTaskRequest `json:"tasks"`
+}
+
+// workflowTaskRequest describes one task of a workflow
+type workflowTaskRequest struct {
+	taskRequest
+	Name      string              `json:"name"`
+	DependsOn []dependencyRequest `json:"depends_on,omitempty"`
+}
+
+// dependencyRequest names an upstream task of the same workflow
+type dependencyRequest struct {
+	Task      string `json:"task"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// newWorkflow validates a workflow request and builds the workflow and its
+// tasks. Task IDs are derived from the workflow ID and the task names.
+func (s *RESTServer) newWorkflow(req *workflowRequest) (*models.Workflow, []*models.Task, error) {
+	if len(req.Tasks) == 0 {
+		return nil, nil, errors.New("Workflow needs at least one task")
+	}
+
+	workflow := &models.Workflow{
+		ID:        generateWorkflowID(),
+		Name:      req.Name,
+		Tasks:     make([]string, 0, len(req.Tasks)),
+		CreatedAt: time.Now(),
+	}
+
+	ids := make(map[string]string, len(req.Tasks))
+	for _, t := range req.Tasks {
+		if !validTaskName(t.Name) {
+			return nil, nil, fmt.Errorf("Task name %q must be non-empty and only use letters, digits, '-', '_' or '.'", t.Name)
+		}
+		if _, exists := ids[t.Name]; exists {
+			return nil, nil, fmt.Errorf("Task name %q is used twice", t.Name)
+		}
+		ids[t.Name] = fmt.Sprintf("%s-%s", workflow.ID, t.Name)
+	}
+
+	tasks := make([]*models.Task, 0, len(req.Tasks))
+	for i := range req.Tasks {
+		t := &req.Tasks[i]
+		task, err := s.newTask(&t.taskRequest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Task %s: %s", t.Name, err)
+		}
+		task.ID = ids[t.Name]
+		task.Name = t.Name
+		task.WorkflowID = workflow.ID
+
+		for _, dep := range t.DependsOn {
+			upstreamID, exists := ids[dep.Task]
+			if !exists {
+				return nil, nil, fmt.Errorf("Task %s depends on unknown task %q", t.Name, dep.Task)
+			}
+			condition := models.DependencyCondition(dep.Condition)
+			switch condition {
+			case "":
+				condition = models.DependOnSuccess
+			case models.DependOnSuccess, models.DependOnFailure, models.DependAlways:
+			default:
+				return nil, nil, errors.New("Condition must be 'on_success', 'on_failure' or 'always'")
+			}
+			task.DependsOn = append(task.DependsOn, models.Dependency{
+				TaskID:    upstreamID,
+				Condition: condition,
+			})
+		}
+
+		workflow.Tasks = append(workflow.Tasks, task.ID)
+		tasks = append(tasks, task)
+	}
+
+	if hasCycle(tasks) {
+		return nil, nil, errors.New("Workflow dependencies must not form a cycle")
+	}
+
+	return workflow, tasks, nil
+}
+
+// validTaskName reports whether a workflow task name can be used in task IDs
+func validTaskName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// hasCycle reports whether the dependencies between tasks form a cycle
+func hasCycle(tasks []*models.Task) bool {
+	indegree := make(map[string]int, len(tasks))
+	downstream := make(map[string][]string)
+	for _, task := range tasks {
+		indegree[task.ID] += 0
+		for _, dep := range task.DependsOn {
+			indegree[task.ID]++
+			downstream[dep.TaskID] = append(downstream[dep.TaskID], task.ID)
+		}
+	}
+
+	ready := make([]string, 0)
+	for id, degree := range indegree {
+		if degree == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	visited := 0
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, next := range downstream[id] {
+			indegree[next]--
+			if indegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	return visited != len(tasks)
+}
+
+// handleWorkflows handles workflow creation and listing
+func (s *RESTServer) handleWorkflows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.createWorkflow(w, r)
+	case http.MethodGet:
+		s.listWorkflows(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// createWorkflow creates a workflow and queues its root tasks
+func (s *RESTServer) createWorkflow(w http.ResponseWriter, r *http.Request) {
+	var req workflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	workflow, tasks, err := s.newWorkflow(&req)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	warnings := make([]string, 0)
+	for i, task := range tasks {
+		problems, err := s.engine.Admit(task, req.Tasks[i].ExpectCapacity)
+		if err != nil {
+			s.sendError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Task %s: %s", task.Name, err))
+			return
+		}
+		for _, problem := range problems {
+			warnings = append(warnings, fmt.Sprintf("Task %s: %s", task.Name, problem))
+		}
+	}
+
+	s.state.AddWorkflow(workflow, tasks)
+	s.engine.Notify(scheduler.EventTaskAdded)
+
+	s.logger.Info("Workflow created",
+		zap.String("workflow_id", workflow.ID),
+		zap.Int("task_count", len(tasks)),
+	)
+	s.logAdmissionWarnings(zap.String("workflow_id", workflow.ID), warnings)
+
+	s.sendJSON(w, http.StatusCreated, withWarnings(map[string]interface{}{
+		"workflow_id": workflow.ID,
+		"tasks":       workflow.Tasks,
+		"created_at":  workflow.CreatedAt,
+	}, warnings))
+}
+
+// listWorkflows lists all workflows with their aggregated status
+func (s *RESTServer) listWorkflows(w http.ResponseWriter, r *http.Request) {
+	workflows := s.state.ListWorkflowSummaries()
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"workflows": workflows,
+		"total":     len(workflows),
+	})
+}
+
+// handleWorkflowByID returns a workflow's aggregated status
+func (s *RESTServer) handleWorkflowByID(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.URL.Path[len("/api/v1/workflows/"):]
+	if workflowID == "" {
+		s.sendError(w, http.StatusBadRequest, "Workflow ID is required")
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary, err := s.state.GetWorkflowSummary(workflowID)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "Workflow not found")
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, summary)
+}
+
+// scheduleRequest is the JSON body describing a scheduled task to create.
+// Without a cron expression the task runs once at not_before.
+type scheduleRequest struct {
+	Name              string      `json:"name,omitempty"`
+	Cron              string      `json:"cron,omitempty"`
+	NotBefore         *time.Time  `json:"not_before,omitempty"`
+	ConcurrencyPolicy string      `json:"concurrency_policy,omitempty"`
+	Task              taskRequest `json:"task"`
+}
+
+// newSchedule validates a schedule request and builds the scheduled task
+func (s *RESTServer) newSchedule(req *scheduleRequest) (*models.ScheduledTask, error) {
+	if req.Cron == "" && req.NotBefore == nil {
+		return nil, errors.New("Cron or not_before is required")
+	}
+	if req.Cron != "" {
+		if _, err := scheduler.ParseCron(req.Cron); err != nil {
+			return nil, fmt.Errorf("Invalid cron expression: %s", err)
+		}
+	}
+
+	policy := models.ConcurrencyPolicy(req.ConcurrencyPolicy)
+	switch policy {
+	case "":
+		policy = models.ConcurrencyAllow
+	case models.ConcurrencyAllow, models.ConcurrencyForbid, models.ConcurrencyReplace:
+	default:
+		return nil, errors.New("Concurrency policy must be 'allow', 'forbid' or 'replace'")
+	}
+
+	template, err := s.newTask(&req.Task)
+	if err != nil {
+		return nil, fmt.Errorf("Task: %s", err)
+	}
+	// Each run gets its own ID and creation time
+	template.ID = ""
+
+	return &models.ScheduledTask{
+		ID:                generateScheduleID(),
+		Name:              req.Name,
+		Cron:              req.Cron,
+		NotBefore:         req.NotBefore,
+		ConcurrencyPolicy: policy,
+		Template:          *template,
+		CreatedAt:         time.Now(),
+	}, nil
+}
+
+// handleSchedules handles scheduled task creation and listing
+func (s *RESTServer) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.createSchedule(w, r)
+	case http.MethodGet:
+		schedules := s.state.ListSchedules()
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"schedules": schedules,
+			"total":     len(schedules),
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// createSchedule creates a scheduled task
+func (s *RESTServer) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	schedule, err := s.newSchedule(&req)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	warnings, err := s.engine.Admit(&schedule.Template, req.Task.ExpectCapacity)
+	if err != nil {
+		s.sendError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Task: %s", err))
+		return
+	}
+
+	if err := s.state.AddSchedule(schedule); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.logger.Info("Schedule created",
+		zap.String("schedule_id", schedule.ID),
+		zap.String("cron", schedule.Cron),
+		zap.String("concurrency_policy", string(schedule.ConcurrencyPolicy)),
+	)
+	s.logAdmissionWarnings(zap.String("schedule_id", schedule.ID), warnings)
+
+	s.sendJSON(w, http.StatusCreated, withWarnings(map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"next_run_at": schedule.NextRunAt,
+		"created_at":  schedule.CreatedAt,
+	}, warnings))
+}
+
+// handleScheduleByID handles scheduled task operations by ID, including
+// POST /api/v1/schedules/{id}/pause and /resume
+func (s *RESTServer) handleScheduleByID(w http.ResponseWriter, r *http.Request) {
+	scheduleID, action, _ := strings.Cut(r.URL.Path[len("/api/v1/schedules/"):], "/")
+	if scheduleID == "" {
+		s.sendError(w, http.StatusBadRequest, "Schedule ID is required")
+		return
+	}
+
+	if action != "" {
+		if action != "pause" && action != "resume" {
+			s.sendError(w, http.StatusNotFound, "Unknown schedule action")
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		schedule, err := s.state.SetSchedulePaused(scheduleID, action == "pause")
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, schedule)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		schedule, err := s.state.GetSchedule(scheduleID)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, schedule)
+
+	case http.MethodDelete:
+		if err := s.state.DeleteSchedule(scheduleID); err != nil {
+			s.sendError(w, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, map[string]string{
+			"message": "Schedule deleted",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGPUs handles GPU listing
+func (s *RESTServer) handleGPUs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := s.state.GetState()
+
+	gpus := make([]*models.GPU, 0, len(state.GPUs))
+	var idle, busy, offline int
+
+	for _, gpu := range state.GPUs {
+		gpus = append(gpus, gpu)
+		switch gpu.Status {
+		case models.GPUStatusIdle:
+			idle++
+		case models.GPUStatusBusy:
+			busy++
+		case models.GPUStatusOffline:
+			offline++
+		}
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"total":   len(gpus),
+		"idle":    idle,
+		"busy":    busy,
+		"offline": offline,
+		"gpus":    gpus,
+	})
+}
+
+// reservationRequest is the JSON body describing an advance reservation
+type reservationRequest struct {
+	Owner        string            `json:"owner"`
+	GPUCount     int               `json:"gpu_count"`
+	GPUModel     string            `json:"gpu_model,omitempty"`
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+}
+
+// newReservation validates a reservation request and builds the
+// reservation, whose GPUs the engine picks
+func newReservation(req *reservationRequest, id string) (*models.Reservation, error) {
+	if req.Owner == "" {
+		return nil, errors.New("Owner is required")
+	}
+	if req.GPUCount <= 0 {
+		return nil, errors.New("GPU count must be positive")
+	}
+	if req.Start.IsZero() || !req.Start.Before(req.End) {
+		return nil, errors.New("Start must be before end")
+	}
+	if !req.End.After(time.Now()) {
+		return nil, errors.New("End must be in the future")
+	}
+
+	return &models.Reservation{
+		ID:           id,
+		Owner:        req.Owner,
+		GPUCount:     req.GPUCount,
+		GPUModel:     req.GPUModel,
+		NodeSelector: req.NodeSelector,
+		Start:        req.Start,
+		End:          req.End,
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// sendReservationError maps a failure to book a reservation to a status:
+// conflicts with other reservations are 409s
+func (s *RESTServer) sendReservationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, scheduler.ErrReservationConflict) {
+		s.sendError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.sendError(w, http.StatusBadRequest, err.Error())
+}
+
+// handleReservations handles reservation creation and listing
+func (s *RESTServer) handleReservations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req reservationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		reservation, err := newReservation(&req, generateReservationID())
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.engine.CreateReservation(reservation); err != nil {
+			s.sendReservationError(w, err)
+			return
+		}
+		s.sendJSON(w, http.StatusCreated, reservation)
+
+	case http.MethodGet:
+		reservations := s.state.ListReservations()
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"reservations": reservations,
+			"total":        len(reservations),
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReservationByID handles reservation operations by ID
+func (s *RESTServer) handleReservationByID(w http.ResponseWriter, r *http.Request) {
+	reservationID := r.URL.Path[len("/api/v1/reservations/"):]
+	if reservationID == "" {
+		s.sendError(w, http.StatusBadRequest, "Reservation ID is required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reservation, err := s.state.GetReservation(reservationID)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Reservation not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, reservation)
+
+	case http.MethodPut:
+		if _, err := s.state.GetReservation(reservationID); err != nil {
+			s.sendError(w, http.StatusNotFound, "Reservation not found")
+			return
+		}
+		var req reservationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		reservation, err := newReservation(&req, reservationID)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.engine.UpdateReservation(reservation); err != nil {
+			s.sendReservationError(w, err)
+			return
+		}
+
+		// A later or smaller window may let pending tasks run
+		s.engine.Notify(scheduler.EventReservationChanged)
+
+		s.sendJSON(w, http.StatusOK, reservation)
+
+	case http.MethodDelete:
+		if err := s.state.DeleteReservation(reservationID); err != nil {
+			s.sendError(w, http.StatusNotFound, "Reservation not found")
+			return
+		}
+		s.engine.Notify(scheduler.EventReservationChanged)
+
+		s.sendJSON(w, http.StatusOK, map[string]string{
+			"message": "Reservation deleted",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAgents handles agent listing
+func (s *RESTServer) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agents := s.state.ListAgents()
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"agents": agents,
+		"total":  len(agents),
+	})
+}
+
+// drainRequest is the JSON body of a drain
+type drainRequest struct {
+	Timeout int `json:"timeout,omitempty"` // seconds running tasks get to finish
+}
+
+// taintsRequest is the JSON body replacing an agent's taints
+type taintsRequest struct {
+	Taints []models.Taint `json:"taints"`
+}
+
+// handleAgentByID handles agent retrieval and maintenance actions
+func (s *RESTServer) handleAgentByID(w http.ResponseWriter, r *http.Request) {
+	agentID, action, _ := strings.Cut(r.URL.Path[len("/api/v1/agents/"):], "/")
+	if agentID == "" {
+		s.sendError(w, http.StatusBadRequest, "Agent ID is required")
+		return
+	}
+
+	if action == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		agent, err := s.state.GetAgent(agentID)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Agent not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, agent)
+		return
+	}
+
+	var (
+		agent *models.Agent
+		err   error
+	)
+	switch action {
+	case "cordon", "uncordon", "drain":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch action {
+		case "cordon":
+			agent, err = s.engine.Cordon(agentID)
+		case "uncordon":
+			agent, err = s.engine.Uncordon(agentID)
+		case "drain":
+			var req drainRequest
+			if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil && decodeErr != io.EOF {
+				s.sendError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			if req.Timeout < 0 {
+				s.sendError(w, http.StatusBadRequest, "Drain timeout must not be negative")
+				return
+			}
+			agent, err = s.engine.Drain(agentID, time.Duration(req.Timeout)*time.Second)
+		}
+
+	case "taints":
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req taintsRequest
+		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		seen := make(map[string]bool, len(req.Taints))
+		for _, taint := range req.Taints {
+			if taint.Key == "" || seen[taint.Key] {
+				s.sendError(w, http.StatusBadRequest, "Taint keys must be non-empty and unique")
+				return
+			}
+			seen[taint.Key] = true
+		}
+		agent, err = s.engine.SetTaints(agentID, req.Taints)
+
+	default:
+		s.sendError(w, http.StatusNotFound, "Unknown agent action")
+		return
+	}
+
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+
+	// Uncordoned nodes and removed taints may let pending tasks run
+	s.engine.Notify(scheduler.EventNodeChanged)
+
+	s.sendJSON(w, http.StatusOK, agent)
+}
+
+// handleQuota handles quota operations
+func (s *RESTServer) handleQuota(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getQuota(w, r)
+	case http.MethodPut:
+		s.updateQuota(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getQuota gets current quota
+func (s *RESTServer) getQuota(w http.ResponseWriter, r *http.Request) {
+	state := s.state.GetState()
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"total_gpus": state.Quota.TotalGPUs,
+		"online": map[string]int{
+			"quota":     state.Quota.OnlineQuota,
+			"used":      state.Quota.OnlineUsed,
+			"lent":      state.Quota.BatchBorrowed,
+			"available": state.Quota.OnlineQuota - state.Quota.OnlineUsed - state.Quota.BatchBorrowed,
+		},
+		"batch": map[string]int{
+			"quota":     state.Quota.BatchQuota,
+			"used":      state.Quota.BatchUsed,
+			"borrowed":  state.Quota.BatchBorrowed,
+			"available": state.Quota.BatchQuota - state.Quota.BatchUsed,
+		},
+	})
+}
+
+// updateQuota updates quota configuration
+func (s *RESTServer) updateQuota(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OnlinePercent float64 `json:"online_percent"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.OnlinePercent < 0 || req.OnlinePercent > 1 {
+		s.sendError(w, http.StatusBadRequest, "Online percent must be between 0 and 1")
+		return
+	}
+
+	batchPercent := 1.0 - req.OnlinePercent
+	s.state.SetQuota(req.OnlinePercent, batchPercent)
+	s.engine.Notify(scheduler.EventQuotaChanged)
+
+	s.sendJSON(w, http.StatusOK, map[string]string{
+		"message": "Quota updated",
+	})
+}
+
+// handleQuotas lists the organization and team quotas
+func (s *RESTServer) handleQuotas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := s.state.GetState()
+
+	orgs := make([]*models.OrgQuota, 0, len(state.OrgQuotas))
+	for _, quota := range state.OrgQuotas {
+		orgs = append(orgs, quota)
+	}
+	teams := make([]*models.TeamQuota, 0, len(state.TeamQuotas))
+	for _, quota := range state.TeamQuotas {
+		teams = append(teams, quota)
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"organizations": orgs,
+		"teams":         teams,
+	})
+}
+
+// handleTeamQuota handles quota operations for a team
+func (s *RESTServer) handleTeamQuota(w http.ResponseWriter, r *http.Request) {
+	team := r.URL.Path[len("/api/v1/quotas/"):]
+	if team == "" {
+		s.sendError(w, http.StatusBadRequest, "Team is required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		quota, err := s.state.GetTeamQuota(team)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Team quota not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, quota)
+
+	case http.MethodPut:
+		var quota models.TeamQuota
+		if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if err := s.validateQuotaSpec(&quota.QuotaSpec); err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for project, spec := range quota.Projects {
+			if err := s.validateQuotaSpec(&spec); err != nil {
+				s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Project %s: %s", project, err))
+				return
+			}
+		}
+		quota.Team = team
+		s.state.SetTeamQuota(&quota)
+
+		// Looser limits may let pending tasks run
+		s.engine.Notify(scheduler.EventQuotaChanged)
+
+		s.sendJSON(w, http.StatusOK, &quota)
+
+	case http.MethodDelete:
+		if err := s.state.DeleteTeamQuota(team); err != nil {
+			s.sendError(w, http.StatusNotFound, "Team quota not found")
+			return
+		}
+		s.engine.Notify(scheduler.EventQuotaChanged)
+
+		s.sendJSON(w, http.StatusOK, map[string]string{
+			"message": "Team quota deleted",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOrgQuota handles quota operations for an organization
+func (s *RESTServer) handleOrgQuota(w http.ResponseWriter, r *http.Request) {
+	org := r.URL.Path[len("/api/v1/org-quotas/"):]
+	if org == "" {
+		s.sendError(w, http.StatusBadRequest, "Organization is required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		quota, err := s.state.GetOrgQuota(org)
+		if err != nil {
+			s.sendError(w, http.StatusNotFound, "Organization quota not found")
+			return
+		}
+		s.sendJSON(w, http.StatusOK, quota)
+
+	case http.MethodPut:
+		var quota models.OrgQuota
+		if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if err := s.validateQuotaSpec(&quota.QuotaSpec); err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		quota.Organization = org
+		s.state.SetOrgQuota(&quota)
+		s.engine.Notify(scheduler.EventQuotaChanged)
+
+		s.sendJSON(w, http.StatusOK, &quota)
+
+	case http.MethodDelete:
+		if err := s.state.DeleteOrgQuota(org); err != nil {
+			s.sendError(w, http.StatusNotFound, "Organization quota not found")
+			return
+		}
+		s.engine.Notify(scheduler.EventQuotaChanged)
+
+		s.sendJSON(w, http.StatusOK, map[string]string{
+			"message": "Organization quota deleted",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateQuotaSpec checks the limits of one level of the quota hierarchy
+func (s *RESTServer) validateQuotaSpec(spec *models.QuotaSpec) error {
+	check := func(limits map[models.Priority]models.QuotaLimit) error {
+		for priority, limit := range limits {
+			if _, exists := s.engine.PriorityClass(priority); !exists {
+				return fmt.Errorf("Unknown priority class: %s", priority)
+			}
+			if limit.Min < 0 || limit.Max < 0 {
+				return errors.New("Quota limits must not be negative")
+			}
+			if limit.Max > 0 && limit.Min > limit.Max {
+				return errors.New("Quota min must not exceed max")
+			}
+		}
+		return nil
+	}
+
+	if err := check(spec.Limits); err != nil {
+		return err
+	}
+	for _, limits := range spec.Models {
+		if err := check(limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleTeams lists each team's fair-share standing
+func (s *RESTServer) handleTeams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	teams := s.engine.TeamShares()
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"teams": teams,
+		"total": len(teams),
+	})
+}
+
+// handlePriorityClasses lists the configured priority classes, highest first
+func (s *RESTServer) handlePriorityClasses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	classes := s.engine.PriorityClasses()
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"priority_classes": classes,
+		"total":            len(classes),
+	})
+}
+
+// handleSchedulerStats reports the scheduling loop's cycle count, latency
+// and the events that woke it
+func (s *RESTServer) handleSchedulerStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, s.engine.CycleStats())
+}
+
+// handleHealth handles health check
+func (s *RESTServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.sendJSON(w, http.StatusOK, map[string]string{
+		"status": "healthy",
+	})
+}
+
+// loggingMiddleware logs all requests
+func (s *RESTServer) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.logger.Info("HTTP request",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// corsMiddleware adds CORS headers
+func (s *RESTServer) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
 
 // sendJSON sends JSON response
 func (s *RESTServer) sendJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -352,3 +1726,23 @@ func (s *RESTServer) sendError(w http.ResponseWriter, status int, message string
 func generateTaskID() string {
 	return fmt.Sprintf("task-%d", time.Now().UnixNano())
 }
+
+// generateArrayID generates a unique task array ID
+func generateArrayID() string {
+	return fmt.Sprintf("array-%d", time.Now().UnixNano())
+}
+
+// generateScheduleID generates a unique schedule ID
+func generateScheduleID() string {
+	return fmt.Sprintf("schedule-%d", time.Now().UnixNano())
+}
+
+// generateWorkflowID generates a unique workflow ID
+func generateWorkflowID() string {
+	return fmt.Sprintf("workflow-%d", time.Now().UnixNano())
+}
+
+// generateReservationID generates a unique reservation ID
+func generateReservationID() string {
+	return fmt.Sprintf("reservation-%d", time.Now().UnixNano())
+}
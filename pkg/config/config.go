@@ -18,6 +18,7 @@ type SchedulerConfig struct {
 		Role             string `yaml:"role"`
 		ScheduleInterval int    `yaml:"schedule_interval"`
 		SnapshotInterval int    `yaml:"snapshot_interval"`
+		PlacementPolicy  string `yaml:"placement_policy"`
 	} `yaml:"scheduler"`
 
 	Replication struct {
@@ -28,10 +29,53 @@ type SchedulerConfig struct {
 	} `yaml:"replication"`
 
 	Quota struct {
-		OnlinePercent float64 `yaml:"online_percent"`
-		BatchPercent  float64 `yaml:"batch_percent"`
+		OnlinePercent      float64 `yaml:"online_percent"`
+		BatchPercent       float64 `yaml:"batch_percent"`
+		Borrowing          bool    `yaml:"borrowing"`
+		BorrowLimit        float64 `yaml:"borrow_limit"`
+		ReclaimMode        string  `yaml:"reclaim_mode"`
+		ReclaimGracePeriod int     `yaml:"reclaim_grace_period"`
 	} `yaml:"quota"`
 
+	Preemption struct {
+		Enabled     bool `yaml:"enabled"`
+		GracePeriod int  `yaml:"grace_period"`
+	} `yaml:"preemption"`
+
+	Backfill struct {
+		Enabled        bool `yaml:"enabled"`
+		DefaultRuntime int  `yaml:"default_runtime"`
+	} `yaml:"backfill"`
+
+	FairShare struct {
+		Enabled  bool               `yaml:"enabled"`
+		HalfLife int                `yaml:"half_life"`
+		Weights  map[string]float64 `yaml:"weights"`
+	} `yaml:"fair_share"`
+
+	PriorityClasses []struct {
+		Name        string `yaml:"name"`
+		Value       int    `yaml:"value"`
+		Pool        string `yaml:"pool"`
+		Preempt     bool   `yaml:"preempt"`
+		Preemptible bool   `yaml:"preemptible"`
+	} `yaml:"priority_classes"`
+
+	Aging struct {
+		Enabled  bool `yaml:"enabled"`
+		Interval int  `yaml:"interval"`
+		Step     int  `yaml:"step"`
+		MaxBoost int  `yaml:"max_boost"`
+	} `yaml:"aging"`
+
+	GPUSharing struct {
+		Models []string `yaml:"models"`
+	} `yaml:"gpu_sharing"`
+
+	Admission struct {
+		Mode string `yaml:"mode"`
+	} `yaml:"admission"`
+
 	Agent struct {
 		HeartbeatTimeout int `yaml:"heartbeat_timeout"`
 	} `yaml:"agent"`
@@ -51,8 +95,13 @@ type SchedulerConfig struct {
 // AgentConfig represents the agent configuration
 type AgentConfig struct {
 	Agent struct {
-		ID                string `yaml:"id"`
-		HeartbeatInterval int    `yaml:"heartbeat_interval"`
+		ID                string            `yaml:"id"`
+		Address           string            `yaml:"address"`
+		HeartbeatInterval int               `yaml:"heartbeat_interval"`
+		Labels            map[string]string `yaml:"labels"`
+		// CPU cores and memory offered to tasks, 0 for all of the node's
+		CPUCores int   `yaml:"cpu_cores"`
+		MemoryMB int64 `yaml:"memory_mb"`
 	} `yaml:"agent"`
 
 	Scheduler struct {
@@ -71,7 +120,13 @@ type AgentConfig struct {
 	Executor struct {
 		ExecutionMethod string `yaml:"execution_method"`
 		WorkDir         string `yaml:"work_dir"`
-		Docker          struct {
+		// Seconds tasks exceeding their time limit get between SIGTERM
+		// and SIGKILL
+		TimeoutGracePeriod int `yaml:"timeout_grace_period"`
+		// cgroup v2 directory under which tasks get a cgroup limiting their
+		// CPU and memory, empty to run tasks unlimited
+		CgroupRoot string `yaml:"cgroup_root"`
+		Docker     struct {
 			Socket       string `yaml:"socket"`
 			DefaultImage string `yaml:"default_image"`
 		} `yaml:"docker"`
@@ -125,6 +180,15 @@ func LoadAgentConfig(path string) (*AgentConfig, error) {
 		cfg.Agent.ID = hostname
 	}
 
+	// Default the advertised address to the hostname
+	if cfg.Agent.Address == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %w", err)
+		}
+		cfg.Agent.Address = hostname
+	}
+
 	// Validate configuration
 	if err := validateAgentConfig(&cfg); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -144,12 +208,71 @@ func validateSchedulerConfig(cfg *SchedulerConfig) error {
 	if cfg.Scheduler.Role != "master" && cfg.Scheduler.Role != "standby" {
 		return fmt.Errorf("scheduler.role must be 'master' or 'standby'")
 	}
+	switch cfg.Scheduler.PlacementPolicy {
+	case "", "binpack", "spread", "random":
+	default:
+		return fmt.Errorf("scheduler.placement_policy must be 'binpack', 'spread' or 'random'")
+	}
+	if cfg.Preemption.GracePeriod < 0 {
+		return fmt.Errorf("preemption.grace_period must not be negative")
+	}
+	if cfg.Backfill.DefaultRuntime < 0 {
+		return fmt.Errorf("backfill.default_runtime must not be negative")
+	}
+	if cfg.FairShare.HalfLife < 0 {
+		return fmt.Errorf("fair_share.half_life must not be negative")
+	}
+	for team, weight := range cfg.FairShare.Weights {
+		if weight <= 0 {
+			return fmt.Errorf("fair_share.weights.%s must be positive", team)
+		}
+	}
 	if cfg.Quota.OnlinePercent+cfg.Quota.BatchPercent != 1.0 {
 		return fmt.Errorf("quota percentages must sum to 1.0")
 	}
 	if cfg.Quota.OnlinePercent < 0 || cfg.Quota.OnlinePercent > 1 {
 		return fmt.Errorf("quota.online_percent must be between 0 and 1")
 	}
+	if cfg.Quota.BorrowLimit < 0 || cfg.Quota.BorrowLimit > 1 {
+		return fmt.Errorf("quota.borrow_limit must be between 0 and 1")
+	}
+	switch cfg.Quota.ReclaimMode {
+	case "", "preempt", "checkpoint":
+	default:
+		return fmt.Errorf("quota.reclaim_mode must be 'preempt' or 'checkpoint'")
+	}
+	if cfg.Quota.ReclaimGracePeriod < 0 {
+		return fmt.Errorf("quota.reclaim_grace_period must not be negative")
+	}
+	classes := make(map[string]bool, len(cfg.PriorityClasses))
+	for _, class := range cfg.PriorityClasses {
+		if class.Name == "" {
+			return fmt.Errorf("priority_classes entries require a name")
+		}
+		if classes[class.Name] {
+			return fmt.Errorf("priority class %q is defined twice", class.Name)
+		}
+		classes[class.Name] = true
+		if class.Pool != "online" && class.Pool != "batch" {
+			return fmt.Errorf("priority class %q pool must be 'online' or 'batch'", class.Name)
+		}
+	}
+	if cfg.Aging.Enabled {
+		if cfg.Aging.Interval <= 0 || cfg.Aging.Step <= 0 {
+			return fmt.Errorf("aging.interval and aging.step must be positive")
+		}
+		if cfg.Aging.MaxBoost <= 0 {
+			return fmt.Errorf("aging.max_boost must be positive")
+		}
+	}
+	switch cfg.Admission.Mode {
+	case "", "reject", "warn", "off":
+	default:
+		return fmt.Errorf("admission.mode must be 'reject', 'warn' or 'off'")
+	}
+	if cfg.Agent.HeartbeatTimeout < 0 {
+		return fmt.Errorf("agent.heartbeat_timeout must not be negative")
+	}
 	return nil
 }
 
@@ -167,5 +290,11 @@ func validateAgentConfig(cfg *AgentConfig) error {
 	if cfg.Executor.ExecutionMethod != "docker" && cfg.Executor.ExecutionMethod != "process" {
 		return fmt.Errorf("executor.execution_method must be 'docker' or 'process'")
 	}
+	if cfg.Executor.TimeoutGracePeriod < 0 {
+		return fmt.Errorf("executor.timeout_grace_period must not be negative")
+	}
+	if cfg.Agent.CPUCores < 0 || cfg.Agent.MemoryMB < 0 {
+		return fmt.Errorf("agent.cpu_cores and agent.memory_mb must not be negative")
+	}
 	return nil
 }
@@ -150,12 +150,17 @@ func TestValidateSchedulerConfig(t *testing.T) {
 					Role             string `yaml:"role"`
 					ScheduleInterval int    `yaml:"schedule_interval"`
 					SnapshotInterval int    `yaml:"snapshot_interval"`
+					PlacementPolicy  string `yaml:"placement_policy"`
 				}{
 					Role: "master",
 				},
 				Quota: struct {
-					OnlinePercent float64 `yaml:"online_percent"`
-					BatchPercent  float64 `yaml:"batch_percent"`
+					OnlinePercent      float64 `yaml:"online_percent"`
+					BatchPercent       float64 `yaml:"batch_percent"`
+					Borrowing          bool    `yaml:"borrowing"`
+					BorrowLimit        float64 `yaml:"borrow_limit"`
+					ReclaimMode        string  `yaml:"reclaim_mode"`
+					ReclaimGracePeriod int     `yaml:"reclaim_grace_period"`
 				}{
 					OnlinePercent: 0.7,
 					BatchPercent:  0.3,
@@ -177,12 +182,50 @@ func TestValidateSchedulerConfig(t *testing.T) {
 					Role             string `yaml:"role"`
 					ScheduleInterval int    `yaml:"schedule_interval"`
 					SnapshotInterval int    `yaml:"snapshot_interval"`
+					PlacementPolicy  string `yaml:"placement_policy"`
 				}{
 					Role: "invalid",
 				},
 				Quota: struct {
-					OnlinePercent float64 `yaml:"online_percent"`
-					BatchPercent  float64 `yaml:"batch_percent"`
+					OnlinePercent      float64 `yaml:"online_percent"`
+					BatchPercent       float64 `yaml:"batch_percent"`
+					Borrowing          bool    `yaml:"borrowing"`
+					BorrowLimit        float64 `yaml:"borrow_limit"`
+					ReclaimMode        string  `yaml:"reclaim_mode"`
+					ReclaimGracePeriod int     `yaml:"reclaim_grace_period"`
+				}{
+					OnlinePercent: 0.7,
+					BatchPercent:  0.3,
+				},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid placement policy",
+			cfg: &SchedulerConfig{
+				Server: struct {
+					GRPCAddress string `yaml:"grpc_address"`
+					HTTPAddress string `yaml:"http_address"`
+				}{
+					GRPCAddress: ":9090",
+					HTTPAddress: ":8080",
+				},
+				Scheduler: struct {
+					Role             string `yaml:"role"`
+					ScheduleInterval int    `yaml:"schedule_interval"`
+					SnapshotInterval int    `yaml:"snapshot_interval"`
+					PlacementPolicy  string `yaml:"placement_policy"`
+				}{
+					Role:            "master",
+					PlacementPolicy: "first-fit",
+				},
+				Quota: struct {
+					OnlinePercent      float64 `yaml:"online_percent"`
+					BatchPercent       float64 `yaml:"batch_percent"`
+					Borrowing          bool    `yaml:"borrowing"`
+					BorrowLimit        float64 `yaml:"borrow_limit"`
+					ReclaimMode        string  `yaml:"reclaim_mode"`
+					ReclaimGracePeriod int     `yaml:"reclaim_grace_period"`
 				}{
 					OnlinePercent: 0.7,
 					BatchPercent:  0.3,
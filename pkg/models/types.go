@@ -17,20 +17,70 @@ type GPU struct {
 	NodeID      string    `json:"node_id"`
 	DeviceIndex int       `json:"device_index"`
 	Model       string    `json:"model"`
-	Memory      int64     `json:"memory"`
+	Memory      int64     `json:"memory"`                       // MiB
+	ComputeCap  string    `json:"compute_capability,omitempty"` // e.g. "8.0"
 	Status      GPUStatus `json:"status"`
 	CurrentTask *string   `json:"current_task,omitempty"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Links maps peer device index to interconnect type as reported by
+	// `nvidia-smi topo -m` (e.g. "NV2", "PIX", "PHB", "SYS")
+	Links map[int]string `json:"links,omitempty"`
+
+	// Tenants are the tasks sharing the GPU, which is busy while it has
+	// any but has no CurrentTask
+	Tenants []GPUTenant `json:"tenants,omitempty"`
+}
+
+// GPUShare is the slice of a shared GPU a task asks for, as a share of its
+// compute, an amount of its memory, or both
+type GPUShare struct {
+	Percent  int   `json:"percent,omitempty"` // 1-99
+	MemoryMB int64 `json:"memory_mb,omitempty"`
 }
 
-// Priority represents task priority
+// GPUTenant is a task running on a slice of a shared GPU
+type GPUTenant struct {
+	TaskID   string `json:"task_id"`
+	Percent  int    `json:"percent"`
+	MemoryMB int64  `json:"memory_mb"`
+}
+
+// Priority is the name of a task's priority class
 type Priority string
 
+// Names of the default priority classes
 const (
 	PriorityHigh Priority = "high"
 	PriorityLow  Priority = "low"
 )
 
+// QuotaPool is the global quota bucket a priority class draws from
+type QuotaPool string
+
+const (
+	QuotaPoolOnline QuotaPool = "online"
+	QuotaPoolBatch  QuotaPool = "batch"
+)
+
+// PriorityClass is a named priority level tasks are submitted with
+type PriorityClass struct {
+	Name        Priority  `json:"name"`
+	Value       int       `json:"value"` // higher values are scheduled first
+	Pool        QuotaPool `json:"pool"`
+	Preempt     bool      `json:"preempt"`     // may preempt preemptible tasks of lower classes
+	Preemptible bool      `json:"preemptible"` // may be preempted by higher classes
+}
+
+// DefaultPriorityClasses returns the classes used when none are configured:
+// online "high" tasks that preempt batch "low" tasks
+func DefaultPriorityClasses() []PriorityClass {
+	return []PriorityClass{
+		{Name: PriorityHigh, Value: 1000, Pool: QuotaPoolOnline, Preempt: true},
+		{Name: PriorityLow, Value: 100, Pool: QuotaPoolBatch, Preemptible: true},
+	}
+}
+
 // TaskStatus represents the status of a task
 type TaskStatus string
 
@@ -39,22 +89,355 @@ const (
 	TaskStatusRunning TaskStatus = "running"
 	TaskStatusSuccess TaskStatus = "success"
 	TaskStatusFailed  TaskStatus = "failed"
+	// TaskStatusWaiting tasks wait for their upstream tasks to finish
+	TaskStatusWaiting TaskStatus = "waiting"
+	// TaskStatusSkipped tasks will never run because a dependency
+	// condition can no longer be met
+	TaskStatusSkipped TaskStatus = "skipped"
+	// TaskStatusCancelled tasks were cancelled before they finished
+	TaskStatusCancelled TaskStatus = "cancelled"
+	// TaskStatusTimeout tasks were killed for exceeding their max runtime
+	// or deadline
+	TaskStatusTimeout TaskStatus = "timeout"
+	// TaskStatusExpired tasks reached their deadline before they started
+	TaskStatusExpired TaskStatus = "expired"
+)
+
+// IsFinal reports whether a task in this status will never run again
+func (s TaskStatus) IsFinal() bool {
+	switch s {
+	case TaskStatusSuccess, TaskStatusFailed, TaskStatusSkipped, TaskStatusCancelled,
+		TaskStatusTimeout, TaskStatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFailure reports whether a task in this status finished without
+// succeeding on its own account
+func (s TaskStatus) IsFailure() bool {
+	switch s {
+	case TaskStatusFailed, TaskStatusTimeout, TaskStatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// TaskType represents how a task is launched
+type TaskType string
+
+const (
+	// TaskTypeSingle runs one process on a single agent
+	TaskTypeSingle TaskType = "single"
+	// TaskTypeDistributed runs one process on each of several agents,
+	// all started together (gang scheduling)
+	TaskTypeDistributed TaskType = "distributed"
+)
+
+// GangSpec describes the shape of a distributed task
+type GangSpec struct {
+	Nodes       int `json:"nodes"`
+	GPUsPerNode int `json:"gpus_per_node"`
+	MasterPort  int `json:"master_port,omitempty"`
+}
+
+// GangMember is the part of a distributed task running on one agent
+type GangMember struct {
+	Rank   int        `json:"rank"`
+	NodeID string     `json:"node_id"`
+	GPUs   []string   `json:"gpus"`
+	Status TaskStatus `json:"status"`
+}
+
+// StopRequest asks the agents running a task to stop it
+type StopRequest struct {
+	Reason      string    `json:"reason"`
+	GracePeriod int       `json:"grace_period"` // seconds between SIGTERM and SIGKILL
+	RequestedAt time.Time `json:"requested_at"`
+	RequestedBy string    `json:"requested_by,omitempty"`
+	Checkpoint  bool      `json:"checkpoint,omitempty"` // signal the task to checkpoint instead of terminating
+	Overdue     bool      `json:"overdue,omitempty"`    // the agent still runs the task past the stop deadline
+}
+
+// TaskReservation holds GPUs for a blocked task until they are expected
+// to be free (backfill scheduling)
+type TaskReservation struct {
+	GPUs           []string  `json:"gpus"`
+	EstimatedStart time.Time `json:"estimated_start"`
+}
+
+// SchedulingDiagnostics records why a pending task was not placed by the
+// last scheduling cycle that considered it. The GPU counts are worked out
+// against the cluster as it is when the diagnostics are read.
+type SchedulingDiagnostics struct {
+	LastAttempt   *time.Time     `json:"last_attempt,omitempty"`
+	QueuePosition int            `json:"queue_position"` // among pending tasks, in scheduling order
+	QueueLength   int            `json:"queue_length"`
+	Reason        string         `json:"reason,omitempty"`
+	QuotaExceeded bool           `json:"quota_exceeded,omitempty"`
+	NeededGPUs    int            `json:"needed_gpus"`
+	TotalGPUs     int            `json:"total_gpus"`
+	EligibleGPUs  int            `json:"eligible_gpus"`      // GPUs passing every filter
+	Rejected      map[string]int `json:"rejected,omitempty"` // filter -> GPUs it rejected
+	HeldGPUs      []string       `json:"-"`                  // GPUs held for an older blocked task
+}
+
+// LabelOperator is how a label expression compares a label
+type LabelOperator string
+
+const (
+	LabelOpIn           LabelOperator = "In"
+	LabelOpNotIn        LabelOperator = "NotIn"
+	LabelOpExists       LabelOperator = "Exists"
+	LabelOpDoesNotExist LabelOperator = "DoesNotExist"
+)
+
+// LabelExpression matches one label against a set of values
+type LabelExpression struct {
+	Key      string        `json:"key"`
+	Operator LabelOperator `json:"operator"`
+	Values   []string      `json:"values,omitempty"`
+}
+
+// NodeAffinityRule matches nodes whose labels meet all of its expressions
+type NodeAffinityRule struct {
+	Expressions []LabelExpression `json:"expressions"`
+	Required    bool              `json:"required,omitempty"` // otherwise matching nodes are only preferred
+	Weight      int               `json:"weight,omitempty"`   // preference of matching nodes
+}
+
+// TaskAffinityRule matches the topology domains, e.g. racks, running tasks
+// with the given labels
+type TaskAffinityRule struct {
+	Selector    map[string]string `json:"selector"`
+	TopologyKey string            `json:"topology_key,omitempty"` // node label grouping nodes, empty for the node itself
+	Required    bool              `json:"required,omitempty"`     // otherwise matching domains are only preferred or avoided
+	Weight      int               `json:"weight,omitempty"`
+}
+
+// Affinity steers a task towards or away from nodes and other tasks
+type Affinity struct {
+	Node     []NodeAffinityRule `json:"node,omitempty"`
+	Task     []TaskAffinityRule `json:"task,omitempty"`      // run near matching tasks
+	TaskAnti []TaskAffinityRule `json:"task_anti,omitempty"` // run away from matching tasks
+}
+
+// Taint keeps tasks off a node unless they tolerate it
+type Taint struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// TolerationOperator is how a toleration matches a taint's value
+type TolerationOperator string
+
+const (
+	TolerationOpEqual  TolerationOperator = "Equal"
+	TolerationOpExists TolerationOperator = "Exists" // any value
 )
 
+// Toleration lets a task run on nodes with matching taints
+type Toleration struct {
+	Key      string             `json:"key"`
+	Operator TolerationOperator `json:"operator,omitempty"` // Equal by default
+	Value    string             `json:"value,omitempty"`
+}
+
+// DependencyCondition decides which outcome of an upstream task lets a
+// downstream task run
+type DependencyCondition string
+
+const (
+	DependOnSuccess DependencyCondition = "on_success"
+	DependOnFailure DependencyCondition = "on_failure"
+	DependAlways    DependencyCondition = "always"
+)
+
+// Dependency is an edge from an upstream task of a workflow
+type Dependency struct {
+	TaskID    string              `json:"task_id"`
+	Condition DependencyCondition `json:"condition"`
+}
+
+// FailureClass classifies why a task attempt ended without succeeding
+type FailureClass string
+
+const (
+	// FailureExit attempts exited with a non-zero exit code
+	FailureExit FailureClass = "exit"
+	// FailureAgentLost attempts ran on an agent that stopped sending
+	// heartbeats
+	FailureAgentLost FailureClass = "agent_lost"
+	// FailurePreempted attempts were stopped to make room for other
+	// tasks
+	FailurePreempted FailureClass = "preempted"
+	// FailureGPUError attempts hit a CUDA, driver or hardware error
+	FailureGPUError FailureClass = "gpu_error"
+)
+
+// RetryPolicy decides whether and when a failed task runs again
+type RetryPolicy struct {
+	MaxAttempts int            `json:"max_attempts"`          // including the first attempt
+	Backoff     int            `json:"backoff,omitempty"`     // seconds before the first retry, doubled for each further retry
+	MaxBackoff  int            `json:"max_backoff,omitempty"` // seconds, 0 means uncapped
+	RetryOn     []FailureClass `json:"retry_on,omitempty"`    // failure classes to retry
+}
+
+// TaskAttempt records one run of a task
+type TaskAttempt struct {
+	Attempt    int          `json:"attempt"` // 1-based
+	Nodes      []string     `json:"nodes"`   // agents the attempt ran on
+	GPUs       []string     `json:"gpus"`
+	Status     TaskStatus   `json:"status"`
+	Failure    FailureClass `json:"failure,omitempty"`
+	ExitCode   *int         `json:"exit_code,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+}
+
+// DefaultTeam is the team tasks without a team are accounted to
+const DefaultTeam = "default"
+
+// TeamUsage records a team's recent GPU usage for fair-share scheduling
+type TeamUsage struct {
+	Team      string    `json:"team"`
+	GPUHours  float64   `json:"gpu_hours"` // decayed GPU-hours
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // Task represents a scheduling task
 type Task struct {
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name,omitempty"`
+	WorkflowID      string                 `json:"workflow_id,omitempty"`
+	DependsOn       []Dependency           `json:"depends_on,omitempty"`
+	ArrayID         string                 `json:"array_id,omitempty"`
+	ArrayIndex      int                    `json:"array_index,omitempty"`
+	ScheduleID      string                 `json:"schedule_id,omitempty"`
+	ReservationID   string                 `json:"reservation_id,omitempty"` // advance reservation whose GPUs the task runs on
+	Type            TaskType               `json:"type,omitempty"`
+	Team            string                 `json:"team,omitempty"`
+	Project         string                 `json:"project,omitempty"`
+	User            string                 `json:"user,omitempty"`
+	Priority        Priority               `json:"priority"`
+	GPUCount        int                    `json:"gpu_count"`
+	Gang            *GangSpec              `json:"gang,omitempty"`
+	GangMembers     []GangMember           `json:"gang_members,omitempty"`
+	GPUModel        *string                `json:"gpu_model,omitempty"`
+	GPUModels       []string               `json:"gpu_models,omitempty"` // acceptable models, most preferred first
+	MinMemoryMB     int64                  `json:"min_memory_mb,omitempty"`
+	MinComputeCap   string                 `json:"min_compute_capability,omitempty"`
+	GPUShare        *GPUShare              `json:"gpu_share,omitempty"`     // run on a slice of a shared GPU
+	CPU             float64                `json:"cpu,omitempty"`           // CPU cores on each node the task runs on
+	MemoryMB        int64                  `json:"memory_mb,omitempty"`     // host memory on each node the task runs on
+	Labels          map[string]string      `json:"labels,omitempty"`        // matched by other tasks' affinity rules
+	NodeSelector    map[string]string      `json:"node_selector,omitempty"` // node labels the task requires
+	Affinity        *Affinity              `json:"affinity,omitempty"`
+	Tolerations     []Toleration           `json:"tolerations,omitempty"` // node taints the task may run on
+	PlacementPolicy string                 `json:"placement_policy,omitempty"`
+	AllowCrossNode  bool                   `json:"allow_cross_node,omitempty"`
+	Command         string                 `json:"command"`
+	Env             map[string]string      `json:"env,omitempty"`
+	ExpectedRuntime int                    `json:"expected_runtime,omitempty"` // seconds
+	MaxRuntime      int                    `json:"max_runtime,omitempty"`      // seconds, 0 means unlimited
+	Deadline        *time.Time             `json:"deadline,omitempty"`         // the task is killed or expired after this time
+	Retry           *RetryPolicy           `json:"retry,omitempty"`
+	Attempts        []TaskAttempt          `json:"attempts,omitempty"`
+	RetryAt         *time.Time             `json:"retry_at,omitempty"` // a retried task waits in the queue until then
+	Status          TaskStatus             `json:"status"`
+	StatusReason    string                 `json:"status_reason,omitempty"`
+	StopRequest     *StopRequest           `json:"stop_request,omitempty"`
+	PreemptionCount int                    `json:"preemption_count,omitempty"`
+	Borrowed        bool                   `json:"borrowed,omitempty"` // batch task running on borrowed online quota
+	AssignedGPUs    []string               `json:"assigned_gpus,omitempty"`
+	AssignedModel   string                 `json:"assigned_model,omitempty"` // model of the assigned GPUs, if they share one
+	Reservation     *TaskReservation       `json:"reservation,omitempty"`
+	Diagnostics     *SchedulingDiagnostics `json:"-"` // why the task is still pending, not persisted
+	TopologyScore   *float64               `json:"topology_score,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	QueuedAt        *time.Time             `json:"queued_at,omitempty"` // when the task last entered the queue
+	StartedAt       *time.Time             `json:"started_at,omitempty"`
+	FinishedAt      *time.Time             `json:"finished_at,omitempty"`
+	Error           *string                `json:"error,omitempty"`
+}
+
+// IsDistributed reports whether the task is gang scheduled across agents
+func (t *Task) IsDistributed() bool {
+	return t.Type == TaskTypeDistributed && t.Gang != nil
+}
+
+// WorkflowStatus represents the aggregated status of a workflow
+type WorkflowStatus string
+
+const (
+	WorkflowStatusPending WorkflowStatus = "pending"
+	WorkflowStatusRunning WorkflowStatus = "running"
+	WorkflowStatusSuccess WorkflowStatus = "success"
+	WorkflowStatusFailed  WorkflowStatus = "failed"
+)
+
+// Workflow is a DAG of tasks submitted together
+type Workflow struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Tasks     []string  `json:"tasks"` // task IDs in submission order
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskArray is a set of near-identical tasks submitted and managed as one
+// unit, e.g. a parameter sweep
+type TaskArray struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	Tasks       []string  `json:"tasks"`                  // task IDs in index order
+	MaxParallel int       `json:"max_parallel,omitempty"` // 0 means unlimited
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ConcurrencyPolicy decides what a schedule does when a run is due while
+// an earlier run hasn't finished
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow starts the new run alongside the earlier ones
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyForbid skips the new run
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+	// ConcurrencyReplace cancels the earlier runs and starts the new one
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+)
+
+// ScheduledTask creates tasks from a template on a cron schedule, or once
+// at a given time if it has no cron expression
+type ScheduledTask struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name,omitempty"`
+	Cron              string            `json:"cron,omitempty"`
+	NotBefore         *time.Time        `json:"not_before,omitempty"` // no run starts earlier
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy"`
+	Paused            bool              `json:"paused,omitempty"`
+	Template          Task              `json:"template"`
+	NextRunAt         *time.Time        `json:"next_run_at,omitempty"` // nil once a one-shot schedule ran
+	LastRunAt         *time.Time        `json:"last_run_at,omitempty"`
+	Runs              []string          `json:"runs,omitempty"` // task IDs of past runs, oldest first
+	CreatedAt         time.Time         `json:"created_at"`
+}
+
+// Reservation books GPUs for a time window, e.g. a launch or a demo.
+// During the window only tasks of the reservation may use its GPUs.
+type Reservation struct {
 	ID           string            `json:"id"`
-	Priority     Priority          `json:"priority"`
+	Owner        string            `json:"owner"`
 	GPUCount     int               `json:"gpu_count"`
-	GPUModel     *string           `json:"gpu_model,omitempty"`
-	Command      string            `json:"command"`
-	Env          map[string]string `json:"env,omitempty"`
-	Status       TaskStatus        `json:"status"`
-	AssignedGPUs []string          `json:"assigned_gpus,omitempty"`
+	GPUModel     string            `json:"gpu_model,omitempty"`
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	GPUs         []string          `json:"gpus"` // GPUs set aside when the reservation was made
 	CreatedAt    time.Time         `json:"created_at"`
-	StartedAt    *time.Time        `json:"started_at,omitempty"`
-	FinishedAt   *time.Time        `json:"finished_at,omitempty"`
-	Error        *string           `json:"error,omitempty"`
 }
 
 // AgentStatus represents the status of an agent
@@ -65,20 +448,64 @@ const (
 	AgentStatusOffline AgentStatus = "offline"
 )
 
+// MaintenanceState is how far an agent was taken out of service
+type MaintenanceState string
+
+const (
+	MaintenanceCordoned MaintenanceState = "cordoned" // no new tasks
+	MaintenanceDraining MaintenanceState = "draining" // no new tasks, running ones are moved off
+	MaintenanceDrained  MaintenanceState = "drained"  // no tasks left
+)
+
 // Agent represents a GPU node agent
 type Agent struct {
-	ID            string      `json:"id"`
-	Address       string      `json:"address"`
-	GPUs          []GPU       `json:"gpus"`
-	LastHeartbeat time.Time   `json:"last_heartbeat"`
-	Status        AgentStatus `json:"status"`
+	ID            string            `json:"id"`
+	Address       string            `json:"address"`
+	Labels        map[string]string `json:"labels,omitempty"`    // node properties such as rack or zone
+	CPUCores      int               `json:"cpu_cores,omitempty"` // CPU cores tasks may use, 0 if unknown
+	MemoryMB      int64             `json:"memory_mb,omitempty"` // host memory tasks may use, 0 if unknown
+	Taints        []Taint           `json:"taints,omitempty"`
+	GPUs          []GPU             `json:"gpus"`
+	LastHeartbeat time.Time         `json:"last_heartbeat"`
+	RunningTasks  []string          `json:"running_tasks,omitempty"` // tasks the agent ran at its last heartbeat
+	Status        AgentStatus       `json:"status"`
+	Maintenance   MaintenanceState  `json:"maintenance,omitempty"`
+	DrainDeadline *time.Time        `json:"drain_deadline,omitempty"` // running tasks are stopped after it
 }
 
 // Quota represents resource quota configuration
 type Quota struct {
-	TotalGPUs   int `json:"total_gpus"`
-	OnlineQuota int `json:"online_quota"`
-	BatchQuota  int `json:"batch_quota"`
-	OnlineUsed  int `json:"online_used"`
-	BatchUsed   int `json:"batch_used"`
+	TotalGPUs     int `json:"total_gpus"`
+	OnlineQuota   int `json:"online_quota"`
+	BatchQuota    int `json:"batch_quota"`
+	OnlineUsed    int `json:"online_used"`
+	BatchUsed     int `json:"batch_used"`
+	BatchBorrowed int `json:"batch_borrowed"` // online quota lent to batch tasks
+}
+
+// QuotaLimit bounds the GPUs one priority class may use
+type QuotaLimit struct {
+	Min int `json:"min,omitempty"` // guaranteed GPUs, held back from siblings
+	Max int `json:"max,omitempty"` // 0 means unlimited
+}
+
+// QuotaSpec holds the limits of one level of the quota hierarchy
+type QuotaSpec struct {
+	Limits map[Priority]QuotaLimit            `json:"limits,omitempty"`
+	Models map[string]map[Priority]QuotaLimit `json:"models,omitempty"` // GPU model -> limits
+}
+
+// OrgQuota is the quota of an organization, shared by its teams
+type OrgQuota struct {
+	Organization string `json:"organization"`
+	QuotaSpec
+}
+
+// TeamQuota is the quota of a team, optionally part of an organization
+// and split further between the team's projects
+type TeamQuota struct {
+	Team         string `json:"team"`
+	Organization string `json:"organization,omitempty"`
+	QuotaSpec
+	Projects map[string]QuotaSpec `json:"projects,omitempty"`
 }
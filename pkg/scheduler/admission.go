@@ -0,0 +1,257 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// ErrUnsatisfiable is returned for tasks the cluster's registered capacity
+// could never run
+var ErrUnsatisfiable = errors.New("task can never be scheduled")
+
+// Admission modes
+const (
+	// AdmissionReject rejects tasks that can never be scheduled
+	AdmissionReject = "reject"
+	// AdmissionWarn accepts them with a warning
+	AdmissionWarn = "warn"
+	// AdmissionOff accepts every task unchecked
+	AdmissionOff = "off"
+)
+
+// AdmissionConfig controls the checks new tasks go through before they are
+// queued
+type AdmissionConfig struct {
+	// Mode is AdmissionReject, AdmissionWarn or AdmissionOff, AdmissionOff if
+	// empty
+	Mode string
+}
+
+// SetAdmission configures admission control
+func (e *Engine) SetAdmission(cfg AdmissionConfig) {
+	e.admission = cfg
+}
+
+// Admit checks a new task against the capacity registered in the cluster:
+// the GPU models, the GPUs of the largest nodes and the quota ceilings.
+// Tasks that could never run are rejected, unless the mode is warn or the
+// submitter expects the capacity to join later, in which case the problems
+// come back as warnings. With no GPUs registered, e.g. before the agents
+// reconnect after a restart, the capacity is unknown and every task is
+// admitted.
+func (e *Engine) Admit(task *models.Task, expectCapacity bool) ([]string, error) {
+	if e.admission.Mode == "" || e.admission.Mode == AdmissionOff {
+		return nil, nil
+	}
+
+	state := e.state.GetState()
+	state.mu.RLock()
+	problems := e.capacityProblemsLocked(task)
+	state.mu.RUnlock()
+
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	if e.admission.Mode == AdmissionWarn || expectCapacity {
+		return problems, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsatisfiable, strings.Join(problems, "; "))
+}
+
+// gpuCapacity counts the GPUs a task could ever get, in total and per node
+type gpuCapacity struct {
+	gpus    int
+	perNode map[string]int
+}
+
+// shortfall describes how the GPUs fall short of the task's shape, or
+// returns "" if they would do
+func (c *gpuCapacity) shortfall(task *models.Task) string {
+	switch {
+	case c.gpus < task.GPUCount:
+		return fmt.Sprintf("needs %d GPUs, %d registered GPUs meet its requirements", task.GPUCount, c.gpus)
+
+	case task.IsDistributed():
+		nodes := 0
+		for _, count := range c.perNode {
+			if count >= task.Gang.GPUsPerNode {
+				nodes++
+			}
+		}
+		if nodes < task.Gang.Nodes {
+			return fmt.Sprintf("needs %d nodes with %d GPUs each, %d registered nodes have them",
+				task.Gang.Nodes, task.Gang.GPUsPerNode, nodes)
+		}
+
+	case !task.AllowCrossNode:
+		largest := 0
+		for _, count := range c.perNode {
+			largest = max(largest, count)
+		}
+		if largest < task.GPUCount {
+			return fmt.Sprintf("needs %d GPUs on one node, the largest registered node has %d", task.GPUCount, largest)
+		}
+	}
+	return ""
+}
+
+// capacityProblemsLocked lists the reasons the registered capacity could
+// never run a task (must hold lock)
+func (e *Engine) capacityProblemsLocked(task *models.Task) []string {
+	state := e.state.state
+	if len(state.GPUs) == 0 {
+		return nil
+	}
+	problems := make([]string, 0)
+
+	// Tasks accepting several models get GPUs of one of them, so their
+	// capacity is counted per model
+	registered := make(map[string]bool)
+	capacities := make(map[string]*gpuCapacity)
+	rejected := make(map[string]int)
+	for _, gpu := range state.GPUs {
+		registered[gpu.Model] = true
+		if filter := e.staticFilterLocked(task, gpu); filter != "" {
+			rejected[filter]++
+			continue
+		}
+
+		key := ""
+		if len(task.GPUModels) > 0 {
+			key = gpu.Model
+		}
+		capacity, exists := capacities[key]
+		if !exists {
+			capacity = &gpuCapacity{perNode: make(map[string]int)}
+			capacities[key] = capacity
+		}
+		capacity.gpus++
+		capacity.perNode[gpu.NodeID]++
+	}
+
+	if missing := missingModels(task, registered); missing != "" {
+		problems = append(problems, missing)
+	}
+
+	if len(capacities) == 0 {
+		problems = append(problems, fmt.Sprintf("none of the %d registered GPUs meets its requirements (rejected: %s)",
+			len(state.GPUs), describeRejections(rejected)))
+	} else if shortfall := bestShortfall(task, capacities); shortfall != "" {
+		problems = append(problems, shortfall)
+	}
+
+	return append(problems, e.quotaCeilingsLocked(task)...)
+}
+
+// staticFilterLocked returns the first filter keeping a task off a GPU for
+// good: its requirements, GPU sharing, and the capacity and labels of the
+// GPU's node. Offline GPUs, maintenance, taints and task affinity come and
+// go (must hold lock).
+func (e *Engine) staticFilterLocked(task *models.Task, gpu *models.GPU) string {
+	if filter := unmetRequirement(task, gpu); filter != "" {
+		return filter
+	}
+	if task.GPUShare != nil && !e.sharedModels[gpu.Model] {
+		return FilterNotShared
+	}
+	if agent, exists := e.state.state.Agents[gpu.NodeID]; exists && !hostHas(agent, nil, task) {
+		return FilterResources
+	}
+
+	labels := e.nodeLabelsLocked(gpu.NodeID)
+	if !matchesSelector(labels, task.NodeSelector) {
+		return FilterNode
+	}
+	if task.Affinity != nil {
+		for _, rule := range task.Affinity.Node {
+			if rule.Required && !matchesExpressions(labels, rule.Expressions) {
+				return FilterNode
+			}
+		}
+	}
+	return ""
+}
+
+// missingModels describes the models the task asks for that no registered
+// GPU has, or returns "" if it may get one. A task accepting several models
+// only needs one of them.
+func missingModels(task *models.Task, registered map[string]bool) string {
+	var wanted []string
+	switch {
+	case task.GPUModel != nil:
+		wanted = []string{*task.GPUModel}
+	case len(task.GPUModels) > 0:
+		wanted = task.GPUModels
+	default:
+		return ""
+	}
+	for _, model := range wanted {
+		if registered[model] {
+			return ""
+		}
+	}
+
+	available := make([]string, 0, len(registered))
+	for model := range registered {
+		available = append(available, model)
+	}
+	sort.Strings(available)
+	if len(available) == 0 {
+		return fmt.Sprintf("no registered GPU is a %s", strings.Join(wanted, " or "))
+	}
+	return fmt.Sprintf("no registered GPU is a %s (registered models: %s)",
+		strings.Join(wanted, " or "), strings.Join(available, ", "))
+}
+
+// bestShortfall returns "" if the GPUs of any model would do, or how the
+// largest capacity falls short otherwise
+func bestShortfall(task *models.Task, capacities map[string]*gpuCapacity) string {
+	var largest *gpuCapacity
+	for _, capacity := range capacities {
+		if capacity.shortfall(task) == "" {
+			return ""
+		}
+		if largest == nil || capacity.gpus > largest.gpus {
+			largest = capacity
+		}
+	}
+	return largest.shortfall(task)
+}
+
+// quotaCeilingsLocked lists the quota limits a task's GPUs exceed on their
+// own, with no other task running (must hold lock)
+func (e *Engine) quotaCeilingsLocked(task *models.Task) []string {
+	state := e.state.state
+	problems := make([]string, 0)
+
+	quota := state.Quota
+	if e.poolOf(task) == models.QuotaPoolOnline {
+		if task.GPUCount > quota.OnlineQuota {
+			problems = append(problems, fmt.Sprintf("needs %d GPUs, the online quota is %d",
+				task.GPUCount, quota.OnlineQuota))
+		}
+	} else if ceiling := quota.BatchQuota + e.borrowLimit(quota); task.GPUCount > ceiling {
+		problems = append(problems, fmt.Sprintf("needs %d GPUs, the batch quota allows at most %d",
+			task.GPUCount, ceiling))
+	}
+
+	for _, node := range quotaPath(state, task) {
+		if limit := node.limit(task.Priority, "").Max; limit > 0 && task.GPUCount > limit {
+			problems = append(problems, fmt.Sprintf("needs %d GPUs, %s %s allows at most %d %s GPUs",
+				task.GPUCount, node.level, node.name, limit, task.Priority))
+		}
+		if task.GPUModel == nil {
+			continue
+		}
+		if limit := node.limit(task.Priority, *task.GPUModel).Max; limit > 0 && task.GPUCount > limit {
+			problems = append(problems, fmt.Sprintf("needs %d GPUs, %s %s allows at most %d %s %s GPUs",
+				task.GPUCount, node.level, node.name, limit, task.Priority, *task.GPUModel))
+		}
+	}
+
+	return problems
+}
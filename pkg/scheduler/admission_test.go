@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestAdmitRejectsUnsatisfiableTasks(t *testing.T) {
+	a100, h100 := "A100", "H100"
+
+	tests := []struct {
+		name    string
+		task    *models.Task
+		problem string // expected in the error, "" if the task is admitted
+	}{
+		{
+			name: "fits",
+			task: &models.Task{Priority: models.PriorityHigh, GPUCount: 4, GPUModel: &a100},
+		},
+		{
+			name:    "unknown model",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 1, GPUModel: &h100},
+			problem: "no registered GPU is a H100 (registered models: A100, V100)",
+		},
+		{
+			name:    "more GPUs of the model than registered",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 5, GPUModel: &a100, AllowCrossNode: true},
+			problem: "needs 5 GPUs, 4 registered GPUs meet its requirements",
+		},
+		{
+			name:    "accepted models counted apart",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 5, GPUModels: []string{"A100", "V100"}, AllowCrossNode: true},
+			problem: "needs 5 GPUs, 4 registered GPUs meet its requirements",
+		},
+		{
+			name: "across nodes",
+			task: &models.Task{Priority: models.PriorityHigh, GPUCount: 5, AllowCrossNode: true},
+		},
+		{
+			name:    "larger than any node",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 5},
+			problem: "needs 5 GPUs on one node, the largest registered node has 4",
+		},
+		{
+			name:    "memory no GPU has",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 1, MinMemoryMB: 100000},
+			problem: "none of the 8 registered GPUs meets its requirements (rejected: memory 8)",
+		},
+		{
+			name:    "node selector no node matches",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 1, NodeSelector: map[string]string{"zone": "b"}},
+			problem: "rejected: node_constraint 8",
+		},
+		{
+			name:    "gang wider than the cluster",
+			task:    newGangTask("gang", 3, 1),
+			problem: "needs 3 nodes with 1 GPUs each, 2 registered nodes have them",
+		},
+		{
+			name:    "beyond the online quota",
+			task:    &models.Task{Priority: models.PriorityHigh, GPUCount: 6, AllowCrossNode: true},
+			problem: "needs 6 GPUs, the online quota is 5",
+		},
+		{
+			name:    "beyond the batch quota",
+			task:    &models.Task{Priority: models.PriorityLow, GPUCount: 3},
+			problem: "needs 3 GPUs, the batch quota allows at most 2",
+		},
+		{
+			name:    "beyond the team max",
+			task:    newQuotaTask("team", "vision", "", 2),
+			problem: "needs 2 GPUs, team vision allows at most 1 low GPUs",
+		},
+	}
+
+	engine, stateManager := newTestEngine(t, newMixedCluster())
+	engine.SetAdmission(AdmissionConfig{Mode: AdmissionReject})
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team:      "vision",
+		QuotaSpec: models.QuotaSpec{Limits: batchLimit(0, 1)},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := engine.Admit(tt.task, false)
+			if len(warnings) > 0 {
+				t.Errorf("Expected no warnings in reject mode, got %v", warnings)
+			}
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("Expected the task to be admitted, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnsatisfiable) {
+				t.Fatalf("Expected ErrUnsatisfiable, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("Expected %q in %q", tt.problem, err.Error())
+			}
+		})
+	}
+}
+
+func TestAdmitCountsBorrowableQuota(t *testing.T) {
+	engine, _ := newTestEngine(t, newMixedCluster())
+	engine.SetAdmission(AdmissionConfig{Mode: AdmissionReject})
+	task := &models.Task{Priority: models.PriorityLow, GPUCount: 4, AllowCrossNode: true}
+
+	if _, err := engine.Admit(task, false); !errors.Is(err, ErrUnsatisfiable) {
+		t.Fatalf("Expected the batch quota to reject the task, got %v", err)
+	}
+
+	engine.SetBorrowing(BorrowingConfig{Enabled: true, Limit: 0.5})
+	if _, err := engine.Admit(task, false); err != nil {
+		t.Errorf("Expected the task to fit with borrowed quota, got %v", err)
+	}
+}
+
+func TestAdmitWarns(t *testing.T) {
+	engine, _ := newTestEngine(t, newMixedCluster())
+	engine.SetAdmission(AdmissionConfig{Mode: AdmissionReject})
+	h100 := "H100"
+	task := &models.Task{Priority: models.PriorityHigh, GPUCount: 8, GPUModel: &h100}
+
+	// Capacity the submitter expects to join later
+	warnings, err := engine.Admit(task, true)
+	if err != nil {
+		t.Fatalf("Expected the task to be accepted, got %v", err)
+	}
+	if len(warnings) != 3 {
+		t.Errorf("Expected model, count and quota warnings, got %v", warnings)
+	}
+
+	engine.SetAdmission(AdmissionConfig{Mode: AdmissionWarn})
+	if warnings, err := engine.Admit(task, false); err != nil || len(warnings) == 0 {
+		t.Errorf("Expected warnings in warn mode, got %v, %v", warnings, err)
+	}
+
+	// Admission is off unless configured
+	for _, mode := range []string{AdmissionOff, ""} {
+		engine.SetAdmission(AdmissionConfig{Mode: mode})
+		if warnings, err := engine.Admit(task, false); err != nil || len(warnings) > 0 {
+			t.Errorf("Expected no checks with admission mode %q, got %v, %v", mode, warnings, err)
+		}
+	}
+}
+
+func TestAdmitWithoutRegisteredGPUs(t *testing.T) {
+	engine, _ := newTestEngine(t, nil)
+	engine.SetAdmission(AdmissionConfig{Mode: AdmissionReject})
+
+	// Before agents reconnect the capacity is unknown
+	h100 := "H100"
+	task := &models.Task{Priority: models.PriorityHigh, GPUCount: 64, GPUModel: &h100}
+	if warnings, err := engine.Admit(task, false); err != nil || len(warnings) > 0 {
+		t.Errorf("Expected the task to be admitted unchecked, got %v, %v", warnings, err)
+	}
+}
@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"slices"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// nodeFit is the outcome of a task's node selector and affinity rules:
+// the nodes it may run on and how much it prefers each
+type nodeFit struct {
+	allowed map[string]bool
+	scores  map[string]float64
+}
+
+// allows reports whether the task may run on a node
+func (f *nodeFit) allows(nodeID string) bool {
+	return f == nil || f.allowed[nodeID]
+}
+
+// policy adds the task's node preferences to a placement policy
+func (f *nodeFit) policy(base PlacementPolicy) PlacementPolicy {
+	if f == nil || len(f.scores) == 0 {
+		return base
+	}
+	return affinityPolicy{PlacementPolicy: base, scores: f.scores}
+}
+
+// affinityPolicy ranks preferred nodes above the ones its base policy
+// would pick
+type affinityPolicy struct {
+	PlacementPolicy
+	scores map[string]float64
+}
+
+func (p affinityPolicy) ScoreNode(load *NodeLoad) float64 {
+	return p.PlacementPolicy.ScoreNode(load) + p.scores[load.NodeID]
+}
+
+// nodeFitLocked evaluates the task's node selector and affinity rules
+// against every node, or returns nil if the task has none (must hold lock)
+func (e *Engine) nodeFitLocked(task *models.Task) *nodeFit {
+	affinity := task.Affinity
+	if len(task.NodeSelector) == 0 && affinity == nil {
+		return nil
+	}
+	if affinity == nil {
+		affinity = &models.Affinity{}
+	}
+
+	state := e.state.state
+	fit := &nodeFit{
+		allowed: make(map[string]bool),
+		scores:  make(map[string]float64),
+	}
+
+	nodes := make(map[string]map[string]string, len(state.gpuIndex.loads))
+	for nodeID := range state.gpuIndex.loads {
+		nodes[nodeID] = e.nodeLabelsLocked(nodeID)
+	}
+
+	// Topology domains running tasks matched by each task rule
+	near := make([]map[string]bool, len(affinity.Task))
+	for i, rule := range affinity.Task {
+		near[i] = e.taskDomainsLocked(task, rule, nodes)
+	}
+	away := make([]map[string]bool, len(affinity.TaskAnti))
+	for i, rule := range affinity.TaskAnti {
+		away[i] = e.taskDomainsLocked(task, rule, nodes)
+	}
+
+	for nodeID, labels := range nodes {
+		if !matchesSelector(labels, task.NodeSelector) {
+			continue
+		}
+
+		allowed, score := true, 0.0
+		for _, rule := range affinity.Node {
+			matched := matchesExpressions(labels, rule.Expressions)
+			switch {
+			case rule.Required && !matched:
+				allowed = false
+			case !rule.Required && matched:
+				score += ruleWeight(rule.Weight)
+			}
+		}
+		for i, rule := range affinity.Task {
+			domain, inDomain := domainOf(nodeID, labels, rule.TopologyKey)
+			matched := inDomain && near[i][domain]
+			switch {
+			case rule.Required && !matched:
+				// The first of a group of tasks that should run together
+				// may go anywhere
+				if len(near[i]) > 0 || !matchesSelector(task.Labels, rule.Selector) {
+					allowed = false
+				}
+			case !rule.Required && matched:
+				score += ruleWeight(rule.Weight)
+			}
+		}
+		for i, rule := range affinity.TaskAnti {
+			domain, inDomain := domainOf(nodeID, labels, rule.TopologyKey)
+			if !inDomain || !away[i][domain] {
+				continue
+			}
+			if rule.Required {
+				allowed = false
+			} else {
+				score -= ruleWeight(rule.Weight)
+			}
+		}
+
+		if allowed {
+			fit.allowed[nodeID] = true
+			fit.scores[nodeID] = score
+		}
+	}
+
+	return fit
+}
+
+// nodeLabelsLocked returns the labels the node's agent advertised (must
+// hold lock)
+func (e *Engine) nodeLabelsLocked(nodeID string) map[string]string {
+	if agent, exists := e.state.state.Agents[nodeID]; exists {
+		return agent.Labels
+	}
+	return nil
+}
+
+// taskDomainsLocked returns the topology domains of the nodes running tasks
+// matched by a task affinity rule (must hold lock)
+func (e *Engine) taskDomainsLocked(task *models.Task, rule models.TaskAffinityRule, nodes map[string]map[string]string) map[string]bool {
+	state := e.state.state
+	domains := make(map[string]bool)
+
+	for _, other := range state.Tasks {
+		if other.ID == task.ID || other.Status != models.TaskStatusRunning {
+			continue
+		}
+		if !matchesSelector(other.Labels, rule.Selector) {
+			continue
+		}
+		for _, gpuID := range other.AssignedGPUs {
+			gpu, exists := state.GPUs[gpuID]
+			if !exists {
+				continue
+			}
+			if domain, ok := domainOf(gpu.NodeID, nodes[gpu.NodeID], rule.TopologyKey); ok {
+				domains[domain] = true
+			}
+		}
+	}
+	return domains
+}
+
+// domainOf returns the topology domain a node belongs to: the value of
+// the topology key label, or the node itself without a key. Nodes lacking
+// the label belong to no domain.
+func domainOf(nodeID string, labels map[string]string, topologyKey string) (string, bool) {
+	if topologyKey == "" {
+		return nodeID, true
+	}
+	domain, exists := labels[topologyKey]
+	return domain, exists
+}
+
+// ruleWeight returns the score of a preferred rule, 1 unless set
+func ruleWeight(weight int) float64 {
+	if weight <= 0 {
+		return 1
+	}
+	return float64(weight)
+}
+
+// matchesSelector reports whether labels contain every key/value pair of
+// the selector
+func matchesSelector(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesExpressions reports whether labels meet every expression
+func matchesExpressions(labels map[string]string, expressions []models.LabelExpression) bool {
+	for _, expr := range expressions {
+		value, exists := labels[expr.Key]
+		switch expr.Operator {
+		case models.LabelOpIn:
+			if !exists || !slices.Contains(expr.Values, value) {
+				return false
+			}
+		case models.LabelOpNotIn:
+			if exists && slices.Contains(expr.Values, value) {
+				return false
+			}
+		case models.LabelOpExists:
+			if !exists {
+				return false
+			}
+		case models.LabelOpDoesNotExist:
+			if exists {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
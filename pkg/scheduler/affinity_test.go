@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// newLabeledEngine returns an engine over nodes with 2 idle GPUs each,
+// whose agents advertise the given labels
+func newLabeledEngine(t *testing.T, labels map[string]map[string]string) (*Engine, *StateManager) {
+	t.Helper()
+
+	nodes := make([]string, 0, len(labels))
+	busy := make([]int, 0, len(labels))
+	for nodeID := range labels {
+		nodes = append(nodes, nodeID)
+		busy = append(busy, 0)
+	}
+	engine, stateManager := newTestEngine(t, newTestCluster(nodes, 2, busy))
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 100
+	for nodeID, nodeLabels := range labels {
+		state.Agents[nodeID] = &models.Agent{ID: nodeID, Labels: nodeLabels, Status: models.AgentStatusOnline}
+	}
+	return engine, stateManager
+}
+
+func newAffinityTask(id string) *models.Task {
+	return &models.Task{
+		ID:       id,
+		Priority: models.PriorityHigh,
+		GPUCount: 1,
+		Command:  "serve",
+		Status:   models.TaskStatusPending,
+	}
+}
+
+// nodeOf returns the node a scheduled task runs on
+func nodeOf(stateManager *StateManager, task *models.Task) string {
+	return stateManager.GetState().GPUs[task.AssignedGPUs[0]].NodeID
+}
+
+func TestMatchesExpressions(t *testing.T) {
+	labels := map[string]string{"rack": "r1", "nvme": "true"}
+
+	tests := []struct {
+		expr models.LabelExpression
+		want bool
+	}{
+		{models.LabelExpression{Key: "rack", Operator: models.LabelOpIn, Values: []string{"r1", "r2"}}, true},
+		{models.LabelExpression{Key: "rack", Operator: models.LabelOpIn, Values: []string{"r2"}}, false},
+		{models.LabelExpression{Key: "rack", Operator: models.LabelOpNotIn, Values: []string{"r2"}}, true},
+		{models.LabelExpression{Key: "zone", Operator: models.LabelOpNotIn, Values: []string{"a"}}, true},
+		{models.LabelExpression{Key: "nvme", Operator: models.LabelOpExists}, true},
+		{models.LabelExpression{Key: "ib", Operator: models.LabelOpExists}, false},
+		{models.LabelExpression{Key: "ib", Operator: models.LabelOpDoesNotExist}, true},
+		{models.LabelExpression{Key: "rack", Operator: "Gt", Values: []string{"1"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesExpressions(labels, []models.LabelExpression{tt.expr}); got != tt.want {
+			t.Errorf("matchesExpressions(%+v) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNodeSelectorAndAffinity(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": {"zone": "a", "infiniband": "true"},
+		"node-b": {"zone": "a"},
+		"node-c": {"zone": "b", "infiniband": "true", "nvme": "true"},
+	})
+
+	// Required: zone a with InfiniBand
+	selected := newAffinityTask("selected")
+	selected.NodeSelector = map[string]string{"zone": "a"}
+	selected.Affinity = &models.Affinity{Node: []models.NodeAffinityRule{{
+		Expressions: []models.LabelExpression{{Key: "infiniband", Operator: models.LabelOpExists}},
+		Required:    true,
+	}}}
+
+	// Preferred: local NVMe
+	preferred := newAffinityTask("preferred")
+	preferred.Affinity = &models.Affinity{Node: []models.NodeAffinityRule{{
+		Expressions: []models.LabelExpression{{Key: "nvme", Operator: models.LabelOpIn, Values: []string{"true"}}},
+		Weight:      10,
+	}}}
+
+	// No node matches
+	impossible := newAffinityTask("impossible")
+	impossible.NodeSelector = map[string]string{"zone": "c"}
+
+	for _, task := range []*models.Task{selected, preferred, impossible} {
+		stateManager.AddTask(task)
+	}
+	for _, task := range []*models.Task{selected, preferred} {
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", task.ID, err)
+		}
+	}
+
+	if node := nodeOf(stateManager, selected); node != "node-a" {
+		t.Errorf("Expected the selected task on node-a, got %s", node)
+	}
+	if node := nodeOf(stateManager, preferred); node != "node-c" {
+		t.Errorf("Expected the preferred task on node-c, got %s", node)
+	}
+	if err := engine.scheduleTask(impossible); err == nil {
+		t.Error("Expected no node to match the selector")
+	}
+}
+
+func TestTaskAntiAffinitySpreadsAcrossRacks(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": {"rack": "r1"},
+		"node-b": {"rack": "r1"},
+		"node-c": {"rack": "r2"},
+	})
+
+	racks := make(map[string]bool)
+	for _, id := range []string{"replica-1", "replica-2", "replica-3"} {
+		replica := newAffinityTask(id)
+		replica.Labels = map[string]string{"app": "svc-x"}
+		replica.Affinity = &models.Affinity{TaskAnti: []models.TaskAffinityRule{{
+			Selector:    map[string]string{"app": "svc-x"},
+			TopologyKey: "rack",
+			Required:    true,
+		}}}
+		stateManager.AddTask(replica)
+
+		err := engine.scheduleTask(replica)
+		if id == "replica-3" {
+			if err == nil {
+				t.Error("Expected no rack to be left for a third replica")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed to schedule %s: %v", id, err)
+		}
+		racks[stateManager.GetState().Agents[nodeOf(stateManager, replica)].Labels["rack"]] = true
+	}
+
+	if len(racks) != 2 {
+		t.Errorf("Expected the replicas on two racks, got %v", racks)
+	}
+}
+
+func TestTaskAffinityCoLocates(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": {},
+		"node-b": {},
+		"node-c": {},
+	})
+
+	colocate := &models.Affinity{Task: []models.TaskAffinityRule{{
+		Selector: map[string]string{"app": "cache"},
+		Required: true,
+	}}}
+
+	// The first task of the group may go anywhere
+	cache := newAffinityTask("cache")
+	cache.Labels = map[string]string{"app": "cache"}
+	cache.Affinity = colocate
+	worker := newAffinityTask("worker")
+	worker.Affinity = colocate
+	for _, task := range []*models.Task{cache, worker} {
+		stateManager.AddTask(task)
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", task.ID, err)
+		}
+	}
+
+	if nodeOf(stateManager, worker) != nodeOf(stateManager, cache) {
+		t.Errorf("Expected the worker next to the cache, got %s and %s",
+			nodeOf(stateManager, worker), nodeOf(stateManager, cache))
+	}
+
+	// The node is full now
+	another := newAffinityTask("worker-2")
+	another.Affinity = colocate
+	stateManager.AddTask(another)
+	if err := engine.scheduleTask(another); err == nil {
+		t.Error("Expected no room next to the cache")
+	}
+
+	// A task that isn't part of the group needs a running match
+	orphan := newAffinityTask("orphan")
+	orphan.Affinity = &models.Affinity{Task: []models.TaskAffinityRule{{
+		Selector: map[string]string{"app": "db"},
+		Required: true,
+	}}}
+	stateManager.AddTask(orphan)
+	if err := engine.scheduleTask(orphan); err == nil {
+		t.Error("Expected no node to run a matching task")
+	}
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// AgingConfig controls queue aging: the effective priority of a pending
+// task rises with its wait time, so tasks that keep failing to fit aren't
+// starved by newer ones
+type AgingConfig struct {
+	Enabled bool
+	// Interval is the wait after which a task gains another Step
+	Interval time.Duration
+	Step     int
+	// MaxBoost caps the priority a task can gain by waiting
+	MaxBoost int
+}
+
+// SetAging configures queue aging
+func (e *Engine) SetAging(cfg AgingConfig) {
+	e.aging = cfg
+}
+
+// WaitTime returns how long a pending task has been waiting in its queue,
+// zero for tasks that aren't pending
+func WaitTime(task *models.Task, now time.Time) time.Duration {
+	if task.Status != models.TaskStatusPending {
+		return 0
+	}
+	queuedAt := task.CreatedAt
+	if task.QueuedAt != nil {
+		queuedAt = *task.QueuedAt
+	}
+	if wait := now.Sub(queuedAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// EffectivePriority returns the value of a task's priority class plus the
+// priority it gained by waiting
+func (e *Engine) EffectivePriority(task *models.Task, now time.Time) int {
+	return e.classOf(task).Value + e.agingBoost(task, now)
+}
+
+// agingBoost returns the priority a task gained by waiting
+func (e *Engine) agingBoost(task *models.Task, now time.Time) int {
+	if !e.aging.Enabled || e.aging.Interval <= 0 {
+		return 0
+	}
+	steps := int(WaitTime(task, now) / e.aging.Interval)
+	return min(steps*e.aging.Step, e.aging.MaxBoost)
+}
+
+// agedOrder merges the queues into one order by effective priority, keeping
+// each queue's own order among its tasks of equal effective priority, and
+// splits it back into runs of tasks of the same class
+func (e *Engine) agedOrder(queues []classQueue, now time.Time) []classQueue {
+	type agedTask struct {
+		task     *models.Task
+		class    models.PriorityClass
+		priority int
+	}
+
+	tasks := make([]agedTask, 0)
+	for _, queue := range queues {
+		for _, task := range queue.tasks {
+			tasks = append(tasks, agedTask{
+				task:     task,
+				class:    queue.class,
+				priority: queue.class.Value + e.agingBoost(task, now),
+			})
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].priority > tasks[j].priority
+	})
+
+	runs := make([]classQueue, 0, len(queues))
+	for _, t := range tasks {
+		if n := len(runs); n > 0 && runs[n-1].class.Name == t.class.Name {
+			runs[n-1].tasks = append(runs[n-1].tasks, t.task)
+			continue
+		}
+		runs = append(runs, classQueue{class: t.class, tasks: []*models.Task{t.task}})
+	}
+	return runs
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestEffectivePriorityGrowsWithWait(t *testing.T) {
+	engine, _ := newTestEngine(t, newTestCluster([]string{"node-a"}, 1, []int{0}))
+	engine.SetPriorityClasses(testPriorityClasses())
+	engine.SetAging(AgingConfig{Enabled: true, Interval: time.Minute, Step: 100, MaxBoost: 250})
+
+	now := time.Now()
+	task := newClassTask("low-1", "low", now.Add(-time.Hour))
+
+	for wait, want := range map[time.Duration]int{
+		0:                 100,
+		59 * time.Second:  100,
+		time.Minute:       200,
+		150 * time.Second: 300,
+		time.Hour:         350,
+	} {
+		queuedAt := now.Add(-wait)
+		task.QueuedAt = &queuedAt
+		if got := engine.EffectivePriority(task, now); got != want {
+			t.Errorf("After waiting %v: expected effective priority %d, got %d", wait, want, got)
+		}
+	}
+
+	// Only pending tasks age
+	task.Status = models.TaskStatusRunning
+	if WaitTime(task, now) != 0 || engine.EffectivePriority(task, now) != 100 {
+		t.Error("Expected running tasks not to age")
+	}
+}
+
+func TestAgedTaskOvertakesHigherClass(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 1, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetPriorityClasses(testPriorityClasses())
+	engine.SetAging(AgingConfig{Enabled: true, Interval: time.Minute, Step: 100, MaxBoost: 500})
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 2
+
+	// The low task waited long enough to pass the normal class
+	now := time.Now()
+	starved := newClassTask("low-1", "low", now.Add(-10*time.Minute))
+	queuedAt := starved.CreatedAt
+	starved.QueuedAt = &queuedAt
+	fresh := newClassTask("normal-1", "normal", now)
+	for _, task := range []*models.Task{starved, fresh} {
+		stateManager.AddTask(task)
+	}
+
+	engine.runSchedulingCycle()
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	if starved.Status != models.TaskStatusRunning {
+		t.Errorf("Expected the aged task to run first, got %s", starved.Status)
+	}
+	if fresh.Status != models.TaskStatusPending {
+		t.Errorf("Expected the newer task to wait, got %s", fresh.Status)
+	}
+}
+
+func TestAgingCapKeepsClassOrder(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 1, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetPriorityClasses(testPriorityClasses())
+	engine.SetAging(AgingConfig{Enabled: true, Interval: time.Minute, Step: 100, MaxBoost: 300})
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 2
+
+	// However long it waits, the low task stays below the normal class
+	now := time.Now()
+	starved := newClassTask("low-1", "low", now.Add(-24*time.Hour))
+	queuedAt := starved.CreatedAt
+	starved.QueuedAt = &queuedAt
+	fresh := newClassTask("normal-1", "normal", now)
+	for _, task := range []*models.Task{starved, fresh} {
+		stateManager.AddTask(task)
+	}
+
+	engine.runSchedulingCycle()
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	if fresh.Status != models.TaskStatusRunning || starved.Status != models.TaskStatusPending {
+		t.Errorf("Expected the cap to keep the normal task first, got normal %s and low %s",
+			fresh.Status, starved.Status)
+	}
+}
@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// StopReasonCancelled marks tasks stopped because they were cancelled
+const StopReasonCancelled = "cancelled"
+
+// cancelGracePeriod is the time cancelled tasks get between SIGTERM and
+// SIGKILL
+const cancelGracePeriod = 10 * time.Second
+
+// TaskArraySummary is a task array with the number of tasks in each status
+type TaskArraySummary struct {
+	*models.TaskArray
+	Counts map[models.TaskStatus]int `json:"counts"`
+}
+
+// GetTaskArraySummary returns a task array with the state of its tasks
+func (sm *StateManager) GetTaskArraySummary(arrayID string) (*TaskArraySummary, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	array, exists := sm.state.Arrays[arrayID]
+	if !exists {
+		return nil, fmt.Errorf("task array not found: %s", arrayID)
+	}
+	return sm.arraySummaryLocked(array), nil
+}
+
+// ListTaskArraySummaries returns every task array with the state of its
+// tasks
+func (sm *StateManager) ListTaskArraySummaries() []*TaskArraySummary {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	summaries := make([]*TaskArraySummary, 0, len(sm.state.Arrays))
+	for _, array := range sm.state.Arrays {
+		summaries = append(summaries, sm.arraySummaryLocked(array))
+	}
+	return summaries
+}
+
+// arraySummaryLocked counts the tasks of an array per status (must hold
+// lock)
+func (sm *StateManager) arraySummaryLocked(array *models.TaskArray) *TaskArraySummary {
+	summary := &TaskArraySummary{
+		TaskArray: array,
+		Counts:    make(map[models.TaskStatus]int),
+	}
+	for _, taskID := range array.Tasks {
+		if task, exists := sm.state.Tasks[taskID]; exists {
+			summary.Counts[task.Status]++
+		}
+	}
+	return summary
+}
+
+// runningArrayTasks counts the running tasks of every task array with a
+// parallelism cap
+func (e *Engine) runningArrayTasks() map[string]int {
+	state := e.state.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	running := make(map[string]int)
+	for id, array := range state.Arrays {
+		if array.MaxParallel <= 0 {
+			continue
+		}
+		for _, taskID := range array.Tasks {
+			if task, exists := state.Tasks[taskID]; exists && task.Status == models.TaskStatusRunning {
+				running[id]++
+			}
+		}
+	}
+	return running
+}
+
+// arrayAtCapacity reports whether a task belongs to an array already
+// running as many tasks as its parallelism cap allows
+func (e *Engine) arrayAtCapacity(task *models.Task, running map[string]int) bool {
+	if task.ArrayID == "" {
+		return false
+	}
+
+	state := e.state.GetState()
+	state.mu.RLock()
+	array, exists := state.Arrays[task.ArrayID]
+	state.mu.RUnlock()
+
+	return exists && array.MaxParallel > 0 && running[task.ArrayID] >= array.MaxParallel
+}
+
+// ErrTaskFinished is returned when cancelling a task that already finished
+var ErrTaskFinished = errors.New("task already finished")
+
+// CancelTask cancels a task: pending and waiting tasks right away, running
+// tasks once their agents stopped them
+func (e *Engine) CancelTask(taskID string) error {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	task, exists := state.Tasks[taskID]
+	if !exists {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+
+	now := time.Now()
+	if !e.cancelTaskLocked(task, now) {
+		return fmt.Errorf("%w: %s is %s", ErrTaskFinished, taskID, task.Status)
+	}
+
+	state.Version++
+	state.UpdatedAt = now
+
+	e.logger.Info("Task cancelled",
+		zap.String("task_id", taskID),
+		zap.String("status", string(task.Status)),
+	)
+
+	// Dependents queued by the cancellation may run
+	e.Notify(EventTaskFinished)
+	return nil
+}
+
+// CancelTaskArray cancels every unfinished task of an array. Pending tasks
+// are cancelled right away, running tasks are stopped by their agents. It
+// returns the number of tasks cancelled.
+func (e *Engine) CancelTaskArray(arrayID string) (int, error) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	array, exists := state.Arrays[arrayID]
+	if !exists {
+		return 0, fmt.Errorf("task array not found: %s", arrayID)
+	}
+
+	now := time.Now()
+	cancelled := 0
+	for _, taskID := range array.Tasks {
+		task, exists := state.Tasks[taskID]
+		if !exists {
+			continue
+		}
+
+		if e.cancelTaskLocked(task, now) {
+			cancelled++
+		}
+	}
+
+	state.Version++
+	state.UpdatedAt = now
+
+	e.logger.Info("Task array cancelled",
+		zap.String("array_id", arrayID),
+		zap.Int("cancelled", cancelled),
+	)
+
+	return cancelled, nil
+}
+
+// cancelTaskLocked cancels a pending or waiting task right away and asks the
+// agents of a running task to stop it, returning false if the task already
+// finished (must hold lock)
+func (e *Engine) cancelTaskLocked(task *models.Task, now time.Time) bool {
+	switch task.Status {
+	case models.TaskStatusPending, models.TaskStatusWaiting:
+		task.Status = models.TaskStatusCancelled
+		task.StatusReason = StopReasonCancelled
+		task.Reservation = nil
+		task.FinishedAt = &now
+		e.state.syncTaskLocked(task)
+
+		// Queue or skip the workflow tasks waiting for this one
+		if task.WorkflowID != "" {
+			e.resolveDependentsLocked(task)
+		}
+		return true
+	case models.TaskStatusRunning:
+		// Overrides a pending preemption, the task isn't coming back
+		task.StopRequest = &models.StopRequest{
+			Reason:      StopReasonCancelled,
+			GracePeriod: int(cancelGracePeriod.Seconds()),
+			RequestedAt: now,
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newTestArray(stateManager *StateManager, id string, size, maxParallel int) []*models.Task {
+	array := &models.TaskArray{ID: id, MaxParallel: maxParallel}
+	tasks := make([]*models.Task, size)
+	for i := range tasks {
+		tasks[i] = &models.Task{
+			ID:         fmt.Sprintf("%s-%d", id, i),
+			ArrayID:    id,
+			ArrayIndex: i,
+			Priority:   models.PriorityLow,
+			GPUCount:   1,
+			Command:    "sweep",
+			Status:     models.TaskStatusPending,
+		}
+		array.Tasks = append(array.Tasks, tasks[i].ID)
+	}
+	stateManager.AddTaskArray(array, tasks)
+	return tasks
+}
+
+func TestTaskArrayParallelismCap(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 8, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 8
+
+	tasks := newTestArray(stateManager, "sweep", 5, 2)
+	engine.processQueue(state.Queues[models.PriorityLow].Tasks(), models.PriorityLow, nil)
+
+	running := 0
+	for _, task := range tasks {
+		if task.Status == models.TaskStatusRunning {
+			running++
+		}
+	}
+	if running != 2 {
+		t.Errorf("Expected 2 running array tasks, got %d", running)
+	}
+
+	// Another cycle must not exceed the cap either
+	engine.processQueue(state.Queues[models.PriorityLow].Tasks(), models.PriorityLow, nil)
+	running = 0
+	for _, task := range tasks {
+		if task.Status == models.TaskStatusRunning {
+			running++
+		}
+	}
+	if running != 2 {
+		t.Errorf("Expected the cap to hold across cycles, got %d running", running)
+	}
+}
+
+func TestCancelTaskArray(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 1, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 1
+
+	tasks := newTestArray(stateManager, "sweep", 3, 0)
+	engine.processQueue(state.Queues[models.PriorityLow].Tasks(), models.PriorityLow, nil)
+	if tasks[0].Status != models.TaskStatusRunning {
+		t.Fatalf("Expected first task to run, got %s", tasks[0].Status)
+	}
+
+	cancelled, err := engine.CancelTaskArray("sweep")
+	if err != nil {
+		t.Fatalf("Failed to cancel array: %v", err)
+	}
+	if cancelled != 3 {
+		t.Errorf("Expected 3 cancelled tasks, got %d", cancelled)
+	}
+	if tasks[1].Status != models.TaskStatusCancelled || tasks[2].Status != models.TaskStatusCancelled {
+		t.Error("Expected pending tasks to be cancelled right away")
+	}
+	if tasks[0].StopRequest == nil || tasks[0].StopRequest.Reason != StopReasonCancelled {
+		t.Fatalf("Expected running task to be stopped, got %+v", tasks[0].StopRequest)
+	}
+
+	// Agent confirms the stop, the task is not requeued
+	if err := engine.ReleaseTask(tasks[0].ID, TaskExit{Status: models.TaskStatusFailed}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+
+	summary, err := stateManager.GetTaskArraySummary("sweep")
+	if err != nil {
+		t.Fatalf("Failed to get array summary: %v", err)
+	}
+	if summary.Counts[models.TaskStatusCancelled] != 3 {
+		t.Errorf("Expected all tasks cancelled, got %v", summary.Counts)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	engine, stateManager := newTestEngine(t, nil)
+
+	// Array children are cancelled one by one
+	tasks := newTestArray(stateManager, "sweep", 2, 0)
+	if err := engine.CancelTask(tasks[1].ID); err != nil {
+		t.Fatalf("Failed to cancel task: %v", err)
+	}
+	summary, _ := stateManager.GetTaskArraySummary("sweep")
+	if summary.Counts[models.TaskStatusCancelled] != 1 || summary.Counts[models.TaskStatusPending] != 1 {
+		t.Errorf("Expected 1 cancelled and 1 pending task, got %v", summary.Counts)
+	}
+	if err := engine.CancelTask(tasks[1].ID); !errors.Is(err, ErrTaskFinished) {
+		t.Errorf("Expected ErrTaskFinished cancelling twice, got %v", err)
+	}
+	if err := engine.CancelTask("missing"); err == nil || errors.Is(err, ErrTaskFinished) {
+		t.Errorf("Expected an unknown task error, got %v", err)
+	}
+
+	// Cancelling workflow tasks resolves their dependents
+	preprocess := newWorkflowTask("preprocess")
+	train := newWorkflowTask("train", models.Dependency{TaskID: "preprocess", Condition: models.DependOnSuccess})
+	notify := newWorkflowTask("notify", models.Dependency{TaskID: "preprocess", Condition: models.DependAlways})
+	report := newWorkflowTask("report", models.Dependency{TaskID: "notify", Condition: models.DependOnSuccess})
+	workflow := &models.Workflow{ID: "wf", Tasks: []string{"preprocess", "train", "notify", "report"}}
+	stateManager.AddWorkflow(workflow, []*models.Task{preprocess, train, notify, report})
+
+	if err := engine.CancelTask("report"); err != nil || report.Status != models.TaskStatusCancelled {
+		t.Fatalf("Expected the waiting task cancelled, got %s, %v", report.Status, err)
+	}
+	if err := engine.CancelTask("preprocess"); err != nil {
+		t.Fatalf("Failed to cancel task: %v", err)
+	}
+	if train.Status != models.TaskStatusSkipped || notify.Status != models.TaskStatusPending {
+		t.Errorf("Expected train skipped and notify queued, got %s and %s", train.Status, notify.Status)
+	}
+}
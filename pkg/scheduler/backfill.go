@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// BackfillConfig controls backfill scheduling
+type BackfillConfig struct {
+	Enabled bool
+	// DefaultRuntime is assumed for tasks without an expected runtime,
+	// zero means such tasks are never backfilled onto reserved GPUs
+	DefaultRuntime time.Duration
+}
+
+// SetBackfill configures backfill scheduling
+func (e *Engine) SetBackfill(cfg BackfillConfig) {
+	e.backfill = cfg
+}
+
+// backfillPlan tracks the GPU reservations made during one scheduling cycle
+type backfillPlan struct {
+	now      time.Time
+	reserved map[string]time.Time // GPU ID -> reservation start
+	heads    map[models.Priority]bool
+}
+
+// newBackfillPlan returns an empty plan for a cycle, or nil if backfill is
+// disabled
+func (e *Engine) newBackfillPlan() *backfillPlan {
+	if !e.backfill.Enabled {
+		return nil
+	}
+	return &backfillPlan{
+		now:      time.Now(),
+		reserved: make(map[string]time.Time),
+		heads:    make(map[models.Priority]bool),
+	}
+}
+
+// expectedRuntime returns the task's expected runtime, falling back to its
+// runtime limit and then to the configured default
+func (e *Engine) expectedRuntime(task *models.Task) time.Duration {
+	if task.ExpectedRuntime > 0 {
+		return time.Duration(task.ExpectedRuntime) * time.Second
+	}
+	if task.MaxRuntime > 0 {
+		return time.Duration(task.MaxRuntime) * time.Second
+	}
+	return e.backfill.DefaultRuntime
+}
+
+// hiddenGPUs returns the GPUs a task may not be placed on: reserved GPUs
+// are hidden unless the task is expected to finish before the reservation
+// starts
+func (e *Engine) hiddenGPUs(plan *backfillPlan, task *models.Task) map[string]bool {
+	if plan == nil || len(plan.reserved) == 0 {
+		return nil
+	}
+
+	runtime := e.expectedRuntime(task)
+	hidden := make(map[string]bool)
+	for id, start := range plan.reserved {
+		if runtime <= 0 || plan.now.Add(runtime).After(start) {
+			hidden[id] = true
+		}
+	}
+	return hidden
+}
+
+// reserveFor gives the oldest blocked task of a queue a reservation on the
+// GPUs expected to free up first, so later tasks can't starve it
+func (e *Engine) reserveFor(plan *backfillPlan, task *models.Task) {
+	if plan == nil {
+		return
+	}
+	if plan.heads[task.Priority] {
+		e.clearReservation(task)
+		return
+	}
+	plan.heads[task.Priority] = true
+
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	reservation := e.planReservation(task, state, plan)
+	task.Reservation = reservation
+	if reservation == nil {
+		return
+	}
+
+	for _, gpuID := range reservation.GPUs {
+		plan.reserved[gpuID] = reservation.EstimatedStart
+	}
+
+	e.logger.Debug("GPUs reserved for blocked task",
+		zap.String("task_id", task.ID),
+		zap.Strings("gpus", reservation.GPUs),
+		zap.Time("estimated_start", reservation.EstimatedStart),
+	)
+}
+
+// planReservation finds the earliest time enough GPUs are expected to be
+// free for the task, based on the expected runtime of the running tasks
+// (must hold lock)
+func (e *Engine) planReservation(task *models.Task, state *State, plan *backfillPlan) *models.TaskReservation {
+	freeAt := make(map[string]time.Time)
+	endAt := make(map[*models.Task]time.Time)
+	for id, gpu := range state.GPUs {
+		// GPUs reserved by an earlier queue head stay out of reach
+		if _, reserved := plan.reserved[id]; reserved {
+			continue
+		}
+
+		switch gpu.Status {
+		case models.GPUStatusIdle:
+			freeAt[id] = plan.now
+		case models.GPUStatusBusy:
+			if gpu.CurrentTask == nil {
+				continue
+			}
+			running, exists := state.Tasks[*gpu.CurrentTask]
+			if !exists || running.StartedAt == nil {
+				continue
+			}
+			runtime := e.expectedRuntime(running)
+			if runtime <= 0 {
+				continue
+			}
+			end := running.StartedAt.Add(runtime)
+			if end.Before(plan.now) {
+				end = plan.now
+			}
+			freeAt[id] = end
+			endAt[running] = end
+		}
+	}
+
+	times := make([]time.Time, 0, len(freeAt))
+	seen := make(map[time.Time]bool)
+	for _, t := range freeAt {
+		if !seen[t] {
+			seen[t] = true
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	// Simulate GPUs, CPU and memory freeing up over time until the task fits
+	sim := make(map[string]*models.GPU, len(freeAt))
+	for id := range freeAt {
+		copied := *state.GPUs[id]
+		copied.Status = models.GPUStatusBusy
+		sim[id] = &copied
+	}
+	hosts := e.simHostUsageLocked()
+
+	for _, t := range times {
+		for id, at := range freeAt {
+			if !at.After(t) {
+				sim[id].Status = models.GPUStatusIdle
+			}
+		}
+		for running, end := range endAt {
+			if !end.After(t) {
+				removeHostUsage(hosts, state, running)
+				delete(endAt, running)
+			}
+		}
+
+		gpus, err := e.findAvailableGPUs(task, sim, e.hostFitFor(task, hosts))
+		if err != nil {
+			continue
+		}
+
+		ids := make([]string, len(gpus))
+		for i, gpu := range gpus {
+			ids[i] = gpu.ID
+		}
+		return &models.TaskReservation{
+			GPUs:           ids,
+			EstimatedStart: t,
+		}
+	}
+
+	return nil
+}
+
+// clearReservation drops a task's reservation once it's no longer the
+// oldest blocked task
+func (e *Engine) clearReservation(task *models.Task) {
+	if task.Reservation == nil {
+		return
+	}
+
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	task.Reservation = nil
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestBackfillReservesGPUsForBlockedTask(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetBackfill(BackfillConfig{Enabled: true})
+
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 8
+
+	running := &models.Task{
+		ID:              "running",
+		Priority:        models.PriorityLow,
+		GPUCount:        2,
+		ExpectedRuntime: 3600,
+		Status:          models.TaskStatusPending,
+	}
+	stateManager.AddTask(running)
+	if err := engine.scheduleTask(running); err != nil {
+		t.Fatalf("Failed to schedule running task: %v", err)
+	}
+
+	head := &models.Task{ID: "head", Priority: models.PriorityLow, GPUCount: 4, Status: models.TaskStatusPending}
+	short := &models.Task{ID: "short", Priority: models.PriorityLow, GPUCount: 1, ExpectedRuntime: 600, Status: models.TaskStatusPending}
+	long := &models.Task{ID: "long", Priority: models.PriorityLow, GPUCount: 1, ExpectedRuntime: 7200, Status: models.TaskStatusPending}
+	unknown := &models.Task{ID: "unknown", Priority: models.PriorityLow, GPUCount: 1, Status: models.TaskStatusPending}
+
+	queue := []*models.Task{head, long, unknown, short}
+	engine.processQueue(queue, models.PriorityLow, engine.newBackfillPlan())
+
+	if head.Status != models.TaskStatusPending {
+		t.Fatalf("Expected head task to stay pending, got %s", head.Status)
+	}
+	if head.Reservation == nil {
+		t.Fatal("Expected head task to get a reservation")
+	}
+	if len(head.Reservation.GPUs) != 4 {
+		t.Errorf("Expected 4 reserved GPUs, got %d", len(head.Reservation.GPUs))
+	}
+	expected := running.StartedAt.Add(time.Hour)
+	if head.Reservation.EstimatedStart.Sub(expected).Abs() > time.Second {
+		t.Errorf("Expected estimated start %v, got %v", expected, head.Reservation.EstimatedStart)
+	}
+
+	if short.Status != models.TaskStatusRunning {
+		t.Errorf("Expected short task to be backfilled, got %s", short.Status)
+	}
+	if long.Status != models.TaskStatusPending {
+		t.Errorf("Expected long task to wait behind the reservation, got %s", long.Status)
+	}
+	if unknown.Status != models.TaskStatusPending {
+		t.Errorf("Expected task without expected runtime to wait, got %s", unknown.Status)
+	}
+	if long.Reservation != nil || unknown.Reservation != nil {
+		t.Error("Expected only the head task to hold a reservation")
+	}
+}
+
+func TestBackfillDisabledKeepsFIFOBehaviour(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.BatchQuota = 8
+
+	head := &models.Task{ID: "head", Priority: models.PriorityLow, GPUCount: 4, Status: models.TaskStatusPending}
+	small := &models.Task{ID: "small", Priority: models.PriorityLow, GPUCount: 1, Status: models.TaskStatusPending}
+
+	engine.processQueue([]*models.Task{head, small}, models.PriorityLow, engine.newBackfillPlan())
+
+	if head.Reservation != nil {
+		t.Error("Expected no reservation with backfill disabled")
+	}
+	if small.Status != models.TaskStatusRunning {
+		t.Errorf("Expected small task to run, got %s", small.Status)
+	}
+}
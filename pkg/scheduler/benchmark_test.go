@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// Cluster and backlog sizes for the benchmarks: 10k GPUs on 8-GPU nodes,
+// and 100k tasks of which 10k run, 30k wait and the rest finished
+const (
+	benchNodes    = 1250
+	benchPerNode  = 8
+	benchPending  = 30000
+	benchFinished = 60000
+)
+
+// newBenchCluster builds a full cluster with its backlog. Every GPU runs a
+// task, so pending tasks only start as running ones are stopped.
+func newBenchCluster(b *testing.B) (*Engine, *StateManager, []*models.Task) {
+	b.Helper()
+
+	engine, stateManager := newTestEngine(b, nil)
+	engine.SetPriorityClasses(testPriorityClasses())
+	state := stateManager.GetState()
+
+	created := time.Now().Add(-time.Hour)
+	running := make([]*models.Task, 0, benchNodes*benchPerNode)
+	for n := 0; n < benchNodes; n++ {
+		nodeID := fmt.Sprintf("node-%04d", n)
+		for i := 0; i < benchPerNode; i++ {
+			gpuID := fmt.Sprintf("%s-gpu-%d", nodeID, i)
+			task := newClassTask("running-"+gpuID, "normal", created)
+			task.Status = models.TaskStatusRunning
+			task.AssignedGPUs = []string{gpuID}
+			task.StartedAt = &created
+			stateManager.AddGPU(&models.GPU{
+				ID:          gpuID,
+				NodeID:      nodeID,
+				DeviceIndex: i,
+				Model:       "TestGPU",
+				Memory:      16000,
+				Status:      models.GPUStatusBusy,
+				CurrentTask: &task.ID,
+				UpdatedAt:   created,
+			})
+			stateManager.AddTask(task)
+			running = append(running, task)
+		}
+	}
+
+	for i := 0; i < benchFinished; i++ {
+		task := newClassTask(fmt.Sprintf("finished-%d", i), "normal", created)
+		task.Status = models.TaskStatusSuccess
+		stateManager.AddTask(task)
+	}
+	for i := 0; i < benchPending; i++ {
+		stateManager.AddTask(newClassTask(fmt.Sprintf("pending-%d", i), "normal", created.Add(time.Duration(i))))
+	}
+
+	state.Quota.BatchQuota = len(running) * 2
+	state.Quota.BatchUsed = len(running)
+	return engine, stateManager, running
+}
+
+// BenchmarkSchedulingCycleFull measures a cycle in which none of the
+// pending tasks fits
+func BenchmarkSchedulingCycleFull(b *testing.B) {
+	engine, _, _ := newBenchCluster(b)
+	engine.runSchedulingCycle()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.runSchedulingCycle()
+	}
+}
+
+// BenchmarkSchedulingCycleChurn measures a cycle placing 100 tasks on the
+// GPUs of as many tasks stopped before it
+func BenchmarkSchedulingCycleChurn(b *testing.B) {
+	const churn = 100
+
+	engine, stateManager, _ := newBenchCluster(b)
+	state := stateManager.GetState()
+	engine.runSchedulingCycle()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		state.mu.Lock()
+		stopped := 0
+		for _, task := range state.Tasks {
+			if task.Status != models.TaskStatusRunning {
+				continue
+			}
+			engine.requeueTaskLocked(task, StopReasonPreempted)
+			if stopped++; stopped == churn {
+				break
+			}
+		}
+		state.mu.Unlock()
+		b.StartTimer()
+
+		engine.runSchedulingCycle()
+	}
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// StopReasonReclaimed marks borrowed tasks stopped to hand their GPUs back
+// to online tasks
+const StopReasonReclaimed = "reclaimed"
+
+// Ways borrowed tasks are reclaimed
+const (
+	// ReclaimPreempt stops borrowed tasks like preemption victims
+	ReclaimPreempt = "preempt"
+	// ReclaimCheckpoint asks borrowed tasks to checkpoint and exit
+	ReclaimCheckpoint = "checkpoint"
+)
+
+// BorrowingConfig controls batch tasks borrowing unused online quota
+type BorrowingConfig struct {
+	Enabled bool
+	// Limit is the share of the online quota batch tasks may borrow,
+	// zero means all of it
+	Limit float64
+	// ReclaimMode is ReclaimPreempt or ReclaimCheckpoint
+	ReclaimMode string
+	// GracePeriod is the time borrowed tasks get to stop once reclaimed
+	GracePeriod time.Duration
+}
+
+// SetBorrowing configures borrowing of unused online quota
+func (e *Engine) SetBorrowing(cfg BorrowingConfig) {
+	e.borrowing = cfg
+}
+
+// borrowLimit returns the most online quota batch tasks may ever borrow
+func (e *Engine) borrowLimit(quota *models.Quota) int {
+	if !e.borrowing.Enabled {
+		return 0
+	}
+	if e.borrowing.Limit > 0 {
+		return int(float64(quota.OnlineQuota) * e.borrowing.Limit)
+	}
+	return quota.OnlineQuota
+}
+
+// borrowable returns how much online quota batch tasks may borrow in total,
+// including what they already borrowed
+func (e *Engine) borrowable(quota *models.Quota) int {
+	limit := e.borrowLimit(quota)
+	if unused := quota.OnlineQuota - quota.OnlineUsed; unused < limit {
+		limit = unused
+	}
+	return limit
+}
+
+// canBorrow reports whether a batch task that doesn't fit the batch quota
+// may run on borrowed online quota
+func (e *Engine) canBorrow(task *models.Task, quota *models.Quota) bool {
+	return e.poolOf(task) == models.QuotaPoolBatch &&
+		quota.BatchBorrowed+task.GPUCount <= e.borrowable(quota)
+}
+
+// chargeQuotaLocked charges a task's GPUs to its quota bucket, marking batch
+// tasks beyond the batch quota as borrowed (must hold lock)
+func (e *Engine) chargeQuotaLocked(task *models.Task) {
+	quota := e.state.state.Quota
+
+	switch {
+	case e.poolOf(task) == models.QuotaPoolOnline:
+		quota.OnlineUsed += task.GPUCount
+	case quota.BatchUsed+task.GPUCount > quota.BatchQuota && e.canBorrow(task, quota):
+		task.Borrowed = true
+		quota.BatchBorrowed += task.GPUCount
+	default:
+		quota.BatchUsed += task.GPUCount
+	}
+}
+
+// releaseQuotaLocked returns a task's GPUs to its quota bucket (must hold
+// lock)
+func (e *Engine) releaseQuotaLocked(task *models.Task) {
+	quota := e.state.state.Quota
+
+	switch {
+	case e.poolOf(task) == models.QuotaPoolOnline:
+		quota.OnlineUsed -= task.GPUCount
+	case task.Borrowed:
+		quota.BatchBorrowed -= task.GPUCount
+	default:
+		quota.BatchUsed -= task.GPUCount
+	}
+}
+
+// reclaimFor stops borrowed tasks so an online task blocked by lent quota
+// can run. Borrowers holding the fewest GPUs are reclaimed first, the
+// youngest first among equals. It returns false if reclaiming can't help.
+func (e *Engine) reclaimFor(task *models.Task) bool {
+	if !e.borrowing.Enabled || e.poolOf(task) != models.QuotaPoolOnline {
+		return false
+	}
+
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	quota := state.Quota
+	if quota.OnlineUsed+task.GPUCount > quota.OnlineQuota {
+		return false
+	}
+
+	// Quota still lent out once borrowers being stopped are gone
+	needed := quota.OnlineUsed + quota.BatchBorrowed + task.GPUCount - quota.OnlineQuota
+	borrowers := make([]*models.Task, 0)
+	for _, t := range state.Tasks {
+		if t.Status != models.TaskStatusRunning || !t.Borrowed {
+			continue
+		}
+		if t.StopRequest != nil {
+			needed -= t.GPUCount
+			continue
+		}
+		borrowers = append(borrowers, t)
+	}
+	if needed <= 0 {
+		return true
+	}
+
+	sort.Slice(borrowers, func(i, j int) bool {
+		if borrowers[i].GPUCount != borrowers[j].GPUCount {
+			return borrowers[i].GPUCount < borrowers[j].GPUCount
+		}
+		return startedAt(borrowers[i]).After(startedAt(borrowers[j]))
+	})
+
+	now := time.Now()
+	for _, borrower := range borrowers {
+		if needed <= 0 {
+			break
+		}
+
+		borrower.StopRequest = &models.StopRequest{
+			Reason:      StopReasonReclaimed,
+			GracePeriod: int(e.borrowing.GracePeriod.Seconds()),
+			RequestedAt: now,
+			RequestedBy: task.ID,
+			Checkpoint:  e.borrowing.ReclaimMode == ReclaimCheckpoint,
+		}
+		needed -= borrower.GPUCount
+
+		e.logger.Info("Reclaiming borrowed quota",
+			zap.String("task_id", borrower.ID),
+			zap.String("reclaimed_for", task.ID),
+			zap.Int("gpu_count", borrower.GPUCount),
+		)
+	}
+
+	state.Version++
+	state.UpdatedAt = now
+	return needed <= 0
+}
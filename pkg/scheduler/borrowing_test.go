@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestBatchTaskBorrowsAndIsReclaimed(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetBorrowing(BorrowingConfig{
+		Enabled:     true,
+		ReclaimMode: ReclaimCheckpoint,
+		GracePeriod: time.Minute,
+	})
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 2
+	state.Quota.BatchQuota = 2
+
+	own := &models.Task{ID: "own", Priority: models.PriorityLow, GPUCount: 2, Status: models.TaskStatusPending}
+	borrower := &models.Task{ID: "borrower", Priority: models.PriorityLow, GPUCount: 2, Status: models.TaskStatusPending}
+	for _, task := range []*models.Task{own, borrower} {
+		stateManager.AddTask(task)
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", task.ID, err)
+		}
+	}
+
+	if own.Borrowed {
+		t.Error("Expected task within the batch quota not to borrow")
+	}
+	if !borrower.Borrowed {
+		t.Error("Expected task beyond the batch quota to borrow")
+	}
+	if state.Quota.BatchUsed != 2 || state.Quota.BatchBorrowed != 2 {
+		t.Errorf("Expected 2 used and 2 borrowed, got %d and %d",
+			state.Quota.BatchUsed, state.Quota.BatchBorrowed)
+	}
+
+	// Online demand comes back and reclaims the borrowed quota
+	online := &models.Task{ID: "online", Priority: models.PriorityHigh, GPUCount: 2, Status: models.TaskStatusPending}
+	stateManager.AddTask(online)
+	engine.processQueue([]*models.Task{online}, models.PriorityHigh, nil)
+
+	if online.Status != models.TaskStatusPending {
+		t.Fatalf("Expected online task to wait for reclaim, got %s", online.Status)
+	}
+	if borrower.StopRequest == nil || borrower.StopRequest.Reason != StopReasonReclaimed {
+		t.Fatalf("Expected borrower to be reclaimed, got %+v", borrower.StopRequest)
+	}
+	if !borrower.StopRequest.Checkpoint {
+		t.Error("Expected reclaim to ask for a checkpoint")
+	}
+	if own.StopRequest != nil {
+		t.Error("Expected task within the batch quota not to be reclaimed")
+	}
+
+	// Agent confirms the stop, the borrowed quota is returned
+	if err := engine.ReleaseTask("borrower", TaskExit{Status: models.TaskStatusFailed}); err != nil {
+		t.Fatalf("Failed to release borrower: %v", err)
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	if state.Quota.BatchBorrowed != 0 {
+		t.Errorf("Expected no borrowed quota after reclaim, got %d", state.Quota.BatchBorrowed)
+	}
+	if borrower.StatusReason != StopReasonReclaimed || borrower.Borrowed {
+		t.Errorf("Expected requeued borrower, got reason %q borrowed %v",
+			borrower.StatusReason, borrower.Borrowed)
+	}
+}
+
+func TestBorrowingDisabled(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 2
+	state.Quota.BatchQuota = 2
+
+	task := &models.Task{ID: "batch", Priority: models.PriorityLow, GPUCount: 3, Status: models.TaskStatusPending}
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != errInsufficientQuota {
+		t.Errorf("Expected insufficient quota without borrowing, got %v", err)
+	}
+}
+
+func TestBorrowLimit(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 8, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetBorrowing(BorrowingConfig{Enabled: true, Limit: 0.25})
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 8
+	state.Quota.BatchQuota = 0
+
+	if engine.checkQuota(&models.Task{Priority: models.PriorityLow, GPUCount: 3}, state.Quota) {
+		t.Error("Expected borrowing to be capped at a quarter of the online quota")
+	}
+	if !engine.checkQuota(&models.Task{Priority: models.PriorityLow, GPUCount: 2}, state.Quota) {
+		t.Error("Expected borrowing within the limit")
+	}
+}
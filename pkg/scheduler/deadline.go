@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// expirePendingTasks expires queued tasks whose deadline passed before they
+// could start. Running tasks are killed by their agents instead.
+func (e *Engine) expirePendingTasks(now time.Time) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	expired := 0
+	for _, queue := range state.Queues {
+		for _, task := range queue.Tasks() {
+			if task.Status != models.TaskStatusPending || task.Deadline == nil || task.Deadline.After(now) {
+				continue
+			}
+
+			task.Status = models.TaskStatusExpired
+			task.StatusReason = "deadline passed before the task started"
+			task.Reservation = nil
+			task.FinishedAt = &now
+			e.state.syncTaskLocked(task)
+			expired++
+
+			e.logger.Info("Task expired",
+				zap.String("task_id", task.ID),
+				zap.Time("deadline", *task.Deadline),
+			)
+
+			// Queue or skip the workflow tasks waiting for this one
+			if task.WorkflowID != "" {
+				e.resolveDependentsLocked(task)
+			}
+		}
+	}
+
+	if expired > 0 {
+		e.state.incrementVersion()
+		e.state.triggerSnapshot()
+	}
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestExpirePendingTasks(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{2})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	late := &models.Task{ID: "late", Priority: models.PriorityLow, GPUCount: 1, Deadline: &past, Status: models.TaskStatusPending}
+	onTime := &models.Task{ID: "on-time", Priority: models.PriorityLow, GPUCount: 1, Deadline: &future, Status: models.TaskStatusPending}
+	stateManager.AddTask(late)
+	stateManager.AddTask(onTime)
+
+	engine.expirePendingTasks(now)
+
+	if late.Status != models.TaskStatusExpired || late.FinishedAt == nil {
+		t.Errorf("Expected task past its deadline to expire, got %s", late.Status)
+	}
+	if onTime.Status != models.TaskStatusPending {
+		t.Errorf("Expected task before its deadline to stay pending, got %s", onTime.Status)
+	}
+}
+
+func TestExpiredTaskTriggersFailureHandler(t *testing.T) {
+	engine, stateManager := newTestEngine(t, nil)
+
+	past := time.Now().Add(-time.Minute)
+	train := newWorkflowTask("train")
+	train.Deadline = &past
+	cleanup := newWorkflowTask("cleanup", models.Dependency{TaskID: "train", Condition: models.DependOnFailure})
+	stateManager.AddWorkflow(&models.Workflow{ID: "wf", Tasks: []string{"train", "cleanup"}}, []*models.Task{train, cleanup})
+
+	engine.expirePendingTasks(time.Now())
+
+	if train.Status != models.TaskStatusExpired {
+		t.Fatalf("Expected train to expire, got %s", train.Status)
+	}
+	if cleanup.Status != models.TaskStatusPending {
+		t.Errorf("Expected the on_failure handler to be queued, got %s", cleanup.Status)
+	}
+
+	summary, err := stateManager.GetWorkflowSummary("wf")
+	if err != nil {
+		t.Fatalf("Failed to get workflow summary: %v", err)
+	}
+	if summary.Status != models.WorkflowStatusRunning {
+		t.Errorf("Expected the workflow to keep running, got %s", summary.Status)
+	}
+}
+
+func TestReleaseTimedOutTask(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.BatchQuota = 2
+
+	task := &models.Task{ID: "hung", Priority: models.PriorityLow, GPUCount: 1, MaxRuntime: 60, Status: models.TaskStatusPending}
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule task: %v", err)
+	}
+
+	msg := "exceeded max runtime of 1m0s"
+	if err := engine.ReleaseTask("hung", TaskExit{Status: models.TaskStatusTimeout, Error: &msg}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+
+	state := stateManager.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	if task.Status != models.TaskStatusTimeout {
+		t.Errorf("Expected timeout status, got %s", task.Status)
+	}
+	if state.Quota.BatchUsed != 0 {
+		t.Errorf("Expected quota to be released, got %d used", state.Quota.BatchUsed)
+	}
+}
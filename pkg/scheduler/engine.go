@@ -1,8 +1,9 @@
 package scheduler
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/chicogong/dgpu-scheduler/pkg/logger"
@@ -10,28 +11,71 @@ import (
 	"go.uber.org/zap"
 )
 
+// errInsufficientQuota is returned when a task doesn't fit its quota
+var errInsufficientQuota = errors.New("insufficient quota")
+
 // Engine is the core scheduling engine
 type Engine struct {
-	state  *StateManager
-	logger *logger.Logger
-	stopCh chan struct{}
+	state      *StateManager
+	logger     *logger.Logger
+	placement  PlacementPolicy
+	preemption PreemptionConfig
+	backfill   BackfillConfig
+	fairShare  FairShareConfig
+	borrowing  BorrowingConfig
+	classes    map[models.Priority]models.PriorityClass
+	aging      AgingConfig
+	admission  AdmissionConfig
+	stopCh     chan struct{}
+
+	// sharedModels are the GPU models tasks may share
+	sharedModels map[string]bool
+
+	// agentTimeout is how long agents may go without a heartbeat
+	agentTimeout time.Duration
+	startedAt    time.Time
+
+	// The scheduling loop runs one cycle at a time, woken by events
+	wakeCh  chan struct{}
+	cycleMu sync.Mutex
+	statsMu sync.Mutex
+	stats   CycleStats
+
+	// Quota usage and the CPU and memory taken per node, counted for the
+	// running cycle, nil between cycles
+	usage map[quotaKey]int
+	hosts map[string]*hostUsage
 }
 
 // NewEngine creates a new scheduling engine
 func NewEngine(state *StateManager, log *logger.Logger) *Engine {
-	return &Engine{
-		state:  state,
-		logger: log,
-		stopCh: make(chan struct{}),
+	e := &Engine{
+		state:     state,
+		logger:    log,
+		placement: randomPolicy{},
+		stopCh:    make(chan struct{}),
+		wakeCh:    make(chan struct{}, 1),
+		stats:     CycleStats{Events: make(map[Event]int64)},
 	}
+	e.SetPriorityClasses(models.DefaultPriorityClasses())
+	return e
+}
+
+// SetPlacementPolicy sets the cluster-wide default placement policy
+func (e *Engine) SetPlacementPolicy(policy PlacementPolicy) {
+	e.placement = policy
 }
 
-// Start starts the scheduling loop
+// Start starts the scheduling loop. Cycles run when events arrive, with
+// the interval as a safety net for changes no event announces.
 func (e *Engine) Start(interval time.Duration) {
+	e.startedAt = time.Now()
 	ticker := time.NewTicker(interval)
 	go func() {
 		for {
 			select {
+			case <-e.wakeCh:
+				e.runSchedulingCycle()
 			case <-ticker.C:
 				e.runSchedulingCycle()
 			case <-e.stopCh:
@@ -47,56 +91,154 @@ func (e *Engine) Stop() {
 	close(e.stopCh)
 }
 
-// TriggerSchedule manually triggers a scheduling cycle
-func (e *Engine) TriggerSchedule() {
-	e.runSchedulingCycle()
-}
-
 // runSchedulingCycle executes one scheduling cycle
 func (e *Engine) runSchedulingCycle() {
-	state := e.state.GetState()
+	e.cycleMu.Lock()
+	defer e.cycleMu.Unlock()
+
+	start := time.Now()
+	defer func() { e.recordCycle(start, time.Since(start)) }()
+
+	// Create the tasks of due schedules and expire tasks that missed their
+	// deadline
+	now := time.Now()
+	e.runDueSchedules(now)
+	e.expirePendingTasks(now)
+
+	// Fail or retry the tasks of agents that stopped sending heartbeats
+	e.detectLostAgents(now)
+
+	// Settle stopped tasks their agents stopped without confirming it
+	e.expireStopRequests()
+
+	// Stop the tasks left on draining nodes once their timeout passed
+	e.drainAgents(now)
 
-	// Process high priority queue first
-	e.processQueue(state.HighPriorityQueue, models.PriorityHigh)
+	// Clear the GPUs of reservations whose window opened
+	e.enforceReservations(now)
 
-	// Then process low priority queue
-	e.processQueue(state.LowPriorityQueue, models.PriorityLow)
+	// Reservations made for blocked tasks in this cycle
+	plan := e.newBackfillPlan()
+
+	if e.fairShare.Enabled {
+		e.updateUsage(now)
+	}
+
+	// Process the queues from the highest priority class down, batch
+	// queues lightest teams first. With aging, tasks that waited long
+	// enough move ahead of higher classes.
+	queues := e.queuesInOrder()
+	for i, queue := range queues {
+		if queue.class.Pool == models.QuotaPoolBatch {
+			queues[i].tasks = e.fairShareOrder(queue.tasks)
+		}
+	}
+	if e.aging.Enabled {
+		queues = e.agedOrder(queues, now)
+	}
+	e.recordQueuePositions(queues)
+
+	e.countQuotaUsage()
+	defer e.forgetQuotaUsage()
+	e.countHostUsage()
+	defer e.forgetHostUsage()
+	for _, queue := range queues {
+		e.processQueue(queue.tasks, queue.class.Name, plan)
+	}
 }
 
-// processQueue processes tasks in a priority queue
-func (e *Engine) processQueue(queue []*models.Task, priority models.Priority) {
+// processQueue processes tasks in a priority queue. With backfill enabled,
+// the oldest blocked task gets a reservation and later tasks may only use
+// the reserved GPUs if they are expected to finish before it starts.
+func (e *Engine) processQueue(queue []*models.Task, priority models.Priority, plan *backfillPlan) {
+	running := e.runningArrayTasks()
+	now := time.Now()
+
 	for _, task := range queue {
 		if task.Status != models.TaskStatusPending {
 			continue
 		}
 
+		// Retried tasks wait for their backoff to pass
+		if task.RetryAt != nil && task.RetryAt.After(now) {
+			e.recordWait(task, fmt.Sprintf("retry backoff until %s", task.RetryAt.Format(time.RFC3339)))
+			continue
+		}
+
+		// Task arrays run at most MaxParallel tasks at a time
+		if e.arrayAtCapacity(task, running) {
+			e.recordWait(task, "task array at its parallelism limit")
+			continue
+		}
+
 		// Try to schedule the task
-		if err := e.scheduleTask(task); err != nil {
-			e.logger.Debug("Failed to schedule task",
+		hidden := e.hiddenGPUs(plan, task)
+		err := e.scheduleTaskOn(task, hidden)
+		if err == nil {
+			if task.ArrayID != "" {
+				running[task.ArrayID]++
+			}
+			continue
+		}
+		e.recordFailure(task, hidden, err, now)
+
+		e.logger.Debug("Failed to schedule task",
+			zap.String("task_id", task.ID),
+			zap.String("priority", string(priority)),
+			zap.Error(err),
+		)
+
+		// Preempting batch tasks frees GPUs but not online quota, only
+		// reclaiming borrowed quota does
+		if errors.Is(err, errInsufficientQuota) {
+			e.clearReservation(task)
+			if e.reclaimFor(task) {
+				e.recordWait(task, err.Error()+", waiting for borrowed quota to be reclaimed")
+				e.logger.Debug("Waiting for borrowed quota to be reclaimed",
+					zap.String("task_id", task.ID),
+				)
+			}
+			continue
+		}
+		if e.preemptFor(task) {
+			e.recordWait(task, err.Error()+", waiting for preempted tasks to stop")
+			e.logger.Debug("Waiting for preempted tasks to stop",
 				zap.String("task_id", task.ID),
-				zap.String("priority", string(priority)),
-				zap.Error(err),
 			)
 		}
+		e.reserveFor(plan, task)
 	}
 }
 
 // scheduleTask attempts to schedule a single task
 func (e *Engine) scheduleTask(task *models.Task) error {
+	return e.scheduleTaskOn(task, nil)
+}
+
+// scheduleTaskOn attempts to schedule a single task, keeping it off the
+// hidden GPUs
+func (e *Engine) scheduleTaskOn(task *models.Task, hidden map[string]bool) error {
 	state := e.state.GetState()
 
 	// Step 1: Check quota
 	if !e.checkQuota(task, state.Quota) {
-		return fmt.Errorf("insufficient quota")
+		return errInsufficientQuota
 	}
 
 	// Step 2: Find available GPUs
-	gpus, err := e.findAvailableGPUs(task, state.GPUs)
+	state.mu.RLock()
+	gpus, err := e.findIdleGPUsLocked(task, hidden)
+	state.mu.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	// Step 3: Allocate GPUs to task
+	// Step 3: Check per-model quotas against the picked GPUs
+	if !e.checkModelQuota(task, gpus) {
+		return errInsufficientQuota
+	}
+
+	// Step 4: Allocate GPUs to task
 	if err := e.allocateGPUs(task, gpus); err != nil {
 		return err
 	}
@@ -111,54 +253,164 @@ func (e *Engine) scheduleTask(task *models.Task) error {
 	return nil
 }
 
-// checkQuota checks if there's sufficient quota for the task
+// checkQuota checks if there's sufficient quota for the task, both in the
+// global online/batch split and in the task's organization, team and
+// project quotas
 func (e *Engine) checkQuota(task *models.Task, quota *models.Quota) bool {
-	switch task.Priority {
-	case models.PriorityHigh:
-		return quota.OnlineUsed+task.GPUCount <= quota.OnlineQuota
-	case models.PriorityLow:
-		return quota.BatchUsed+task.GPUCount <= quota.BatchQuota
+	state := e.state.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	switch e.poolOf(task) {
+	case models.QuotaPoolOnline:
+		// Quota lent to batch tasks has to be reclaimed first
+		if quota.OnlineUsed+quota.BatchBorrowed+task.GPUCount > quota.OnlineQuota {
+			return false
+		}
+	case models.QuotaPoolBatch:
+		if quota.BatchUsed+task.GPUCount > quota.BatchQuota && !e.canBorrow(task, quota) {
+			return false
+		}
 	default:
 		return false
 	}
+
+	counts := map[string]int{"": task.GPUCount}
+	if task.GPUModel != nil {
+		counts[*task.GPUModel] = task.GPUCount
+	}
+	return e.quotaAllowsLocked(state, task, counts)
 }
 
-// findAvailableGPUs finds available GPUs for a task
-func (e *Engine) findAvailableGPUs(task *models.Task, allGPUs map[string]*models.GPU) ([]*models.GPU, error) {
+// findAvailableGPUs finds available GPUs for a task among the given GPUs,
+// e.g. a simulation of the cluster, on the nodes its selector and affinity
+// rules allow. Tasks accepting several models get GPUs of a single model,
+// the most preferred one that fits, on nodes the host fit allows (must
+// hold lock).
+func (e *Engine) findAvailableGPUs(task *models.Task, allGPUs map[string]*models.GPU, hosts *hostFit) ([]*models.GPU, error) {
+	fit := e.nodeFitLocked(task)
+	booked := e.reservationFitLocked(task, time.Now())
+	if task.GPUShare != nil {
+		return e.findSharedGPU(task, allGPUs, fit, booked, hosts)
+	}
+
 	available := make([]*models.GPU, 0)
 
-	// Filter idle GPUs
+	// Filter idle GPUs meeting the task's requirements
 	for _, gpu := range allGPUs {
 		if gpu.Status != models.GPUStatusIdle {
 			continue
 		}
-
-		// If task specifies GPU model, check match
-		if task.GPUModel != nil && *task.GPUModel != gpu.Model {
+		if e.gpuFilterLocked(task, gpu, fit, booked, hosts) != "" {
 			continue
 		}
 
 		available = append(available, gpu)
 	}
 
-	// Check if we have enough GPUs
-	if len(available) < task.GPUCount {
-		return nil, fmt.Errorf("insufficient GPUs: need %d, have %d", task.GPUCount, len(available))
+	return e.pickGPUs(task, available, computeNodeLoads(allGPUs), fit)
+}
+
+// findIdleGPUsLocked is findAvailableGPUs for the cluster's own GPUs less
+// the hidden ones, on nodes with the CPU and memory the task asks for. Only
+// the idle GPUs of the models and nodes the task may use are looked at
+// (must hold lock).
+func (e *Engine) findIdleGPUsLocked(task *models.Task, hidden map[string]bool) ([]*models.GPU, error) {
+	state := e.state.state
+	fit := e.nodeFitLocked(task)
+	booked := e.reservationFitLocked(task, time.Now())
+	hosts := e.hostFitLocked(task)
+	if task.GPUShare != nil {
+		return e.findSharedGPU(task, withoutHidden(state.GPUs, hidden), fit, booked, hosts)
 	}
 
-	// Select GPUs (simple random selection)
-	selected := e.selectGPUs(available, task.GPUCount)
-	return selected, nil
+	available := make([]*models.GPU, 0)
+	for _, gpu := range state.gpuIndex.idleFor(task, fit) {
+		if hidden[gpu.ID] || e.gpuFilterLocked(task, gpu, fit, booked, hosts) != "" {
+			continue
+		}
+		available = append(available, gpu)
+	}
+
+	return e.pickGPUs(task, available, state.gpuIndex.loads, fit)
+}
+
+// withoutHidden returns the GPUs less the hidden ones
+func withoutHidden(gpus map[string]*models.GPU, hidden map[string]bool) map[string]*models.GPU {
+	if len(hidden) == 0 {
+		return gpus
+	}
+	visible := make(map[string]*models.GPU, len(gpus))
+	for id, gpu := range gpus {
+		if !hidden[id] {
+			visible[id] = gpu
+		}
+	}
+	return visible
+}
+
+// pickGPUs picks the task's GPUs among the available ones that passed its
+// filters, given the load of every node
+func (e *Engine) pickGPUs(task *models.Task, available []*models.GPU, loads map[string]*NodeLoad, fit *nodeFit) ([]*models.GPU, error) {
+	if len(task.GPUModels) == 0 {
+		// Check if we have enough GPUs
+		if len(available) < task.GPUCount {
+			return nil, fmt.Errorf("insufficient GPUs: need %d, have %d", task.GPUCount, len(available))
+		}
+
+		// Select GPUs according to the placement policy
+		return e.selectGPUs(task, available, loads, fit)
+	}
+
+	// Fall back through the acceptable models in preference order
+	byModel := make(map[string][]*models.GPU)
+	for _, gpu := range available {
+		byModel[gpu.Model] = append(byModel[gpu.Model], gpu)
+	}
+	for _, model := range task.GPUModels {
+		if len(byModel[model]) < task.GPUCount {
+			continue
+		}
+		if gpus, err := e.selectGPUs(task, byModel[model], loads, fit); err == nil {
+			return gpus, nil
+		}
+	}
+	return nil, fmt.Errorf("insufficient GPUs: need %d of one of %v", task.GPUCount, task.GPUModels)
+}
+
+// selectGPUs selects the task's GPUs from the available pool. Distributed
+// tasks get a fixed share on several nodes; otherwise, unless the task
+// allows cross-node placement, all GPUs are taken from one node since the
+// agent launches a single process per task. Nodes the task prefers rank
+// above the placement policy's choice.
+func (e *Engine) selectGPUs(task *models.Task, available []*models.GPU, loads map[string]*NodeLoad, fit *nodeFit) ([]*models.GPU, error) {
+	policy := fit.policy(e.placementPolicyFor(task))
+
+	if task.IsDistributed() {
+		return placeGang(policy, available, task.Gang, loads)
+	}
+	if task.AllowCrossNode {
+		return placeGPUs(policy, available, task.GPUCount, loads), nil
+	}
+	return placeNodeLocal(policy, available, task.GPUCount, loads)
 }
 
-// selectGPUs selects N GPUs from available pool
-func (e *Engine) selectGPUs(available []*models.GPU, count int) []*models.GPU {
-	// Shuffle for random selection (Go 1.20+ auto-seeds global RNG)
-	rand.Shuffle(len(available), func(i, j int) {
-		available[i], available[j] = available[j], available[i]
-	})
+// placementPolicyFor returns the task's placement policy override, or the
+// cluster default if the task doesn't set one
+func (e *Engine) placementPolicyFor(task *models.Task) PlacementPolicy {
+	if task.PlacementPolicy == "" {
+		return e.placement
+	}
 
-	return available[:count]
+	policy, err := NewPlacementPolicy(task.PlacementPolicy)
+	if err != nil {
+		e.logger.Warn("Invalid task placement policy, using default",
+			zap.String("task_id", task.ID),
+			zap.String("placement_policy", task.PlacementPolicy),
+		)
+		return e.placement
+	}
+	return policy
 }
 
 // allocateGPUs allocates GPUs to a task
@@ -168,25 +420,43 @@ func (e *Engine) allocateGPUs(task *models.Task, gpus []*models.GPU) error {
 	defer state.mu.Unlock()
 
 	// Update GPU status
+	now := time.Now()
 	assignedIDs := make([]string, len(gpus))
 	for i, gpu := range gpus {
-		gpu.Status = models.GPUStatusBusy
-		gpu.CurrentTask = &task.ID
-		gpu.UpdatedAt = time.Now()
+		if task.GPUShare != nil {
+			addTenant(gpu, task, now)
+		} else {
+			gpu.Status = models.GPUStatusBusy
+			gpu.CurrentTask = &task.ID
+			gpu.UpdatedAt = now
+		}
+		e.state.syncGPULocked(gpu)
 		assignedIDs[i] = gpu.ID
 	}
 
 	// Update task
 	task.AssignedGPUs = assignedIDs
+	task.AssignedModel = commonModel(gpus)
+	task.Reservation = nil
+	task.Diagnostics = nil
+	task.RetryAt = nil
+	task.TopologyScore = nil
+	if task.IsDistributed() {
+		task.GangMembers = buildGangMembers(gpus)
+	} else if score, ok := topologyScore(gpus); ok {
+		task.TopologyScore = &score
+	}
 	task.Status = models.TaskStatusRunning
-	now := time.Now()
 	task.StartedAt = &now
+	e.state.syncTaskLocked(task)
 
 	// Update quota
-	if task.Priority == models.PriorityHigh {
-		state.Quota.OnlineUsed += task.GPUCount
-	} else {
-		state.Quota.BatchUsed += task.GPUCount
+	e.chargeQuotaLocked(task)
+	if e.usage != nil {
+		addQuotaUsage(e.usage, state, task)
+	}
+	if e.hosts != nil {
+		addHostUsage(e.hosts, state, task)
 	}
 
 	// Increment version
@@ -196,8 +466,9 @@ func (e *Engine) allocateGPUs(task *models.Task, gpus []*models.GPU) error {
 	return nil
 }
 
-// ReleaseTask releases resources when a task finishes
-func (e *Engine) ReleaseTask(taskID string, status models.TaskStatus, errorMsg *string) error {
+// ReleaseTask releases resources when a task finishes, or requeues the task
+// if its retry policy allows another attempt
+func (e *Engine) ReleaseTask(taskID string, exit TaskExit) error {
 	task, err := e.state.GetTask(taskID)
 	if err != nil {
 		return err
@@ -207,41 +478,60 @@ func (e *Engine) ReleaseTask(taskID string, status models.TaskStatus, errorMsg *
 	state.mu.Lock()
 	defer state.mu.Unlock()
 
+	// Ignore late reports for tasks that were already released
+	if task.Status != models.TaskStatusRunning {
+		return fmt.Errorf("task is not running: %s", taskID)
+	}
+
+	// Tasks stopped on request go back to the queue unless cancelled
+	if task.StopRequest != nil {
+		e.settleStoppedTaskLocked(task)
+		return nil
+	}
+
+	e.endAttemptLocked(task, exit)
+	return nil
+}
+
+// releaseTaskLocked frees a task's GPUs and quota and records its final
+// status (must hold lock)
+func (e *Engine) releaseTaskLocked(task *models.Task, status models.TaskStatus, errorMsg *string) {
+	state := e.state.state
+
 	// Release GPUs
 	for _, gpuID := range task.AssignedGPUs {
 		if gpu, exists := state.GPUs[gpuID]; exists {
-			gpu.Status = models.GPUStatusIdle
-			gpu.CurrentTask = nil
-			gpu.UpdatedAt = time.Now()
+			freeGPU(gpu, task.ID, time.Now())
+			e.state.syncGPULocked(gpu)
 		}
 	}
 
 	// Update quota
-	if task.Priority == models.PriorityHigh {
-		state.Quota.OnlineUsed -= task.GPUCount
-	} else {
-		state.Quota.BatchUsed -= task.GPUCount
-	}
+	e.releaseQuotaLocked(task)
 
 	// Update task status
 	task.Status = status
 	now := time.Now()
 	task.FinishedAt = &now
+	e.state.syncTaskLocked(task)
 	if errorMsg != nil {
 		task.Error = errorMsg
 	}
 
+	// Queue or skip the workflow tasks waiting for this one
+	if task.WorkflowID != "" {
+		e.resolveDependentsLocked(task)
+	}
+
 	// Increment version
 	state.Version++
 	state.UpdatedAt = time.Now()
 
 	e.logger.Info("Task released",
-		zap.String("task_id", taskID),
+		zap.String("task_id", task.ID),
 		zap.String("status", string(status)),
 	)
 
-	// Trigger scheduling to process pending tasks
-	go e.TriggerSchedule()
-
-	return nil
+	// Freed GPUs may let pending tasks run
+	e.Notify(EventTaskFinished)
 }
@@ -191,7 +191,7 @@ func TestReleaseTask(t *testing.T) {
 	}
 
 	// Release the task
-	err = engine.ReleaseTask("task-1", models.TaskStatusSuccess, nil)
+	err = engine.ReleaseTask("task-1", TaskExit{Status: models.TaskStatusSuccess})
 	if err != nil {
 		t.Fatalf("Failed to release task: %v", err)
 	}
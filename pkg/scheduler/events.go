@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event is a state change that may let pending tasks run
+type Event string
+
+const (
+	EventTaskAdded          Event = "task_added"
+	EventTaskFinished       Event = "task_finished"
+	EventTaskRequeued       Event = "task_requeued"
+	EventAgentRegistered    Event = "agent_registered"
+	EventGPUStatusChanged   Event = "gpu_status_changed"
+	EventQuotaChanged       Event = "quota_changed"
+	EventNodeChanged        Event = "node_changed" // cordon, drain or taints
+	EventReservationChanged Event = "reservation_changed"
+)
+
+// CycleStats reports on the scheduling cycles run so far
+type CycleStats struct {
+	Cycles        int64           `json:"cycles"`
+	LastRunAt     *time.Time      `json:"last_run_at,omitempty"`
+	LastLatencyMs float64         `json:"last_latency_ms"`
+	AvgLatencyMs  float64         `json:"avg_latency_ms"`
+	MaxLatencyMs  float64         `json:"max_latency_ms"`
+	Events        map[Event]int64 `json:"events"` // events received, by type
+}
+
+// Notify wakes the scheduling loop for an event. Events arriving while a
+// cycle runs are coalesced into a single further cycle. It never blocks,
+// so it may be called with the state lock held.
+func (e *Engine) Notify(event Event) {
+	e.statsMu.Lock()
+	e.stats.Events[event]++
+	e.statsMu.Unlock()
+
+	select {
+	case e.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// CycleStats returns the scheduling loop's statistics
+func (e *Engine) CycleStats() CycleStats {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+
+	stats := e.stats
+	stats.Events = maps.Clone(e.stats.Events)
+	return stats
+}
+
+// recordCycle adds a finished cycle to the statistics
+func (e *Engine) recordCycle(start time.Time, latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+
+	e.statsMu.Lock()
+	e.stats.Cycles++
+	e.stats.LastRunAt = &start
+	e.stats.LastLatencyMs = ms
+	e.stats.AvgLatencyMs += (ms - e.stats.AvgLatencyMs) / float64(e.stats.Cycles)
+	e.stats.MaxLatencyMs = max(e.stats.MaxLatencyMs, ms)
+	e.statsMu.Unlock()
+
+	e.logger.Debug("Scheduling cycle finished", zap.Duration("latency", latency))
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// waitFor polls a condition read under the state lock until it holds
+func waitFor(t *testing.T, stateManager *StateManager, cond func() bool) bool {
+	t.Helper()
+
+	state := stateManager.GetState()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		state.mu.RLock()
+		ok := cond()
+		state.mu.RUnlock()
+		if ok {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestEventsWakeTheLoop(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.OnlineQuota = 2
+
+	// A ticker this slow never fires during the test
+	engine.Start(time.Hour)
+	defer engine.Stop()
+
+	task := newClassTask("task-1", models.PriorityHigh, time.Now())
+	stateManager.AddTask(task)
+	engine.Notify(EventTaskAdded)
+
+	if !waitFor(t, stateManager, func() bool { return task.Status == models.TaskStatusRunning }) {
+		t.Fatal("Expected the event to schedule the task")
+	}
+
+	stats := engine.CycleStats()
+	if stats.Cycles < 1 || stats.LastRunAt == nil || stats.Events[EventTaskAdded] != 1 {
+		t.Errorf("Expected a cycle and the event counted, got %+v", stats)
+	}
+}
+
+func TestNotifyCoalescesEvents(t *testing.T) {
+	engine, _ := newTestEngine(t, newTestCluster([]string{"node-a"}, 1, []int{0}))
+
+	// Events before the loop runs leave a single wake-up pending
+	for i := 0; i < 100; i++ {
+		engine.Notify(EventTaskFinished)
+	}
+	if len(engine.wakeCh) != 1 {
+		t.Fatalf("Expected one pending wake-up, got %d", len(engine.wakeCh))
+	}
+
+	engine.Start(time.Hour)
+	defer engine.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for engine.CycleStats().Cycles == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stats := engine.CycleStats()
+	if stats.Cycles != 1 {
+		t.Errorf("Expected 100 events to run one cycle, got %d", stats.Cycles)
+	}
+	if stats.Events[EventTaskFinished] != 100 {
+		t.Errorf("Expected 100 events counted, got %d", stats.Events[EventTaskFinished])
+	}
+}
+
+func TestCyclesRunOneAtATime(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b"}, 4, []int{0, 0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.OnlineQuota = 8
+
+	for _, id := range []string{"t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9"} {
+		stateManager.AddTask(newClassTask(id, models.PriorityHigh, time.Now()))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			engine.runSchedulingCycle()
+		}()
+	}
+	wg.Wait()
+
+	// Overlapping cycles could hand a GPU to two tasks
+	state := stateManager.GetState()
+	owners := make(map[string]string)
+	running := 0
+	for _, task := range state.Tasks {
+		if task.Status != models.TaskStatusRunning {
+			continue
+		}
+		running++
+		for _, gpuID := range task.AssignedGPUs {
+			if other, taken := owners[gpuID]; taken {
+				t.Errorf("GPU %s given to both %s and %s", gpuID, other, task.ID)
+			}
+			owners[gpuID] = task.ID
+		}
+	}
+	if running != 8 {
+		t.Errorf("Expected 8 tasks running, got %d", running)
+	}
+	if stats := engine.CycleStats(); stats.Cycles != 4 {
+		t.Errorf("Expected 4 cycles, got %d", stats.Cycles)
+	}
+}
+
+func TestReportGPUStatusKeepsAllocations(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{1})
+	_, stateManager := newTestEngine(t, gpus)
+	busy, idle := gpus["node-a-gpu-0"], gpus["node-a-gpu-1"]
+
+	// Agents don't know which GPUs the scheduler handed out
+	if changed, _ := stateManager.ReportGPUStatus(busy.ID, models.GPUStatusIdle); changed || busy.Status != models.GPUStatusBusy {
+		t.Errorf("Expected a busy GPU to stay busy, got %s", busy.Status)
+	}
+
+	if changed, _ := stateManager.ReportGPUStatus(idle.ID, models.GPUStatusOffline); !changed || idle.Status != models.GPUStatusOffline {
+		t.Errorf("Expected the GPU offline, got %s", idle.Status)
+	}
+	if changed, _ := stateManager.ReportGPUStatus(idle.ID, models.GPUStatusIdle); !changed || idle.Status != models.GPUStatusIdle {
+		t.Errorf("Expected the GPU back, got %s", idle.Status)
+	}
+
+	if _, err := stateManager.ReportGPUStatus("missing", models.GPUStatusIdle); err == nil {
+		t.Error("Expected an unknown GPU to fail")
+	}
+}
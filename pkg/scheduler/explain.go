@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// Filters keeping a task off a GPU, in the order they are applied.
+// Constraints waiting won't fix come first, so a GPU only counts as busy if
+// the task could otherwise use it.
+const (
+	FilterOffline     = "offline"
+	FilterModel       = "model"
+	FilterMemory      = "memory"
+	FilterComputeCap  = "compute_capability"
+	FilterNotShared   = "not_shared" // the GPU model isn't shared
+	FilterMaintenance = "maintenance"
+	FilterTaint       = "taint"
+	FilterNode        = "node_constraint" // node selector or affinity rules
+	FilterReservation = "reservation"
+	FilterBusy        = "busy"
+	FilterResources   = "host_resources" // the node lacks the CPU or memory
+	FilterBackfill    = "backfill"       // held for an older blocked task
+	FilterQuota       = "quota"
+)
+
+// gpuFilterLocked returns the first filter keeping a task off a GPU, or ""
+// if the task may use it (must hold lock)
+func (e *Engine) gpuFilterLocked(task *models.Task, gpu *models.GPU, fit *nodeFit, booked *reservationFit, hosts *hostFit) string {
+	if gpu.Status == models.GPUStatusOffline {
+		return FilterOffline
+	}
+	if filter := unmetRequirement(task, gpu); filter != "" {
+		return filter
+	}
+	if task.GPUShare != nil && !e.sharedModels[gpu.Model] {
+		return FilterNotShared
+	}
+	if filter := e.nodeRejectionLocked(task, gpu.NodeID); filter != "" {
+		return filter
+	}
+	if !fit.allows(gpu.NodeID) {
+		return FilterNode
+	}
+	if !booked.allows(gpu.ID) {
+		return FilterReservation
+	}
+	if !hasRoom(task, gpu) {
+		return FilterBusy
+	}
+	if !hosts.allows(gpu.NodeID) {
+		return FilterResources
+	}
+	return ""
+}
+
+// hasRoom reports whether a GPU is idle, or for tasks asking for a slice,
+// shared with room for the slice
+func hasRoom(task *models.Task, gpu *models.GPU) bool {
+	if task.GPUShare == nil {
+		return gpu.Status == models.GPUStatusIdle
+	}
+	if gpu.Status != models.GPUStatusIdle && !isShared(gpu) {
+		return false
+	}
+	percent, memory := sliceOf(task.GPUShare, gpu)
+	freePercent, freeMemory := freeSlice(gpu)
+	return percent <= freePercent && memory <= freeMemory
+}
+
+// diagnosticsLocked returns a task's diagnostics, creating them if needed
+// (must hold lock)
+func diagnosticsLocked(task *models.Task) *models.SchedulingDiagnostics {
+	if task.Diagnostics == nil {
+		task.Diagnostics = &models.SchedulingDiagnostics{}
+	}
+	return task.Diagnostics
+}
+
+// recordQueuePositions notes the place of every pending task in the order
+// this cycle considers them
+func (e *Engine) recordQueuePositions(queues []classQueue) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	pending := make([]*models.Task, 0)
+	for _, queue := range queues {
+		for _, task := range queue.tasks {
+			if task.Status == models.TaskStatusPending {
+				pending = append(pending, task)
+			}
+		}
+	}
+
+	for i, task := range pending {
+		diag := diagnosticsLocked(task)
+		diag.QueuePosition = i + 1
+		diag.QueueLength = len(pending)
+	}
+}
+
+// recordWait notes why a pending task is waiting, keeping the counts of its
+// last attempt
+func (e *Engine) recordWait(task *models.Task, reason string) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	diagnosticsLocked(task).Reason = reason
+}
+
+// recordFailure notes why a task could not be placed and the GPUs backfill
+// hid from it. Counting the GPUs each filter rejected is left to Explain,
+// so failing tasks don't cost a pass over the cluster every cycle.
+func (e *Engine) recordFailure(task *models.Task, hidden map[string]bool, err error, now time.Time) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	diag := diagnosticsLocked(task)
+	diag.LastAttempt = &now
+	diag.Reason = err.Error()
+	diag.QuotaExceeded = errors.Is(err, errInsufficientQuota)
+	diag.NeededGPUs = task.GPUCount
+	diag.HeldGPUs = nil
+	for id := range hidden {
+		diag.HeldGPUs = append(diag.HeldGPUs, id)
+	}
+}
+
+// countRejectionsLocked counts the GPUs each filter keeps a task off, and
+// those left eligible (must hold lock)
+func (e *Engine) countRejectionsLocked(task *models.Task, diag *models.SchedulingDiagnostics, now time.Time) {
+	state := e.state.state
+	fit := e.nodeFitLocked(task)
+	booked := e.reservationFitLocked(task, now)
+	hosts := e.hostFitLocked(task)
+
+	held := make(map[string]bool, len(diag.HeldGPUs))
+	for _, id := range diag.HeldGPUs {
+		held[id] = true
+	}
+
+	diag.TotalGPUs = len(state.GPUs)
+	diag.EligibleGPUs = 0
+	diag.Rejected = make(map[string]int)
+	for id, gpu := range state.GPUs {
+		filter := e.gpuFilterLocked(task, gpu, fit, booked, hosts)
+		if filter == "" && held[id] {
+			filter = FilterBackfill
+		}
+		if filter != "" {
+			diag.Rejected[filter]++
+			continue
+		}
+		diag.EligibleGPUs++
+	}
+
+	// GPUs the quota keeps the task from
+	if diag.QuotaExceeded && diag.EligibleGPUs > 0 {
+		diag.Rejected[FilterQuota] = diag.EligibleGPUs
+		diag.EligibleGPUs = 0
+	}
+}
+
+// TaskExplanation tells why a task is in its current state
+type TaskExplanation struct {
+	TaskID       string                        `json:"task_id"`
+	Status       models.TaskStatus             `json:"status"`
+	StatusReason string                        `json:"status_reason,omitempty"`
+	Message      string                        `json:"message"`
+	Diagnostics  *models.SchedulingDiagnostics `json:"diagnostics,omitempty"`
+	Reservation  *models.TaskReservation       `json:"reservation,omitempty"`
+}
+
+// Explain tells why a task is in its current state, with the diagnostics of
+// the last scheduling cycle for pending tasks
+func (e *Engine) Explain(taskID string) (*TaskExplanation, error) {
+	state := e.state.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	task, exists := state.Tasks[taskID]
+	if !exists {
+		return nil, fmt.Errorf("task not found: %s", taskID)
+	}
+
+	explanation := &TaskExplanation{
+		TaskID:       task.ID,
+		Status:       task.Status,
+		StatusReason: task.StatusReason,
+	}
+
+	switch task.Status {
+	case models.TaskStatusPending:
+		if task.Diagnostics == nil {
+			explanation.Message = "Not considered by a scheduling cycle yet"
+			break
+		}
+		diag := *task.Diagnostics
+		if diag.LastAttempt != nil {
+			e.countRejectionsLocked(task, &diag, time.Now())
+		}
+		explanation.Diagnostics = &diag
+		explanation.Message = summarize(&diag)
+		if task.Reservation != nil {
+			reservation := *task.Reservation
+			explanation.Reservation = &reservation
+		}
+	case models.TaskStatusWaiting:
+		explanation.Message = "Waiting for upstream tasks to finish"
+	case models.TaskStatusRunning:
+		if task.StopRequest != nil {
+			explanation.Message = fmt.Sprintf("Stopping (%s)", task.StopRequest.Reason)
+		} else {
+			explanation.Message = fmt.Sprintf("Running on %d GPUs", len(task.AssignedGPUs))
+		}
+	default:
+		explanation.Message = fmt.Sprintf("Finished with status %s", task.Status)
+	}
+
+	return explanation, nil
+}
+
+// summarize describes a pending task's diagnostics in one line
+func summarize(diag *models.SchedulingDiagnostics) string {
+	if diag.LastAttempt == nil {
+		return diag.Reason
+	}
+
+	msg := fmt.Sprintf("%s; %d of %d GPUs eligible, %d needed",
+		diag.Reason, diag.EligibleGPUs, diag.TotalGPUs, diag.NeededGPUs)
+	if len(diag.Rejected) > 0 {
+		msg += " (rejected: " + describeRejections(diag.Rejected) + ")"
+	}
+	return msg
+}
+
+// describeRejections lists the GPUs each filter rejected, the filters that
+// rejected the most first
+func describeRejections(rejected map[string]int) string {
+	filters := make([]string, 0, len(rejected))
+	for filter := range rejected {
+		filters = append(filters, filter)
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		if rejected[filters[i]] != rejected[filters[j]] {
+			return rejected[filters[i]] > rejected[filters[j]]
+		}
+		return filters[i] < filters[j]
+	})
+
+	counts := make([]string, len(filters))
+	for i, filter := range filters {
+		counts[i] = fmt.Sprintf("%s %d", filter, rejected[filter])
+	}
+	return strings.Join(counts, ", ")
+}
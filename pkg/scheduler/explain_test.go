@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestExplainCountsRejectedGPUs(t *testing.T) {
+	engine, stateManager := newTestEngine(t, newMixedCluster())
+	stateManager.GetState().Quota.OnlineQuota = 8
+
+	model := "A100"
+	task := &models.Task{
+		ID:          "train-1",
+		Priority:    models.PriorityHigh,
+		GPUCount:    3,
+		GPUModel:    &model,
+		MinMemoryMB: 40000,
+		Command:     "train",
+		Status:      models.TaskStatusPending,
+	}
+	stateManager.AddTask(task)
+
+	engine.runSchedulingCycle()
+
+	explanation, err := engine.Explain(task.ID)
+	if err != nil {
+		t.Fatalf("Failed to explain: %v", err)
+	}
+	diag := explanation.Diagnostics
+	if diag == nil || diag.LastAttempt == nil {
+		t.Fatalf("Expected diagnostics of the last attempt, got %+v", explanation)
+	}
+
+	// The V100s fail the model filter first, 2 A100s are busy
+	want := map[string]int{FilterModel: 4, FilterBusy: 2}
+	for filter, count := range want {
+		if diag.Rejected[filter] != count {
+			t.Errorf("Expected %d GPUs rejected by %s, got %v", count, filter, diag.Rejected)
+		}
+	}
+	if diag.EligibleGPUs != 2 || diag.NeededGPUs != 3 || diag.TotalGPUs != 8 {
+		t.Errorf("Expected 2 of 8 GPUs eligible for 3 needed, got %+v", diag)
+	}
+	if diag.QueuePosition != 1 || diag.QueueLength != 1 {
+		t.Errorf("Expected queue position 1 of 1, got %d of %d", diag.QueuePosition, diag.QueueLength)
+	}
+	if !strings.Contains(explanation.Message, "model 4") {
+		t.Errorf("Expected the message to name the model filter, got %q", explanation.Message)
+	}
+
+	// Placed tasks drop their diagnostics
+	task.GPUCount = 2
+	engine.runSchedulingCycle()
+	explanation, _ = engine.Explain(task.ID)
+	if explanation.Status != models.TaskStatusRunning || explanation.Diagnostics != nil {
+		t.Errorf("Expected a running task without diagnostics, got %+v", explanation)
+	}
+}
+
+func TestExplainQuotaAndQueuePosition(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 0
+
+	now := time.Now()
+	tasks := []*models.Task{
+		newClassTask("first", models.PriorityHigh, now.Add(-time.Minute)),
+		newClassTask("second", models.PriorityHigh, now),
+	}
+	for _, task := range tasks {
+		stateManager.AddTask(task)
+	}
+
+	engine.runSchedulingCycle()
+
+	for i, task := range tasks {
+		explanation, err := engine.Explain(task.ID)
+		if err != nil {
+			t.Fatalf("Failed to explain %s: %v", task.ID, err)
+		}
+		diag := explanation.Diagnostics
+		if diag.QueuePosition != i+1 || diag.QueueLength != 2 {
+			t.Errorf("Expected %s at %d of 2, got %d of %d", task.ID, i+1, diag.QueuePosition, diag.QueueLength)
+		}
+		if diag.Rejected[FilterQuota] != 4 || diag.EligibleGPUs != 0 {
+			t.Errorf("Expected the quota to reject all 4 GPUs, got %+v", diag)
+		}
+	}
+
+	if _, err := engine.Explain("missing"); err == nil {
+		t.Error("Expected an unknown task to fail")
+	}
+}
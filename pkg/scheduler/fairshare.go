@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// FairShareConfig controls fair-share ordering of the batch queue
+type FairShareConfig struct {
+	Enabled bool
+	// HalfLife is the time after which recorded GPU-hours count half
+	HalfLife time.Duration
+	// Weights scales each team's entitlement, teams not listed get 1
+	Weights map[string]float64
+}
+
+// TeamShare is a team's current fair-share standing
+type TeamShare struct {
+	Team        string  `json:"team"`
+	Weight      float64 `json:"weight"`
+	RunningGPUs int     `json:"running_gpus"`
+	GPUHours    float64 `json:"gpu_hours"`
+	Share       float64 `json:"share"`
+}
+
+// SetFairShare configures fair-share ordering of the batch queue
+func (e *Engine) SetFairShare(cfg FairShareConfig) {
+	e.fairShare = cfg
+}
+
+// teamOf returns the team a task is accounted to
+func teamOf(task *models.Task) string {
+	if task.Team == "" {
+		return models.DefaultTeam
+	}
+	return task.Team
+}
+
+// weight returns a team's fair-share weight
+func (cfg *FairShareConfig) weight(team string) float64 {
+	if w, exists := cfg.Weights[team]; exists && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// updateUsage decays every team's recorded GPU-hours and adds the GPU time
+// of running tasks since the last update
+func (e *Engine) updateUsage(now time.Time) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for _, usage := range state.TeamUsage {
+		elapsed := now.Sub(usage.UpdatedAt)
+		if elapsed <= 0 {
+			continue
+		}
+		if e.fairShare.HalfLife > 0 {
+			usage.GPUHours *= math.Pow(0.5, elapsed.Hours()/e.fairShare.HalfLife.Hours())
+		}
+	}
+
+	for _, task := range state.Tasks {
+		if task.Status != models.TaskStatusRunning || task.StartedAt == nil {
+			continue
+		}
+
+		team := teamOf(task)
+		usage, exists := state.TeamUsage[team]
+		if !exists {
+			usage = &models.TeamUsage{Team: team}
+			state.TeamUsage[team] = usage
+		}
+
+		// Only count the part of the run since the last update
+		since := *task.StartedAt
+		if usage.UpdatedAt.After(since) {
+			since = usage.UpdatedAt
+		}
+		if now.After(since) {
+			usage.GPUHours += float64(task.GPUCount) * now.Sub(since).Hours()
+		}
+	}
+
+	for _, usage := range state.TeamUsage {
+		usage.UpdatedAt = now
+	}
+}
+
+// teamShares computes the weighted dominant share of every known team
+// (must hold lock)
+func (e *Engine) teamShares(state *State) map[string]*TeamShare {
+	shares := make(map[string]*TeamShare)
+	get := func(team string) *TeamShare {
+		share, exists := shares[team]
+		if !exists {
+			share = &TeamShare{Team: team, Weight: e.fairShare.weight(team)}
+			shares[team] = share
+		}
+		return share
+	}
+
+	for team, usage := range state.TeamUsage {
+		get(team).GPUHours = usage.GPUHours
+	}
+	for _, task := range state.Tasks {
+		switch task.Status {
+		case models.TaskStatusRunning:
+			get(teamOf(task)).RunningGPUs += task.GPUCount
+		case models.TaskStatusPending:
+			get(teamOf(task))
+		}
+	}
+
+	for _, share := range shares {
+		share.Share = e.dominantShare(state, share.RunningGPUs, share.GPUHours) / share.Weight
+	}
+	return shares
+}
+
+// dominantShare returns the dominant resource share of a team: the larger
+// of its share of the cluster's GPUs now and its share of the decayed
+// GPU-hours the cluster could have delivered
+func (e *Engine) dominantShare(state *State, runningGPUs int, gpuHours float64) float64 {
+	total := float64(len(state.GPUs))
+	if total == 0 {
+		return 0
+	}
+
+	allocation := float64(runningGPUs) / total
+
+	// Capacity of the decay window: total GPUs integrated over the decay
+	// curve, i.e. total * halfLife / ln 2
+	usage := 0.0
+	if e.fairShare.HalfLife > 0 {
+		capacity := total * e.fairShare.HalfLife.Hours() / math.Ln2
+		usage = gpuHours / capacity
+	}
+
+	return math.Max(allocation, usage)
+}
+
+// TeamShares returns every team's current fair-share standing, lowest
+// share first
+func (e *Engine) TeamShares() []TeamShare {
+	state := e.state.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	shares := e.teamShares(state)
+	result := make([]TeamShare, 0, len(shares))
+	for _, share := range shares {
+		result = append(result, *share)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Share != result[j].Share {
+			return result[i].Share < result[j].Share
+		}
+		return result[i].Team < result[j].Team
+	})
+	return result
+}
+
+// fairShareOrder reorders the pending tasks of a queue so teams with the
+// lowest share are served first. Each task handed out raises its team's
+// share by the GPUs it would take, so one team can't take a whole cycle.
+// Within a team tasks keep their submission order.
+func (e *Engine) fairShareOrder(queue []*models.Task) []*models.Task {
+	if !e.fairShare.Enabled {
+		return queue
+	}
+
+	state := e.state.GetState()
+	state.mu.RLock()
+	shares := e.teamShares(state)
+	total := float64(len(state.GPUs))
+	state.mu.RUnlock()
+
+	pending := make(map[string][]*models.Task)
+	for _, task := range queue {
+		if task.Status == models.TaskStatusPending {
+			team := teamOf(task)
+			pending[team] = append(pending[team], task)
+		}
+	}
+
+	weights := make(map[string]float64, len(pending))
+	teams := &teamHeap{}
+	for team, tasks := range pending {
+		weights[team] = e.fairShare.weight(team)
+		entry := &teamEntry{team: team, tasks: tasks}
+		if share, exists := shares[team]; exists {
+			entry.share = share.Share
+		}
+		heap.Push(teams, entry)
+	}
+
+	ordered := make([]*models.Task, 0, len(queue))
+	for teams.Len() > 0 {
+		entry := heap.Pop(teams).(*teamEntry)
+		task := entry.tasks[0]
+		ordered = append(ordered, task)
+
+		entry.tasks = entry.tasks[1:]
+		if len(entry.tasks) > 0 {
+			if total > 0 {
+				entry.share += float64(task.GPUCount) / total / weights[entry.team]
+			}
+			heap.Push(teams, entry)
+		}
+	}
+
+	return ordered
+}
+
+// teamEntry is a team's remaining pending tasks during fair-share ordering
+type teamEntry struct {
+	team  string
+	share float64
+	tasks []*models.Task
+}
+
+// teamHeap orders teams by share, then by their oldest pending task
+type teamHeap []*teamEntry
+
+func (h teamHeap) Len() int { return len(h) }
+
+func (h teamHeap) Less(i, j int) bool {
+	if h[i].share != h[j].share {
+		return h[i].share < h[j].share
+	}
+	return h[i].tasks[0].CreatedAt.Before(h[j].tasks[0].CreatedAt)
+}
+
+func (h teamHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *teamHeap) Push(x interface{}) { *h = append(*h, x.(*teamEntry)) }
+
+func (h *teamHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	*h = old[:n-1]
+	return entry
+}
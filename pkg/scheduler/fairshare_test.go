@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newTeamTask(id, team string, gpuCount int, createdAt time.Time) *models.Task {
+	return &models.Task{
+		ID:        id,
+		Team:      team,
+		Priority:  models.PriorityLow,
+		GPUCount:  gpuCount,
+		Status:    models.TaskStatusPending,
+		CreatedAt: createdAt,
+	}
+}
+
+func taskIDs(tasks []*models.Task) []string {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestFairShareOrderInterleavesTeams(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, _ := newTestEngine(t, gpus)
+	engine.SetFairShare(FairShareConfig{Enabled: true, HalfLife: time.Hour})
+
+	now := time.Now()
+	queue := []*models.Task{
+		newTeamTask("a1", "team-a", 1, now),
+		newTeamTask("a2", "team-a", 1, now.Add(time.Second)),
+		newTeamTask("a3", "team-a", 1, now.Add(2*time.Second)),
+		newTeamTask("b1", "team-b", 1, now.Add(3*time.Second)),
+	}
+
+	got := taskIDs(engine.fairShareOrder(queue))
+	expected := []string{"a1", "b1", "a2", "a3"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected order %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestFairShareOrderServesLightTeamFirst(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetFairShare(FairShareConfig{Enabled: true, HalfLife: time.Hour})
+
+	now := time.Now()
+	state := stateManager.GetState()
+	state.TeamUsage["heavy"] = &models.TeamUsage{Team: "heavy", GPUHours: 10, UpdatedAt: now}
+	state.TeamUsage["light"] = &models.TeamUsage{Team: "light", GPUHours: 1, UpdatedAt: now}
+
+	queue := []*models.Task{
+		newTeamTask("heavy-1", "heavy", 1, now),
+		newTeamTask("light-1", "light", 1, now.Add(time.Second)),
+	}
+
+	got := taskIDs(engine.fairShareOrder(queue))
+	if got[0] != "light-1" {
+		t.Errorf("Expected light team first, got %v", got)
+	}
+
+	// A larger weight entitles the heavy team to go first again
+	engine.SetFairShare(FairShareConfig{
+		Enabled:  true,
+		HalfLife: time.Hour,
+		Weights:  map[string]float64{"heavy": 100},
+	})
+	got = taskIDs(engine.fairShareOrder(queue))
+	if got[0] != "heavy-1" {
+		t.Errorf("Expected weighted heavy team first, got %v", got)
+	}
+}
+
+func TestFairShareDisabledKeepsFIFOOrder(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, _ := newTestEngine(t, gpus)
+
+	now := time.Now()
+	queue := []*models.Task{
+		newTeamTask("a1", "team-a", 1, now),
+		newTeamTask("a2", "team-a", 1, now.Add(time.Second)),
+		newTeamTask("b1", "team-b", 1, now.Add(2*time.Second)),
+	}
+
+	got := taskIDs(engine.fairShareOrder(queue))
+	expected := []string{"a1", "a2", "b1"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected order %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestUpdateUsageDecaysAndAccumulates(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetFairShare(FairShareConfig{Enabled: true, HalfLife: time.Hour})
+
+	now := time.Now()
+	state := stateManager.GetState()
+	state.TeamUsage["idle"] = &models.TeamUsage{Team: "idle", GPUHours: 8, UpdatedAt: now.Add(-time.Hour)}
+
+	started := now.Add(-30 * time.Minute)
+	running := newTeamTask("running", "busy", 2, now)
+	running.Status = models.TaskStatusRunning
+	running.StartedAt = &started
+	stateManager.AddTask(running)
+
+	engine.updateUsage(now)
+
+	if got := state.TeamUsage["idle"].GPUHours; got < 3.99 || got > 4.01 {
+		t.Errorf("Expected idle team usage to halve to 4, got %f", got)
+	}
+	if got := state.TeamUsage["busy"].GPUHours; got < 0.99 || got > 1.01 {
+		t.Errorf("Expected busy team usage of 1 GPU-hour, got %f", got)
+	}
+}
@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// DefaultMasterPort is the rendezvous port used when a distributed task
+// doesn't specify one
+const DefaultMasterPort = 29500
+
+// placeGang picks GPUsPerNode GPUs on each of Nodes distinct nodes, all or
+// nothing. The returned GPUs are grouped by node in rank order.
+func placeGang(policy PlacementPolicy, candidates []*models.GPU, spec *models.GangSpec, loads map[string]*NodeLoad) ([]*models.GPU, error) {
+	byNode := make(map[string][]*models.GPU)
+	for _, gpu := range candidates {
+		byNode[gpu.NodeID] = append(byNode[gpu.NodeID], gpu)
+	}
+
+	type nodeChoice struct {
+		nodeID string
+		gpus   []*models.GPU
+		score  float64
+	}
+
+	choices := make([]nodeChoice, 0, len(byNode))
+	for nodeID, gpus := range byNode {
+		sort.Slice(gpus, func(i, j int) bool {
+			return gpus[i].DeviceIndex < gpus[j].DeviceIndex
+		})
+
+		set, topoScore := bestConnectedSet(gpus, spec.GPUsPerNode)
+		if set == nil {
+			continue
+		}
+
+		load := &NodeLoad{NodeID: nodeID, Total: len(gpus)}
+		if l, exists := loads[nodeID]; exists {
+			load = l
+		}
+
+		choices = append(choices, nodeChoice{
+			nodeID: nodeID,
+			gpus:   set,
+			score:  policy.ScoreNode(load) + topoScore,
+		})
+	}
+
+	if len(choices) < spec.Nodes {
+		return nil, fmt.Errorf("insufficient nodes for gang: need %d nodes with %d GPUs, have %d",
+			spec.Nodes, spec.GPUsPerNode, len(choices))
+	}
+
+	sort.Slice(choices, func(i, j int) bool {
+		if choices[i].score != choices[j].score {
+			return choices[i].score > choices[j].score
+		}
+		return choices[i].nodeID < choices[j].nodeID
+	})
+
+	selected := make([]*models.GPU, 0, spec.Nodes*spec.GPUsPerNode)
+	for _, choice := range choices[:spec.Nodes] {
+		selected = append(selected, choice.gpus...)
+	}
+	return selected, nil
+}
+
+// buildGangMembers splits a gang's GPUs into per-node members, ranked in
+// the order the nodes appear
+func buildGangMembers(gpus []*models.GPU) []models.GangMember {
+	members := make([]models.GangMember, 0)
+	index := make(map[string]int)
+
+	for _, gpu := range gpus {
+		i, exists := index[gpu.NodeID]
+		if !exists {
+			i = len(members)
+			index[gpu.NodeID] = i
+			members = append(members, models.GangMember{
+				Rank:   i,
+				NodeID: gpu.NodeID,
+				Status: models.TaskStatusRunning,
+			})
+		}
+		members[i].GPUs = append(members[i].GPUs, gpu.ID)
+	}
+
+	return members
+}
+
+// RendezvousEnv returns the environment variables a gang member needs to
+// join the distributed job. The agent launches one process per node, meant
+// to be a launcher such as torchrun, which starts NPROC_PER_NODE workers
+// and gives each its own WORLD_SIZE and RANK.
+func RendezvousEnv(task *models.Task, member *models.GangMember, masterAddr string) map[string]string {
+	port := task.Gang.MasterPort
+	if port == 0 {
+		port = DefaultMasterPort
+	}
+
+	return map[string]string{
+		"MASTER_ADDR":    masterAddr,
+		"MASTER_PORT":    strconv.Itoa(port),
+		"NNODES":         strconv.Itoa(task.Gang.Nodes),
+		"NODE_RANK":      strconv.Itoa(member.Rank),
+		"NPROC_PER_NODE": strconv.Itoa(task.Gang.GPUsPerNode),
+	}
+}
+
+// ReleaseGangMember records the outcome of one member of a distributed
+// task. A failed member tears down the whole gang; the task succeeds once
+// every member has succeeded.
+func (e *Engine) ReleaseGangMember(taskID, nodeID string, exit TaskExit) error {
+	task, err := e.state.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if task.Status != models.TaskStatusRunning {
+		return fmt.Errorf("task is not running: %s", taskID)
+	}
+
+	var member *models.GangMember
+	for i := range task.GangMembers {
+		if task.GangMembers[i].NodeID == nodeID {
+			member = &task.GangMembers[i]
+			break
+		}
+	}
+	if member == nil || member.Status != models.TaskStatusRunning {
+		return fmt.Errorf("no running gang member of task %s on %s", taskID, nodeID)
+	}
+
+	// A gang stopped on request is settled as a whole, agents stop the
+	// remaining members once the task is no longer running
+	if task.StopRequest != nil {
+		e.settleStoppedTaskLocked(task)
+		return nil
+	}
+
+	member.Status = exit.Status
+	state.Version++
+	state.UpdatedAt = time.Now()
+
+	if exit.Status != models.TaskStatusSuccess {
+		// Tear down the rest of the gang, agents stop the remaining
+		// members once the task is no longer running
+		for i := range task.GangMembers {
+			if task.GangMembers[i].Status == models.TaskStatusRunning {
+				task.GangMembers[i].Status = models.TaskStatusFailed
+			}
+		}
+
+		msg := fmt.Sprintf("rank %d on %s failed", member.Rank, nodeID)
+		if exit.Error != nil {
+			msg = fmt.Sprintf("%s: %s", msg, *exit.Error)
+		}
+
+		// A member killed for running too long times out the whole gang
+		final := models.TaskStatusFailed
+		if exit.Status == models.TaskStatusTimeout {
+			final = models.TaskStatusTimeout
+		}
+		e.endAttemptLocked(task, TaskExit{
+			Status:   final,
+			Failure:  exit.Failure,
+			ExitCode: exit.ExitCode,
+			Error:    &msg,
+		})
+		return nil
+	}
+
+	for _, m := range task.GangMembers {
+		if m.Status != models.TaskStatusSuccess {
+			e.logger.Info("Gang member finished",
+				zap.String("task_id", taskID),
+				zap.String("node_id", nodeID),
+				zap.Int("rank", member.Rank),
+			)
+			return nil
+		}
+	}
+
+	e.endAttemptLocked(task, TaskExit{Status: models.TaskStatusSuccess})
+	return nil
+}
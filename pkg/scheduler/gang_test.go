@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/logger"
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// newTestEngine creates an engine over the given GPUs with a 70/30 quota
+func newTestEngine(t testing.TB, gpus map[string]*models.GPU) (*Engine, *StateManager) {
+	t.Helper()
+
+	log, _ := logger.New(logger.Config{
+		Level:  "error",
+		Format: "json",
+		Output: "stderr",
+	})
+
+	stateManager := NewStateManager(t.TempDir())
+	for _, gpu := range gpus {
+		stateManager.AddGPU(gpu)
+	}
+	stateManager.SetQuota(0.7, 0.3)
+
+	return NewEngine(stateManager, log), stateManager
+}
+
+func newGangTask(id string, nodes, gpusPerNode int) *models.Task {
+	return &models.Task{
+		ID:       id,
+		Type:     models.TaskTypeDistributed,
+		Priority: models.PriorityHigh,
+		GPUCount: nodes * gpusPerNode,
+		Gang:     &models.GangSpec{Nodes: nodes, GPUsPerNode: gpusPerNode},
+		Command:  "train",
+		Status:   models.TaskStatusPending,
+	}
+}
+
+func TestScheduleGangTask(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b", "node-c"}, 4, []int{0, 3, 0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	task := newGangTask("gang-1", 2, 2)
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule gang task: %v", err)
+	}
+
+	if len(task.GangMembers) != 2 {
+		t.Fatalf("Expected 2 gang members, got %d", len(task.GangMembers))
+	}
+	for rank, member := range task.GangMembers {
+		if member.Rank != rank {
+			t.Errorf("Expected member rank %d, got %d", rank, member.Rank)
+		}
+		if member.NodeID == "node-b" {
+			t.Errorf("Expected node-b (1 idle GPU) to be skipped")
+		}
+		if len(member.GPUs) != 2 {
+			t.Errorf("Expected 2 GPUs on %s, got %d", member.NodeID, len(member.GPUs))
+		}
+	}
+	if task.GangMembers[0].NodeID == task.GangMembers[1].NodeID {
+		t.Error("Expected gang members on distinct nodes")
+	}
+
+	env := RendezvousEnv(task, &task.GangMembers[1], "node-a")
+	if env["NNODES"] != "2" || env["NODE_RANK"] != "1" || env["NPROC_PER_NODE"] != "2" || env["MASTER_PORT"] != "29500" {
+		t.Errorf("Unexpected rendezvous env: %v", env)
+	}
+	if _, exists := env["WORLD_SIZE"]; exists {
+		t.Error("Expected the launcher to set the per-process WORLD_SIZE")
+	}
+}
+
+func TestScheduleGangTaskAllOrNothing(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b"}, 4, []int{0, 3})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	task := newGangTask("gang-1", 2, 2)
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err == nil {
+		t.Fatal("Expected gang scheduling to fail with only one eligible node")
+	}
+
+	for _, gpu := range gpus {
+		if gpu.CurrentTask != nil {
+			t.Errorf("Expected no GPUs allocated, %s runs %s", gpu.ID, *gpu.CurrentTask)
+		}
+	}
+}
+
+func TestReleaseGangMemberFailureTearsDownGang(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b"}, 2, []int{0, 0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	task := newGangTask("gang-1", 2, 2)
+	task.Priority = models.PriorityLow
+	stateManager.AddTask(task)
+	stateManager.SetQuota(0, 1)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule gang task: %v", err)
+	}
+
+	// First member succeeds, gang keeps running
+	first := task.GangMembers[0].NodeID
+	if err := engine.ReleaseGangMember("gang-1", first, TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+		t.Fatalf("Failed to release gang member: %v", err)
+	}
+	if task.Status != models.TaskStatusRunning {
+		t.Fatalf("Expected gang to keep running, got %s", task.Status)
+	}
+
+	// Second member fails, whole gang fails
+	second := task.GangMembers[1].NodeID
+	errMsg := "exit status 1"
+	if err := engine.ReleaseGangMember("gang-1", second, TaskExit{Status: models.TaskStatusFailed, Error: &errMsg}); err != nil {
+		t.Fatalf("Failed to release gang member: %v", err)
+	}
+	if task.Status != models.TaskStatusFailed {
+		t.Errorf("Expected gang task failed, got %s", task.Status)
+	}
+	for _, gpu := range gpus {
+		if gpu.Status != models.GPUStatusIdle {
+			t.Errorf("Expected GPU %s to be idle, got %s", gpu.ID, gpu.Status)
+		}
+	}
+	if quota := stateManager.GetState().Quota; quota.BatchUsed != 0 {
+		t.Errorf("Expected batch quota released, used %d", quota.BatchUsed)
+	}
+}
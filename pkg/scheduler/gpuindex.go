@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// gpuIndex tracks the idle GPUs by model and node, and the load of every
+// node, so placing a task only looks at the GPUs it could get
+type gpuIndex struct {
+	idleByModel map[string]map[string]*models.GPU // model -> GPU ID -> GPU
+	idleByNode  map[string]map[string]*models.GPU // node -> GPU ID -> GPU
+	loads       map[string]*NodeLoad
+	indexed     map[string]indexedGPU // GPU ID -> GPU as last indexed
+}
+
+// indexedGPU is what the index recorded about a GPU
+type indexedGPU struct {
+	gpu    *models.GPU
+	nodeID string
+	model  string
+	status models.GPUStatus
+}
+
+// newGPUIndex creates an empty index
+func newGPUIndex() *gpuIndex {
+	return &gpuIndex{
+		idleByModel: make(map[string]map[string]*models.GPU),
+		idleByNode:  make(map[string]map[string]*models.GPU),
+		loads:       make(map[string]*NodeLoad),
+		indexed:     make(map[string]indexedGPU),
+	}
+}
+
+// update records a GPU's current node, model and status
+func (x *gpuIndex) update(gpu *models.GPU) {
+	x.remove(gpu.ID)
+
+	entry := indexedGPU{gpu: gpu, nodeID: gpu.NodeID, model: gpu.Model, status: gpu.Status}
+	x.indexed[gpu.ID] = entry
+
+	load, exists := x.loads[entry.nodeID]
+	if !exists {
+		load = &NodeLoad{NodeID: entry.nodeID}
+		x.loads[entry.nodeID] = load
+	}
+	load.Total++
+
+	switch entry.status {
+	case models.GPUStatusBusy:
+		load.Busy++
+	case models.GPUStatusIdle:
+		addToSet(x.idleByModel, entry.model, gpu)
+		addToSet(x.idleByNode, entry.nodeID, gpu)
+	}
+}
+
+// remove forgets a GPU
+func (x *gpuIndex) remove(gpuID string) {
+	entry, exists := x.indexed[gpuID]
+	if !exists {
+		return
+	}
+	delete(x.indexed, gpuID)
+
+	load := x.loads[entry.nodeID]
+	load.Total--
+	if load.Total == 0 {
+		delete(x.loads, entry.nodeID)
+	}
+
+	switch entry.status {
+	case models.GPUStatusBusy:
+		load.Busy--
+	case models.GPUStatusIdle:
+		removeFromSet(x.idleByModel, entry.model, gpuID)
+		removeFromSet(x.idleByNode, entry.nodeID, gpuID)
+	}
+}
+
+// idleFor returns the idle GPUs of the models the task accepts, on the
+// nodes its selector and affinity rules allow if it has any
+func (x *gpuIndex) idleFor(task *models.Task, fit *nodeFit) []*models.GPU {
+	gpus := make([]*models.GPU, 0)
+
+	switch {
+	case task.GPUModel != nil:
+		for _, gpu := range x.idleByModel[*task.GPUModel] {
+			gpus = append(gpus, gpu)
+		}
+	case len(task.GPUModels) > 0:
+		for _, model := range task.GPUModels {
+			for _, gpu := range x.idleByModel[model] {
+				gpus = append(gpus, gpu)
+			}
+		}
+	case fit != nil:
+		for nodeID, allowed := range fit.allowed {
+			if !allowed {
+				continue
+			}
+			for _, gpu := range x.idleByNode[nodeID] {
+				gpus = append(gpus, gpu)
+			}
+		}
+	default:
+		for _, idle := range x.idleByModel {
+			for _, gpu := range idle {
+				gpus = append(gpus, gpu)
+			}
+		}
+	}
+	return gpus
+}
+
+// addToSet adds a GPU to the set under key
+func addToSet(sets map[string]map[string]*models.GPU, key string, gpu *models.GPU) {
+	set, exists := sets[key]
+	if !exists {
+		set = make(map[string]*models.GPU)
+		sets[key] = set
+	}
+	set[gpu.ID] = gpu
+}
+
+// removeFromSet removes a GPU from the set under key, dropping the set once
+// empty
+func removeFromSet(sets map[string]map[string]*models.GPU, key, gpuID string) {
+	delete(sets[key], gpuID)
+	if len(sets[key]) == 0 {
+		delete(sets, key)
+	}
+}
+
+// syncGPULocked brings the GPU index in line with a GPU's status (must hold
+// lock)
+func (sm *StateManager) syncGPULocked(gpu *models.GPU) {
+	sm.state.gpuIndex.update(gpu)
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// SetAgentTimeout sets how long an agent may go without a heartbeat before
+// it is considered lost, zero disables the check
+func (e *Engine) SetAgentTimeout(timeout time.Duration) {
+	e.agentTimeout = timeout
+}
+
+// detectLostAgents marks agents without a recent heartbeat offline, along
+// with their GPUs, and fails the attempts of the tasks running on them
+func (e *Engine) detectLostAgents(now time.Time) {
+	if e.agentTimeout <= 0 {
+		return
+	}
+
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	lost := 0
+	for _, agent := range state.Agents {
+		// Agents get a full timeout to reconnect after a scheduler restart
+		lastSeen := agent.LastHeartbeat
+		if lastSeen.Before(e.startedAt) {
+			lastSeen = e.startedAt
+		}
+		if agent.Status != models.AgentStatusOnline || now.Sub(lastSeen) <= e.agentTimeout {
+			continue
+		}
+
+		agent.Status = models.AgentStatusOffline
+		lost++
+
+		e.logger.Warn("Agent lost",
+			zap.String("agent_id", agent.ID),
+			zap.Time("last_heartbeat", agent.LastHeartbeat),
+		)
+
+		for _, task := range state.Tasks {
+			if task.Status != models.TaskStatusRunning || !runsOnLocked(state, task, agent.ID) {
+				continue
+			}
+			if task.StopRequest != nil {
+				e.settleStoppedTaskLocked(task)
+				continue
+			}
+
+			msg := fmt.Sprintf("agent %s lost", agent.ID)
+			e.endAttemptLocked(task, TaskExit{
+				Status:  models.TaskStatusFailed,
+				Failure: models.FailureAgentLost,
+				Error:   &msg,
+			})
+		}
+
+		// Heartbeats bring the GPUs back once the agent returns
+		for _, gpu := range state.GPUs {
+			if gpu.NodeID == agent.ID {
+				gpu.Status = models.GPUStatusOffline
+				gpu.CurrentTask = nil
+				gpu.Tenants = nil
+				gpu.UpdatedAt = now
+				e.state.syncGPULocked(gpu)
+			}
+		}
+	}
+
+	if lost > 0 {
+		e.state.incrementVersion()
+		e.state.triggerSnapshot()
+	}
+}
+
+// runsOnLocked reports whether any of a task's GPUs are on the given node
+// (must hold lock)
+func runsOnLocked(state *State, task *models.Task, nodeID string) bool {
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := state.GPUs[gpuID]; exists && gpu.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// StopReasonDrained marks tasks stopped to drain their node
+const StopReasonDrained = "drained"
+
+// drainGracePeriod is the time tasks stopped by a drain get between SIGTERM
+// and SIGKILL
+const drainGracePeriod = 30 * time.Second
+
+// Cordon stops new tasks from being placed on an agent's node. Tasks
+// already running there are left alone.
+func (e *Engine) Cordon(agentID string) (*models.Agent, error) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	agent, exists := state.Agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+
+	if agent.Maintenance == "" {
+		agent.Maintenance = models.MaintenanceCordoned
+		e.logger.Info("Agent cordoned", zap.String("agent_id", agentID))
+	}
+
+	e.state.incrementVersion()
+	e.state.triggerSnapshot()
+	return agent, nil
+}
+
+// Drain cordons an agent's node and moves its tasks off: running tasks get
+// until the timeout to finish, then they are stopped and requeued. The node
+// is drained once no task runs on it.
+func (e *Engine) Drain(agentID string, timeout time.Duration) (*models.Agent, error) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	agent, exists := state.Agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+
+	now := time.Now()
+	deadline := now.Add(timeout)
+	agent.Maintenance = models.MaintenanceDraining
+	agent.DrainDeadline = &deadline
+
+	e.logger.Info("Agent draining",
+		zap.String("agent_id", agentID),
+		zap.Duration("timeout", timeout),
+	)
+
+	e.drainAgentLocked(agent, now)
+
+	e.state.incrementVersion()
+	e.state.triggerSnapshot()
+	return agent, nil
+}
+
+// Uncordon returns an agent's node to service. Tasks a drain already asked
+// to stop are still stopped.
+func (e *Engine) Uncordon(agentID string) (*models.Agent, error) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	agent, exists := state.Agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+
+	if agent.Maintenance != "" {
+		agent.Maintenance = ""
+		agent.DrainDeadline = nil
+		e.logger.Info("Agent uncordoned", zap.String("agent_id", agentID))
+	}
+
+	e.state.incrementVersion()
+	e.state.triggerSnapshot()
+	return agent, nil
+}
+
+// SetTaints replaces the taints of an agent's node. Tasks already running
+// there are left alone.
+func (e *Engine) SetTaints(agentID string, taints []models.Taint) (*models.Agent, error) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	agent, exists := state.Agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+	agent.Taints = taints
+
+	e.state.incrementVersion()
+	e.state.triggerSnapshot()
+	return agent, nil
+}
+
+// drainAgents moves the tasks off draining nodes
+func (e *Engine) drainAgents(now time.Time) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	changed := false
+	for _, agent := range state.Agents {
+		if agent.Maintenance == models.MaintenanceDraining && e.drainAgentLocked(agent, now) {
+			changed = true
+		}
+	}
+
+	if changed {
+		e.state.incrementVersion()
+		e.state.triggerSnapshot()
+	}
+}
+
+// drainAgentLocked stops the tasks still running on a draining node once
+// its deadline passed, and marks the node drained when none is left. It
+// reports whether anything changed (must hold lock).
+func (e *Engine) drainAgentLocked(agent *models.Agent, now time.Time) bool {
+	state := e.state.state
+	expired := agent.DrainDeadline == nil || !now.Before(*agent.DrainDeadline)
+
+	changed := false
+	running := 0
+	for _, task := range state.Tasks {
+		if task.Status != models.TaskStatusRunning || !runsOnLocked(state, task, agent.ID) {
+			continue
+		}
+		running++
+
+		if expired && task.StopRequest == nil {
+			task.StopRequest = &models.StopRequest{
+				Reason:      StopReasonDrained,
+				GracePeriod: int(drainGracePeriod.Seconds()),
+				RequestedAt: now,
+			}
+			changed = true
+
+			e.logger.Info("Stopping task to drain agent",
+				zap.String("task_id", task.ID),
+				zap.String("agent_id", agent.ID),
+			)
+		}
+	}
+
+	if running == 0 {
+		agent.Maintenance = models.MaintenanceDrained
+		agent.DrainDeadline = nil
+		changed = true
+
+		e.logger.Info("Agent drained", zap.String("agent_id", agent.ID))
+	}
+	return changed
+}
+
+// nodeRejectionLocked returns the filter keeping a task off a node, or ""
+// if new tasks may be placed there: the node must be in service and the
+// task must tolerate all of its taints (must hold lock)
+func (e *Engine) nodeRejectionLocked(task *models.Task, nodeID string) string {
+	agent, exists := e.state.state.Agents[nodeID]
+	if !exists {
+		return ""
+	}
+	if agent.Maintenance != "" {
+		return FilterMaintenance
+	}
+	for _, taint := range agent.Taints {
+		if !tolerates(task.Tolerations, taint) {
+			return FilterTaint
+		}
+	}
+	return ""
+}
+
+// tolerates reports whether any of the tolerations matches a taint
+func tolerates(tolerations []models.Toleration, taint models.Taint) bool {
+	for _, toleration := range tolerations {
+		if toleration.Key != taint.Key {
+			continue
+		}
+		if toleration.Operator == models.TolerationOpExists || toleration.Value == taint.Value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestCordonedNodeGetsNoNewTasks(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": nil,
+		"node-b": nil,
+	})
+
+	for _, nodeID := range []string{"node-a", "node-b"} {
+		if _, err := engine.Cordon(nodeID); err != nil {
+			t.Fatalf("Failed to cordon %s: %v", nodeID, err)
+		}
+	}
+	if _, err := engine.Cordon("node-x"); err == nil {
+		t.Error("Expected cordoning an unknown agent to fail")
+	}
+
+	task := newAffinityTask("task-1")
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err == nil {
+		t.Fatal("Expected no node to accept the task while all are cordoned")
+	}
+
+	agent, err := engine.Uncordon("node-b")
+	if err != nil {
+		t.Fatalf("Failed to uncordon: %v", err)
+	}
+	if agent.Maintenance != "" {
+		t.Errorf("Expected node-b back in service, got %q", agent.Maintenance)
+	}
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule after uncordon: %v", err)
+	}
+	if node := nodeOf(stateManager, task); node != "node-b" {
+		t.Errorf("Expected the task on node-b, got %s", node)
+	}
+}
+
+func TestTaintsRequireTolerations(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": nil,
+	})
+	if _, err := engine.SetTaints("node-a", []models.Taint{{Key: "dedicated", Value: "research"}}); err != nil {
+		t.Fatalf("Failed to set taints: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		tolerations []models.Toleration
+		want        bool
+	}{
+		{"none", nil, false},
+		{"other value", []models.Toleration{{Key: "dedicated", Value: "prod"}}, false},
+		{"equal", []models.Toleration{{Key: "dedicated", Value: "research"}}, true},
+		{"exists", []models.Toleration{{Key: "dedicated", Operator: models.TolerationOpExists}}, true},
+	}
+
+	for _, tt := range tests {
+		task := newAffinityTask("task-" + tt.name)
+		task.Tolerations = tt.tolerations
+		stateManager.AddTask(task)
+
+		err := engine.scheduleTask(task)
+		if got := err == nil; got != tt.want {
+			t.Errorf("%s: scheduled = %v, want %v (%v)", tt.name, got, tt.want, err)
+		}
+		if err == nil {
+			if err := engine.ReleaseTask(task.ID, TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+				t.Fatalf("Failed to release %s: %v", task.ID, err)
+			}
+		}
+	}
+}
+
+func TestDrainStopsTasksAfterTimeout(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": nil,
+	})
+
+	task := newAffinityTask("task-1")
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+
+	// The task may finish on its own within the timeout
+	agent, err := engine.Drain("node-a", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to drain: %v", err)
+	}
+	if agent.Maintenance != models.MaintenanceDraining || task.StopRequest != nil {
+		t.Fatalf("Expected a draining node with the task untouched, got %q and %+v",
+			agent.Maintenance, task.StopRequest)
+	}
+
+	// Past the deadline it is stopped
+	engine.drainAgents(agent.DrainDeadline.Add(time.Second))
+	if task.StopRequest == nil || task.StopRequest.Reason != StopReasonDrained {
+		t.Fatalf("Expected the task to be stopped for the drain, got %+v", task.StopRequest)
+	}
+	if agent.Maintenance != models.MaintenanceDraining {
+		t.Errorf("Expected the node to drain until the task stops, got %q", agent.Maintenance)
+	}
+
+	// The agent confirms the stop and the task waits for another node
+	if err := engine.ReleaseTask(task.ID, TaskExit{Status: models.TaskStatusFailed}); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	if task.Status != models.TaskStatusPending || task.StatusReason != StopReasonDrained {
+		t.Errorf("Expected the task requeued, got %s (%s)", task.Status, task.StatusReason)
+	}
+
+	engine.drainAgents(time.Now())
+	if agent.Maintenance != models.MaintenanceDrained || agent.DrainDeadline != nil {
+		t.Errorf("Expected the node drained, got %q", agent.Maintenance)
+	}
+	if err := engine.scheduleTask(task); err == nil {
+		t.Error("Expected a drained node to accept no tasks")
+	}
+
+	// Maintenance survives the agent re-registering
+	stateManager.RegisterAgent(&models.Agent{ID: "node-a", Status: models.AgentStatusOnline})
+	if agent, _ := stateManager.GetAgent("node-a"); agent.Maintenance != models.MaintenanceDrained {
+		t.Errorf("Expected the node to stay drained after re-registering, got %q", agent.Maintenance)
+	}
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// Placement policy names
+const (
+	PlacementBinPack = "binpack"
+	PlacementSpread  = "spread"
+	PlacementRandom  = "random"
+)
+
+// NodeLoad summarizes GPU usage on a single node
+type NodeLoad struct {
+	NodeID string
+	Total  int
+	Busy   int
+}
+
+// Utilization returns the fraction of busy GPUs on the node
+func (l *NodeLoad) Utilization() float64 {
+	if l.Total == 0 {
+		return 0
+	}
+	return float64(l.Busy) / float64(l.Total)
+}
+
+// PlacementPolicy decides which node a task's GPUs are taken from
+type PlacementPolicy interface {
+	// Name returns the policy name used in configuration
+	Name() string
+
+	// ScoreNode rates placing one more GPU on the node, higher is preferred
+	ScoreNode(load *NodeLoad) float64
+}
+
+// NewPlacementPolicy returns the placement policy with the given name
+func NewPlacementPolicy(name string) (PlacementPolicy, error) {
+	switch name {
+	case PlacementBinPack:
+		return binPackPolicy{}, nil
+	case PlacementSpread:
+		return spreadPolicy{}, nil
+	case PlacementRandom, "":
+		return randomPolicy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown placement policy: %s", name)
+	}
+}
+
+// binPackPolicy fills the busiest node first to keep whole nodes free
+type binPackPolicy struct{}
+
+func (binPackPolicy) Name() string { return PlacementBinPack }
+
+func (binPackPolicy) ScoreNode(load *NodeLoad) float64 {
+	return load.Utilization()
+}
+
+// spreadPolicy balances load across nodes
+type spreadPolicy struct{}
+
+func (spreadPolicy) Name() string { return PlacementSpread }
+
+func (spreadPolicy) ScoreNode(load *NodeLoad) float64 {
+	return -load.Utilization()
+}
+
+// randomPolicy places GPUs at random (Go 1.20+ auto-seeds global RNG)
+type randomPolicy struct{}
+
+func (randomPolicy) Name() string { return PlacementRandom }
+
+func (randomPolicy) ScoreNode(load *NodeLoad) float64 {
+	return rand.Float64()
+}
+
+// computeNodeLoads counts total and busy GPUs per node
+func computeNodeLoads(allGPUs map[string]*models.GPU) map[string]*NodeLoad {
+	loads := make(map[string]*NodeLoad)
+	for _, gpu := range allGPUs {
+		load, exists := loads[gpu.NodeID]
+		if !exists {
+			load = &NodeLoad{NodeID: gpu.NodeID}
+			loads[gpu.NodeID] = load
+		}
+		load.Total++
+		if gpu.Status == models.GPUStatusBusy {
+			load.Busy++
+		}
+	}
+	return loads
+}
+
+// placeGPUs picks count GPUs from the candidates one at a time, each time
+// taking a GPU from the node the policy scores highest
+func placeGPUs(policy PlacementPolicy, candidates []*models.GPU, count int, loads map[string]*NodeLoad) []*models.GPU {
+	// Group candidates by node in a deterministic order
+	byNode := make(map[string][]*models.GPU)
+	for _, gpu := range candidates {
+		byNode[gpu.NodeID] = append(byNode[gpu.NodeID], gpu)
+	}
+	nodeIDs := make([]string, 0, len(byNode))
+	for nodeID, gpus := range byNode {
+		sort.Slice(gpus, func(i, j int) bool {
+			return gpus[i].DeviceIndex < gpus[j].DeviceIndex
+		})
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	// Work on a copy of the loads so picks are reflected in later scores
+	working := make(map[string]*NodeLoad, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		load := NodeLoad{NodeID: nodeID, Total: len(byNode[nodeID])}
+		if l, exists := loads[nodeID]; exists {
+			load = *l
+		}
+		working[nodeID] = &load
+	}
+
+	selected := make([]*models.GPU, 0, count)
+	for len(selected) < count {
+		best := ""
+		bestScore := 0.0
+		found := false
+		for _, nodeID := range nodeIDs {
+			if len(byNode[nodeID]) == 0 {
+				continue
+			}
+			score := policy.ScoreNode(working[nodeID])
+			if !found || score > bestScore {
+				best = nodeID
+				bestScore = score
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+
+		selected = append(selected, byNode[best][0])
+		byNode[best] = byNode[best][1:]
+		working[best].Busy++
+	}
+
+	return selected
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// newTestCluster builds GPUs for the given nodes, marking the first busy[i]
+// GPUs of node i as busy
+func newTestCluster(nodes []string, perNode int, busy []int) map[string]*models.GPU {
+	gpus := make(map[string]*models.GPU)
+	for n, nodeID := range nodes {
+		for i := 0; i < perNode; i++ {
+			gpu := &models.GPU{
+				ID:          fmt.Sprintf("%s-gpu-%d", nodeID, i),
+				NodeID:      nodeID,
+				DeviceIndex: i,
+				Model:       "TestGPU",
+				Memory:      16000,
+				Status:      models.GPUStatusIdle,
+				UpdatedAt:   time.Now(),
+			}
+			if i < busy[n] {
+				gpu.Status = models.GPUStatusBusy
+			}
+			gpus[gpu.ID] = gpu
+		}
+	}
+	return gpus
+}
+
+func idleGPUs(gpus map[string]*models.GPU) []*models.GPU {
+	idle := make([]*models.GPU, 0)
+	for _, gpu := range gpus {
+		if gpu.Status == models.GPUStatusIdle {
+			idle = append(idle, gpu)
+		}
+	}
+	return idle
+}
+
+func TestPlaceGPUsBinPack(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b", "node-c"}, 4, []int{1, 3, 0})
+
+	selected := placeGPUs(binPackPolicy{}, idleGPUs(gpus), 2, computeNodeLoads(gpus))
+	if len(selected) != 2 {
+		t.Fatalf("Expected 2 GPUs, got %d", len(selected))
+	}
+
+	// node-b has one idle GPU left and is filled first, then node-b is
+	// full so the next busiest node (node-a) is used
+	if selected[0].NodeID != "node-b" {
+		t.Errorf("Expected first GPU on node-b, got %s", selected[0].NodeID)
+	}
+	if selected[1].NodeID != "node-a" {
+		t.Errorf("Expected second GPU on node-a, got %s", selected[1].NodeID)
+	}
+}
+
+func TestPlaceGPUsSpread(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b", "node-c"}, 4, []int{1, 3, 0})
+
+	selected := placeGPUs(spreadPolicy{}, idleGPUs(gpus), 2, computeNodeLoads(gpus))
+	if len(selected) != 2 {
+		t.Fatalf("Expected 2 GPUs, got %d", len(selected))
+	}
+
+	if selected[0].NodeID != "node-c" {
+		t.Errorf("Expected first GPU on node-c, got %s", selected[0].NodeID)
+	}
+	if selected[1].NodeID != "node-a" {
+		t.Errorf("Expected second GPU on node-a, got %s", selected[1].NodeID)
+	}
+}
+
+func TestNewPlacementPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  string
+		shouldErr bool
+	}{
+		{name: "binpack", expected: PlacementBinPack},
+		{name: "spread", expected: PlacementSpread},
+		{name: "random", expected: PlacementRandom},
+		{name: "", expected: PlacementRandom},
+		{name: "first-fit", shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPlacementPolicy(tt.name)
+			if (err != nil) != tt.shouldErr {
+				t.Fatalf("NewPlacementPolicy() error = %v, shouldErr %v", err, tt.shouldErr)
+			}
+			if err == nil && policy.Name() != tt.expected {
+				t.Errorf("NewPlacementPolicy() = %s, want %s", policy.Name(), tt.expected)
+			}
+		})
+	}
+}
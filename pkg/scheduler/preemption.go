@@ -0,0 +1,285 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// StopReasonPreempted marks tasks stopped to make room for higher priority work
+const StopReasonPreempted = "preempted"
+
+// stopConfirmTimeout is how long after the grace period the engine waits for
+// an agent to confirm a stop before checking whether the task still runs
+const stopConfirmTimeout = 30 * time.Second
+
+// PreemptionConfig controls preemption of low priority tasks
+type PreemptionConfig struct {
+	Enabled bool
+	// GracePeriod is the time victims get between SIGTERM and SIGKILL
+	GracePeriod time.Duration
+}
+
+// SetPreemption configures preemption of low priority tasks
+func (e *Engine) SetPreemption(cfg PreemptionConfig) {
+	e.preemption = cfg
+}
+
+// preemptFor picks victims among the preemptible tasks of lower priority
+// classes whose GPUs would let the task be placed and asks their agents to
+// stop them. Victims of the lowest class are picked first, then those
+// losing the fewest GPUs, the youngest first among equals. It returns false
+// if no set of victims would make room.
+func (e *Engine) preemptFor(task *models.Task) bool {
+	class := e.classOf(task)
+	if !e.preemption.Enabled || !class.Preempt {
+		return false
+	}
+
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// Simulate on copies of the GPUs and of the CPU and memory in use,
+	// counting the resources of tasks that are already being stopped as free
+	sim := make(map[string]*models.GPU, len(state.GPUs))
+	for id, gpu := range state.GPUs {
+		copied := *gpu
+		sim[id] = &copied
+	}
+	hosts := e.simHostUsageLocked()
+
+	victims := make([]*models.Task, 0)
+	for _, t := range state.Tasks {
+		if t.Status != models.TaskStatusRunning {
+			continue
+		}
+		if t.StopRequest != nil {
+			freeSimGPUs(sim, t)
+			removeHostUsage(hosts, state, t)
+			continue
+		}
+		// Stopping a task on a shared GPU rarely frees the whole GPU
+		if t.GPUShare != nil {
+			continue
+		}
+		if victim := e.classOf(t); victim.Preemptible && victim.Value < class.Value {
+			victims = append(victims, t)
+		}
+	}
+
+	// Room is already being made by earlier preemptions
+	if e.fitsIn(task, sim, hosts) {
+		return true
+	}
+
+	sort.Slice(victims, func(i, j int) bool {
+		if vi, vj := e.classOf(victims[i]).Value, e.classOf(victims[j]).Value; vi != vj {
+			return vi < vj
+		}
+		if victims[i].GPUCount != victims[j].GPUCount {
+			return victims[i].GPUCount < victims[j].GPUCount
+		}
+		return startedAt(victims[i]).After(startedAt(victims[j]))
+	})
+
+	chosen := make([]*models.Task, 0)
+	for _, victim := range victims {
+		freeSimGPUs(sim, victim)
+		removeHostUsage(hosts, state, victim)
+		chosen = append(chosen, victim)
+		if e.fitsIn(task, sim, hosts) {
+			break
+		}
+	}
+	if len(chosen) == 0 || !e.fitsIn(task, sim, hosts) {
+		return false
+	}
+
+	// Drop victims that turned out not to be needed, largest first
+	for i := len(chosen) - 1; i >= 0; i-- {
+		occupySimGPUs(sim, chosen[i])
+		addHostUsage(hosts, state, chosen[i])
+		if e.fitsIn(task, sim, hosts) {
+			chosen = append(chosen[:i], chosen[i+1:]...)
+			continue
+		}
+		freeSimGPUs(sim, chosen[i])
+		removeHostUsage(hosts, state, chosen[i])
+	}
+
+	now := time.Now()
+	for _, victim := range chosen {
+		victim.StopRequest = &models.StopRequest{
+			Reason:      StopReasonPreempted,
+			GracePeriod: int(e.preemption.GracePeriod.Seconds()),
+			RequestedAt: now,
+			RequestedBy: task.ID,
+		}
+
+		e.logger.Info("Preempting task",
+			zap.String("task_id", victim.ID),
+			zap.String("preemptor", task.ID),
+			zap.Int("gpu_count", victim.GPUCount),
+		)
+	}
+
+	state.Version++
+	state.UpdatedAt = now
+	return true
+}
+
+// fitsIn reports whether the task could be placed on the given GPUs with
+// the given CPU and memory in use (must hold lock)
+func (e *Engine) fitsIn(task *models.Task, gpus map[string]*models.GPU, hosts map[string]*hostUsage) bool {
+	_, err := e.findAvailableGPUs(task, gpus, e.hostFitFor(task, hosts))
+	return err == nil
+}
+
+// freeSimGPUs takes a task off its GPUs in a simulated GPU map
+func freeSimGPUs(sim map[string]*models.GPU, task *models.Task) {
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := sim[gpuID]; exists {
+			freeGPU(gpu, task.ID, gpu.UpdatedAt)
+		}
+	}
+}
+
+// occupySimGPUs marks a task's GPUs busy in a simulated GPU map
+func occupySimGPUs(sim map[string]*models.GPU, task *models.Task) {
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := sim[gpuID]; exists {
+			gpu.Status = models.GPUStatusBusy
+		}
+	}
+}
+
+// startedAt returns when a task started, or the zero time
+func startedAt(task *models.Task) time.Time {
+	if task.StartedAt == nil {
+		return time.Time{}
+	}
+	return *task.StartedAt
+}
+
+// requeueTaskLocked frees a stopped task's resources and puts it back in
+// the pending queue (must hold lock)
+func (e *Engine) requeueTaskLocked(task *models.Task, reason string) {
+	state := e.state.state
+
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := state.GPUs[gpuID]; exists {
+			freeGPU(gpu, task.ID, time.Now())
+			e.state.syncGPULocked(gpu)
+		}
+	}
+
+	e.releaseQuotaLocked(task)
+
+	if reason == StopReasonPreempted || reason == StopReasonReclaimed {
+		task.PreemptionCount++
+	}
+	now := time.Now()
+	task.Status = models.TaskStatusPending
+	task.StatusReason = reason
+	task.QueuedAt = &now
+	task.StopRequest = nil
+	task.Borrowed = false
+	task.AssignedGPUs = nil
+	task.AssignedModel = ""
+	task.GangMembers = nil
+	task.TopologyScore = nil
+	task.StartedAt = nil
+	e.state.syncTaskLocked(task)
+
+	state.Version++
+	state.UpdatedAt = time.Now()
+
+	e.logger.Info("Task requeued",
+		zap.String("task_id", task.ID),
+		zap.String("reason", reason),
+	)
+
+	e.Notify(EventTaskRequeued)
+}
+
+// settleStoppedTaskLocked handles a task whose stop request was carried
+// out: cancelled tasks are released for good, others are requeued unless
+// their retry policy has no attempts left for preemption (must hold lock)
+func (e *Engine) settleStoppedTaskLocked(task *models.Task) {
+	reason := task.StopRequest.Reason
+
+	if reason == StopReasonCancelled {
+		e.recordAttemptLocked(task, models.TaskStatusCancelled, "", nil, nil)
+		task.StopRequest = nil
+		task.StatusReason = StopReasonCancelled
+		e.releaseTaskLocked(task, models.TaskStatusCancelled, nil)
+		return
+	}
+
+	e.recordAttemptLocked(task, models.TaskStatusFailed, models.FailurePreempted, nil, &reason)
+	if task.Retry != nil && !retryable(task, models.FailurePreempted) {
+		msg := fmt.Sprintf("stopped (%s) with no retries left", reason)
+		task.StopRequest = nil
+		task.StatusReason = reason
+		e.releaseTaskLocked(task, models.TaskStatusFailed, &msg)
+		return
+	}
+	e.requeueTaskLocked(task, reason)
+}
+
+// expireStopRequests settles stopped tasks whose agents never confirmed
+// the stop but no longer run them, e.g. because the confirmation was lost.
+// Tasks an agent still runs keep their GPUs and are warned about, the
+// agent is sent the stop again with every heartbeat.
+func (e *Engine) expireStopRequests() {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	now := time.Now()
+	for _, task := range state.Tasks {
+		if task.Status != models.TaskStatusRunning || task.StopRequest == nil {
+			continue
+		}
+
+		deadline := task.StopRequest.RequestedAt.
+			Add(time.Duration(task.StopRequest.GracePeriod) * time.Second).
+			Add(stopConfirmTimeout)
+		if !now.After(deadline) {
+			continue
+		}
+
+		if stillRunningLocked(state, task) {
+			if !task.StopRequest.Overdue {
+				task.StopRequest.Overdue = true
+				e.logger.Warn("Stopped task still running past its deadline",
+					zap.String("task_id", task.ID),
+					zap.String("reason", task.StopRequest.Reason),
+				)
+			}
+			continue
+		}
+
+		e.logger.Warn("Stop not confirmed, settling task",
+			zap.String("task_id", task.ID),
+		)
+		e.settleStoppedTaskLocked(task)
+	}
+}
+
+// stillRunningLocked reports whether an online agent listed the task as
+// running in its latest heartbeat (must hold lock)
+func stillRunningLocked(state *State, task *models.Task) bool {
+	for _, nodeID := range taskNodesLocked(state, task) {
+		agent, exists := state.Agents[nodeID]
+		if exists && agent.Status == models.AgentStatusOnline && slices.Contains(agent.RunningTasks, task.ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestPreemptionPicksSmallestYoungestVictim(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetPreemption(PreemptionConfig{Enabled: true, GracePeriod: 10 * time.Second})
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 4
+	state.Quota.BatchQuota = 4
+
+	// Fill the node with batch tasks
+	batch := []struct {
+		id       string
+		gpuCount int
+		age      time.Duration
+	}{
+		{id: "batch-old", gpuCount: 1, age: time.Hour},
+		{id: "batch-young", gpuCount: 1, age: time.Minute},
+		{id: "batch-big", gpuCount: 2, age: time.Second},
+	}
+	for _, b := range batch {
+		task := &models.Task{
+			ID:       b.id,
+			Priority: models.PriorityLow,
+			GPUCount: b.gpuCount,
+			Command:  "batch",
+			Status:   models.TaskStatusPending,
+		}
+		stateManager.AddTask(task)
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", b.id, err)
+		}
+		started := time.Now().Add(-b.age)
+		task.StartedAt = &started
+	}
+
+	online := &models.Task{
+		ID:       "online-1",
+		Priority: models.PriorityHigh,
+		GPUCount: 1,
+		Command:  "serve",
+		Status:   models.TaskStatusPending,
+	}
+	stateManager.AddTask(online)
+	engine.processQueue([]*models.Task{online}, models.PriorityHigh, nil)
+
+	victim, _ := stateManager.GetTask("batch-young")
+	if victim.StopRequest == nil || victim.StopRequest.Reason != StopReasonPreempted {
+		t.Fatalf("Expected batch-young to be preempted, got %+v", victim.StopRequest)
+	}
+	for _, id := range []string{"batch-old", "batch-big"} {
+		if task, _ := stateManager.GetTask(id); task.StopRequest != nil {
+			t.Errorf("Expected %s not to be preempted", id)
+		}
+	}
+
+	// A second cycle must not pick more victims while the first stops
+	engine.processQueue([]*models.Task{online}, models.PriorityHigh, nil)
+	if task, _ := stateManager.GetTask("batch-old"); task.StopRequest != nil {
+		t.Error("Expected no additional victims while preemption is in progress")
+	}
+
+	// Agent confirms the stop, victim is requeued and GPUs are freed
+	engine.Start(time.Hour)
+	defer engine.Stop()
+	if err := engine.ReleaseTask("batch-young", TaskExit{Status: models.TaskStatusFailed}); err != nil {
+		t.Fatalf("Failed to release victim: %v", err)
+	}
+	if victim.Status != models.TaskStatusPending {
+		t.Errorf("Expected victim to be pending, got %s", victim.Status)
+	}
+	if victim.StatusReason != StopReasonPreempted || victim.PreemptionCount != 1 {
+		t.Errorf("Expected preempted reason and count 1, got %q and %d",
+			victim.StatusReason, victim.PreemptionCount)
+	}
+
+	// Releasing the victim wakes the loop, which places the online task
+	deadline := time.Now().Add(time.Second)
+	for {
+		state.mu.RLock()
+		running := online.Status == models.TaskStatusRunning
+		state.mu.RUnlock()
+		if running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected online task to be scheduled after preemption")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPreemptionDisabled(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 1, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 1
+	state.Quota.BatchQuota = 1
+
+	batch := &models.Task{ID: "batch-1", Priority: models.PriorityLow, GPUCount: 1, Status: models.TaskStatusPending}
+	stateManager.AddTask(batch)
+	if err := engine.scheduleTask(batch); err != nil {
+		t.Fatalf("Failed to schedule batch task: %v", err)
+	}
+
+	online := &models.Task{ID: "online-1", Priority: models.PriorityHigh, GPUCount: 1, Status: models.TaskStatusPending}
+	if engine.preemptFor(online) {
+		t.Error("Expected no preemption when disabled")
+	}
+	if batch.StopRequest != nil {
+		t.Error("Expected batch task not to be preempted")
+	}
+}
+
+func TestExpiredStopWaitsForAgent(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.RegisterAgent(&models.Agent{ID: "node-a", Status: models.AgentStatusOnline})
+	stateManager.GetState().Quota.BatchQuota = 2
+
+	task := &models.Task{
+		ID:       "batch-1",
+		Priority: models.PriorityLow,
+		GPUCount: 1,
+		Command:  "batch",
+		Status:   models.TaskStatusPending,
+	}
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+	task.StopRequest = &models.StopRequest{
+		Reason:      StopReasonPreempted,
+		GracePeriod: 10,
+		RequestedAt: time.Now().Add(-time.Hour),
+	}
+
+	// The agent still runs the task, so its GPU stays taken
+	if err := stateManager.UpdateAgentHeartbeat("node-a", []string{task.ID}); err != nil {
+		t.Fatalf("Failed to update heartbeat: %v", err)
+	}
+	engine.expireStopRequests()
+	if task.Status != models.TaskStatusRunning || !task.StopRequest.Overdue {
+		t.Fatalf("Expected an overdue running task, got %s", task.Status)
+	}
+	if gpu := stateManager.GetState().GPUs[task.AssignedGPUs[0]]; gpu.Status != models.GPUStatusBusy {
+		t.Errorf("Expected the task's GPU to stay busy, got %s", gpu.Status)
+	}
+
+	// Once the agent no longer lists it the task is requeued
+	if err := stateManager.UpdateAgentHeartbeat("node-a", nil); err != nil {
+		t.Fatalf("Failed to update heartbeat: %v", err)
+	}
+	engine.expireStopRequests()
+	if task.Status != models.TaskStatusPending || task.StopRequest != nil {
+		t.Errorf("Expected the task requeued, got %s", task.Status)
+	}
+}
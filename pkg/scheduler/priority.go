@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// classQueue is the queue of one priority class
+type classQueue struct {
+	class models.PriorityClass
+	tasks []*models.Task
+}
+
+// SetPriorityClasses sets the priority classes tasks may be submitted with
+func (e *Engine) SetPriorityClasses(classes []models.PriorityClass) {
+	e.classes = make(map[models.Priority]models.PriorityClass, len(classes))
+	for _, class := range classes {
+		e.classes[class.Name] = class
+	}
+}
+
+// PriorityClass returns a configured priority class by name
+func (e *Engine) PriorityClass(name models.Priority) (models.PriorityClass, bool) {
+	class, exists := e.classes[name]
+	return class, exists
+}
+
+// PriorityClasses returns the configured priority classes, highest first
+func (e *Engine) PriorityClasses() []models.PriorityClass {
+	classes := make([]models.PriorityClass, 0, len(e.classes))
+	for _, class := range e.classes {
+		classes = append(classes, class)
+	}
+	sortClasses(classes)
+	return classes
+}
+
+// sortClasses orders priority classes from the highest value down, by name
+// among equals
+func sortClasses(classes []models.PriorityClass) {
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].Value != classes[j].Value {
+			return classes[i].Value > classes[j].Value
+		}
+		return classes[i].Name < classes[j].Name
+	})
+}
+
+// classOf returns a task's priority class
+func (e *Engine) classOf(task *models.Task) models.PriorityClass {
+	return e.classNamed(task.Priority)
+}
+
+// classNamed returns a priority class by name. Classes that are no longer
+// configured are treated as preemptible batch classes below every
+// configured class.
+func (e *Engine) classNamed(name models.Priority) models.PriorityClass {
+	if class, exists := e.classes[name]; exists {
+		return class
+	}
+	return models.PriorityClass{
+		Name:        name,
+		Value:       math.MinInt,
+		Pool:        models.QuotaPoolBatch,
+		Preemptible: true,
+	}
+}
+
+// poolOf returns the global quota pool a task draws from
+func (e *Engine) poolOf(task *models.Task) models.QuotaPool {
+	return e.classOf(task).Pool
+}
+
+// queuesInOrder returns the pending tasks of every queue, highest priority
+// class first
+func (e *Engine) queuesInOrder() []classQueue {
+	state := e.state.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	queues := make([]classQueue, 0, len(state.Queues))
+	classes := make([]models.PriorityClass, 0, len(state.Queues))
+	for name := range state.Queues {
+		classes = append(classes, e.classNamed(name))
+	}
+	sortClasses(classes)
+
+	for _, class := range classes {
+		queues = append(queues, classQueue{class: class, tasks: state.Queues[class.Name].Tasks()})
+	}
+	return queues
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// testPriorityClasses is a three-class setup: critical online tasks that
+// preempt, normal batch tasks that preempt and are preemptible, and
+// preemptible low batch tasks
+func testPriorityClasses() []models.PriorityClass {
+	return []models.PriorityClass{
+		{Name: "critical", Value: 2000, Pool: models.QuotaPoolOnline, Preempt: true},
+		{Name: "normal", Value: 500, Pool: models.QuotaPoolBatch, Preempt: true, Preemptible: true},
+		{Name: "low", Value: 100, Pool: models.QuotaPoolBatch, Preemptible: true},
+	}
+}
+
+func newClassTask(id string, priority models.Priority, createdAt time.Time) *models.Task {
+	return &models.Task{
+		ID:        id,
+		Priority:  priority,
+		GPUCount:  1,
+		Command:   "run",
+		Status:    models.TaskStatusPending,
+		CreatedAt: createdAt,
+	}
+}
+
+func TestSchedulingOrdersPriorityClasses(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetPriorityClasses(testPriorityClasses())
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 2
+	state.Quota.BatchQuota = 2
+
+	// Submitted lowest class first
+	now := time.Now()
+	low := newClassTask("low-1", "low", now.Add(-3*time.Minute))
+	normal := newClassTask("normal-1", "normal", now.Add(-2*time.Minute))
+	critical := newClassTask("critical-1", "critical", now.Add(-time.Minute))
+	for _, task := range []*models.Task{low, normal, critical} {
+		stateManager.AddTask(task)
+	}
+
+	engine.runSchedulingCycle()
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	if critical.Status != models.TaskStatusRunning || normal.Status != models.TaskStatusRunning {
+		t.Errorf("Expected the two highest classes to run, got critical %s and normal %s",
+			critical.Status, normal.Status)
+	}
+	if low.Status != models.TaskStatusPending {
+		t.Errorf("Expected the lowest class to wait, got %s", low.Status)
+	}
+
+	// Each class draws from its own quota pool
+	if state.Quota.OnlineUsed != 1 || state.Quota.BatchUsed != 1 {
+		t.Errorf("Expected 1 online and 1 batch GPU in use, got %d and %d",
+			state.Quota.OnlineUsed, state.Quota.BatchUsed)
+	}
+}
+
+func TestPreemptionRespectsPriorityClasses(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetPreemption(PreemptionConfig{Enabled: true, GracePeriod: 10 * time.Second})
+	engine.SetPriorityClasses(testPriorityClasses())
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 2
+	state.Quota.BatchQuota = 2
+
+	// The low task is older than the normal one, which would otherwise
+	// make the normal task the preferred victim
+	running := []struct {
+		id       string
+		priority models.Priority
+		age      time.Duration
+	}{
+		{id: "low-1", priority: "low", age: time.Hour},
+		{id: "normal-1", priority: "normal", age: time.Minute},
+	}
+	for _, r := range running {
+		task := newClassTask(r.id, r.priority, time.Now())
+		stateManager.AddTask(task)
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", r.id, err)
+		}
+		started := time.Now().Add(-r.age)
+		task.StartedAt = &started
+	}
+
+	if !engine.preemptFor(newClassTask("critical-1", "critical", time.Now())) {
+		t.Fatal("Expected the critical task to preempt")
+	}
+	low, _ := stateManager.GetTask("low-1")
+	normal, _ := stateManager.GetTask("normal-1")
+	if low.StopRequest == nil || normal.StopRequest != nil {
+		t.Error("Expected the lowest class to be preempted first")
+	}
+
+	// Tasks of non-preemptible classes are never victims
+	gpus = newTestCluster([]string{"node-b"}, 1, []int{0})
+	engine, stateManager = newTestEngine(t, gpus)
+	engine.SetPreemption(PreemptionConfig{Enabled: true, GracePeriod: 10 * time.Second})
+	engine.SetPriorityClasses(append(testPriorityClasses(),
+		models.PriorityClass{Name: "protected", Value: 50, Pool: models.QuotaPoolBatch}))
+	stateManager.GetState().Quota.BatchQuota = 1
+
+	protected := newClassTask("protected-1", "protected", time.Now())
+	stateManager.AddTask(protected)
+	if err := engine.scheduleTask(protected); err != nil {
+		t.Fatalf("Failed to schedule protected task: %v", err)
+	}
+	if engine.preemptFor(newClassTask("critical-2", "critical", time.Now())) {
+		t.Error("Expected a non-preemptible task not to be preempted")
+	}
+}
+
+func TestQueuesRebuiltFromSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	stateManager := NewStateManager(dir)
+
+	now := time.Now()
+	second := newClassTask("normal-2", "normal", now)
+	first := newClassTask("normal-1", "normal", now.Add(-time.Minute))
+	done := newClassTask("low-1", "low", now)
+	done.Status = models.TaskStatusSuccess
+	for _, task := range []*models.Task{second, first, done} {
+		stateManager.AddTask(task)
+	}
+	if err := stateManager.SaveSnapshot(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored := NewStateManager(dir)
+	if err := restored.LoadSnapshot(); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	queue := restored.GetState().Queues["normal"].Tasks()
+	if len(queue) != 2 || queue[0].ID != "normal-1" || queue[1].ID != "normal-2" {
+		t.Errorf("Expected the normal queue to be rebuilt in submission order, got %v", queue)
+	}
+	if restored.GetState().Queues["low"].Len() != 0 {
+		t.Error("Expected finished tasks not to be queued")
+	}
+}
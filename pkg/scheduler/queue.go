@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// TaskQueue holds the pending tasks of one priority class in submission
+// order. Tasks leave it when they start or finish, and a requeued task
+// gets its old place back.
+type TaskQueue struct {
+	entries queueHeap
+	byID    map[string]*queueEntry
+}
+
+// queueEntry is a task's place in its queue
+type queueEntry struct {
+	task  *models.Task
+	seq   uint64
+	index int
+}
+
+// newTaskQueue creates an empty queue
+func newTaskQueue() *TaskQueue {
+	return &TaskQueue{byID: make(map[string]*queueEntry)}
+}
+
+// Len returns the number of pending tasks in the queue
+func (q *TaskQueue) Len() int {
+	if q == nil {
+		return 0
+	}
+	return len(q.entries)
+}
+
+// Tasks returns the queue's tasks in order
+func (q *TaskQueue) Tasks() []*models.Task {
+	if q == nil {
+		return nil
+	}
+
+	entries := make([]*queueEntry, len(q.entries))
+	copy(entries, q.entries)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	tasks := make([]*models.Task, len(entries))
+	for i, entry := range entries {
+		tasks[i] = entry.task
+	}
+	return tasks
+}
+
+// push adds a task at the given place in the queue
+func (q *TaskQueue) push(task *models.Task, seq uint64) {
+	if _, queued := q.byID[task.ID]; queued {
+		return
+	}
+	entry := &queueEntry{task: task, seq: seq}
+	heap.Push(&q.entries, entry)
+	q.byID[task.ID] = entry
+}
+
+// remove takes a task out of the queue
+func (q *TaskQueue) remove(taskID string) {
+	entry, queued := q.byID[taskID]
+	if !queued {
+		return
+	}
+	heap.Remove(&q.entries, entry.index)
+	delete(q.byID, taskID)
+}
+
+// queueHeap orders queue entries by their place in the queue
+type queueHeap []*queueEntry
+
+func (h queueHeap) Len() int { return len(h) }
+
+func (h queueHeap) Less(i, j int) bool { return h[i].seq < h[j].seq }
+
+func (h queueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queueHeap) Push(x interface{}) {
+	entry := x.(*queueEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *queueHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return entry
+}
+
+// syncTaskLocked brings the queues in line with a task's status: pending
+// tasks are queued, others taken out. Unfinished tasks keep their place
+// for when they are requeued (must hold lock).
+func (sm *StateManager) syncTaskLocked(task *models.Task) {
+	state := sm.state
+	if queue, exists := state.Queues[task.Priority]; exists {
+		queue.remove(task.ID)
+	}
+
+	switch {
+	case task.Status == models.TaskStatusPending:
+		if task.QueuedAt == nil {
+			now := time.Now()
+			task.QueuedAt = &now
+		}
+		seq, exists := state.queueSeq[task.ID]
+		if !exists {
+			state.nextSeq++
+			seq = state.nextSeq
+			state.queueSeq[task.ID] = seq
+		}
+		queue, exists := state.Queues[task.Priority]
+		if !exists {
+			queue = newTaskQueue()
+			state.Queues[task.Priority] = queue
+		}
+		queue.push(task, seq)
+	case task.Status.IsFinal():
+		delete(state.queueSeq, task.ID)
+	}
+}
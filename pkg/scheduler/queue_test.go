@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func queuedIDs(queue *TaskQueue) []string {
+	ids := make([]string, 0, queue.Len())
+	for _, task := range queue.Tasks() {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestQueueHoldsOnlyPendingTasks(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 2
+
+	now := time.Now()
+	tasks := make([]*models.Task, 4)
+	for i := range tasks {
+		tasks[i] = newClassTask(fmt.Sprintf("task-%d", i), models.PriorityHigh, now)
+		stateManager.AddTask(tasks[i])
+	}
+
+	engine.runSchedulingCycle()
+	queue := state.Queues[models.PriorityHigh]
+	if got := queuedIDs(queue); len(got) != 2 || got[0] != "task-2" || got[1] != "task-3" {
+		t.Fatalf("Expected the tasks left pending to be queued, got %v", got)
+	}
+
+	// A finished task leaves for good, a requeued one gets its place back
+	if err := engine.ReleaseTask("task-0", TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+	state.mu.Lock()
+	engine.requeueTaskLocked(tasks[1], StopReasonPreempted)
+	state.mu.Unlock()
+
+	if got := queuedIDs(queue); len(got) != 3 || got[0] != "task-1" {
+		t.Errorf("Expected the requeued task back at the head, got %v", got)
+	}
+	if _, exists := state.queueSeq["task-0"]; exists {
+		t.Error("Expected the finished task to be forgotten")
+	}
+}
+
+func TestGPUIndexFollowsAllocations(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b"}, 2, []int{1, 0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 4
+
+	checkIndex := func(when string) {
+		t.Helper()
+		idle := 0
+		for _, gpu := range state.GPUs {
+			if gpu.Status == models.GPUStatusIdle {
+				idle++
+				if state.gpuIndex.idleByNode[gpu.NodeID][gpu.ID] != gpu {
+					t.Errorf("%s: expected idle GPU %s in the index", when, gpu.ID)
+				}
+			}
+		}
+		if got := len(state.gpuIndex.idleFor(&models.Task{}, nil)); got != idle {
+			t.Errorf("%s: expected %d idle GPUs indexed, got %d", when, idle, got)
+		}
+		for nodeID, load := range computeNodeLoads(state.GPUs) {
+			if indexed := state.gpuIndex.loads[nodeID]; indexed == nil || *indexed != *load {
+				t.Errorf("%s: expected load %+v on %s, got %+v", when, *load, nodeID, indexed)
+			}
+		}
+	}
+
+	task := newClassTask("task-1", models.PriorityHigh, time.Now())
+	task.GPUCount = 2
+	stateManager.AddTask(task)
+	engine.runSchedulingCycle()
+	if task.Status != models.TaskStatusRunning {
+		t.Fatalf("Expected the task to run, got %s", task.Status)
+	}
+	checkIndex("after placing")
+
+	if _, err := stateManager.ReportGPUStatus("node-a-gpu-1", models.GPUStatusOffline); err != nil {
+		t.Fatalf("Failed to report status: %v", err)
+	}
+	checkIndex("after going offline")
+
+	if err := engine.ReleaseTask(task.ID, TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+	checkIndex("after releasing")
+
+	stateManager.RemoveGPU("node-b-gpu-0")
+	checkIndex("after removing a GPU")
+}
@@ -0,0 +1,251 @@
+package scheduler
+
+import (
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// Levels of the quota hierarchy
+const (
+	quotaLevelOrg     = "org"
+	quotaLevelTeam    = "team"
+	quotaLevelProject = "project"
+)
+
+// quotaNode is one level of the quota hierarchy a task is accounted to
+type quotaNode struct {
+	level string
+	name  string
+	spec  *models.QuotaSpec // nil if no quota is set at this level
+}
+
+// limit returns the node's limit for a priority class and GPU model, ""
+// meaning GPUs of every model
+func (n quotaNode) limit(priority models.Priority, model string) models.QuotaLimit {
+	if n.spec == nil {
+		return models.QuotaLimit{}
+	}
+	if model == "" {
+		return n.spec.Limits[priority]
+	}
+	return n.spec.Models[model][priority]
+}
+
+// quotaKey identifies a usage counter of one node of the quota hierarchy
+type quotaKey struct {
+	level    string
+	name     string
+	priority models.Priority
+	model    string
+}
+
+func (n quotaNode) key(priority models.Priority, model string) quotaKey {
+	return quotaKey{level: n.level, name: n.name, priority: priority, model: model}
+}
+
+// projectName scopes a project name to its team
+func projectName(team, project string) string {
+	return team + "/" + project
+}
+
+// quotaPath returns the nodes of the quota hierarchy a task is accounted
+// to, from the organization down to the project (must hold lock)
+func quotaPath(state *State, task *models.Task) []quotaNode {
+	team := teamOf(task)
+	path := make([]quotaNode, 0, 3)
+
+	teamQuota := state.TeamQuotas[team]
+	if teamQuota != nil && teamQuota.Organization != "" {
+		node := quotaNode{level: quotaLevelOrg, name: teamQuota.Organization}
+		if orgQuota, exists := state.OrgQuotas[teamQuota.Organization]; exists {
+			node.spec = &orgQuota.QuotaSpec
+		}
+		path = append(path, node)
+	}
+
+	node := quotaNode{level: quotaLevelTeam, name: team}
+	if teamQuota != nil {
+		node.spec = &teamQuota.QuotaSpec
+	}
+	path = append(path, node)
+
+	if task.Project != "" {
+		node := quotaNode{level: quotaLevelProject, name: projectName(team, task.Project)}
+		if teamQuota != nil {
+			if spec, exists := teamQuota.Projects[task.Project]; exists {
+				node.spec = &spec
+			}
+		}
+		path = append(path, node)
+	}
+
+	return path
+}
+
+// quotaSiblings returns the nodes sharing a parent with path[depth], i.e.
+// competing with it for the parent's capacity (must hold lock)
+func quotaSiblings(state *State, path []quotaNode, depth int) []quotaNode {
+	self := path[depth]
+	siblings := make([]quotaNode, 0)
+
+	switch {
+	case self.level == quotaLevelProject:
+		team := path[depth-1].name
+		teamQuota, exists := state.TeamQuotas[team]
+		if !exists {
+			return siblings
+		}
+		for project, spec := range teamQuota.Projects {
+			name := projectName(team, project)
+			if name != self.name {
+				siblings = append(siblings, quotaNode{level: quotaLevelProject, name: name, spec: &spec})
+			}
+		}
+
+	case self.level == quotaLevelTeam && depth > 0:
+		org := path[depth-1].name
+		for team, quota := range state.TeamQuotas {
+			if quota.Organization == org && team != self.name {
+				siblings = append(siblings, quotaNode{level: quotaLevelTeam, name: team, spec: &quota.QuotaSpec})
+			}
+		}
+
+	default:
+		// Top level: organizations and teams outside any organization
+		for org, quota := range state.OrgQuotas {
+			if self.level != quotaLevelOrg || org != self.name {
+				siblings = append(siblings, quotaNode{level: quotaLevelOrg, name: org, spec: &quota.QuotaSpec})
+			}
+		}
+		for team, quota := range state.TeamQuotas {
+			if quota.Organization == "" && (self.level != quotaLevelTeam || team != self.name) {
+				siblings = append(siblings, quotaNode{level: quotaLevelTeam, name: team, spec: &quota.QuotaSpec})
+			}
+		}
+	}
+
+	return siblings
+}
+
+// quotaUsage counts the GPUs held by running tasks at every node of the
+// quota hierarchy, per priority class and GPU model (must hold lock)
+func quotaUsage(state *State) map[quotaKey]int {
+	usage := make(map[quotaKey]int)
+	for _, task := range state.Tasks {
+		if task.Status == models.TaskStatusRunning {
+			addQuotaUsage(usage, state, task)
+		}
+	}
+	return usage
+}
+
+// addQuotaUsage counts a running task's GPUs at every node of its quota
+// path (must hold lock)
+func addQuotaUsage(usage map[quotaKey]int, state *State, task *models.Task) {
+	for _, node := range quotaPath(state, task) {
+		usage[node.key(task.Priority, "")] += task.GPUCount
+		for _, gpuID := range task.AssignedGPUs {
+			if gpu, exists := state.GPUs[gpuID]; exists {
+				usage[node.key(task.Priority, gpu.Model)]++
+			}
+		}
+	}
+}
+
+// countQuotaUsage counts the quota usage once for the tasks a cycle places,
+// which charge it as they start. Tasks finishing meanwhile only free their
+// quota for the next cycle, which their release triggers.
+func (e *Engine) countQuotaUsage() {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	e.usage = quotaUsage(state)
+}
+
+// forgetQuotaUsage drops the usage counted for a cycle
+func (e *Engine) forgetQuotaUsage() {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	e.usage = nil
+}
+
+// quotaAllowsLocked reports whether the task's quota hierarchy has room for
+// the given GPU counts per model, "" counting GPUs of every model. Each
+// level must stay within its maximum, and within its parent's capacity
+// after setting aside the unused minimums guaranteed to its siblings.
+// Per-model minimums are only guaranteed under a parent capping that model.
+// (must hold lock)
+func (e *Engine) quotaAllowsLocked(state *State, task *models.Task, counts map[string]int) bool {
+	path := quotaPath(state, task)
+	usage := e.usage
+	if usage == nil {
+		usage = quotaUsage(state)
+	}
+
+	for model, count := range counts {
+		for depth, node := range path {
+			limit := node.limit(task.Priority, model)
+			used := usage[node.key(task.Priority, model)]
+			if limit.Max > 0 && used+count > limit.Max {
+				return false
+			}
+
+			// Capacity shared with the siblings
+			var capacity, parentUsed int
+			switch {
+			case depth > 0:
+				parent := path[depth-1]
+				capacity = parent.limit(task.Priority, model).Max
+				parentUsed = usage[parent.key(task.Priority, model)]
+			case model == "":
+				capacity, parentUsed = e.bucketOf(state.Quota, e.poolOf(task))
+			}
+			if capacity <= 0 {
+				continue
+			}
+
+			reserved := 0
+			for _, sibling := range quotaSiblings(state, path, depth) {
+				unused := sibling.limit(task.Priority, model).Min - usage[sibling.key(task.Priority, model)]
+				if unused > 0 {
+					reserved += unused
+				}
+			}
+			if parentUsed+count+reserved > capacity {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// bucketOf returns the global quota and usage of a quota pool, counting
+// online quota batch tasks may borrow towards the batch quota
+func (e *Engine) bucketOf(quota *models.Quota, pool models.QuotaPool) (int, int) {
+	if pool == models.QuotaPoolOnline {
+		return quota.OnlineQuota, quota.OnlineUsed + quota.BatchBorrowed
+	}
+	return quota.BatchQuota + e.borrowable(quota), quota.BatchUsed + quota.BatchBorrowed
+}
+
+// checkModelQuota checks the per-model quotas against the GPUs picked for
+// a task, for tasks that didn't ask for a specific model
+func (e *Engine) checkModelQuota(task *models.Task, gpus []*models.GPU) bool {
+	if task.GPUModel != nil {
+		return true
+	}
+
+	counts := make(map[string]int)
+	for _, gpu := range gpus {
+		counts[gpu.Model]++
+	}
+
+	state := e.state.GetState()
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	return e.quotaAllowsLocked(state, task, counts)
+}
@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newQuotaTask(id, team, project string, gpuCount int) *models.Task {
+	return &models.Task{
+		ID:       id,
+		Team:     team,
+		Project:  project,
+		Priority: models.PriorityLow,
+		GPUCount: gpuCount,
+		Status:   models.TaskStatusPending,
+	}
+}
+
+func batchLimit(min, max int) map[models.Priority]models.QuotaLimit {
+	return map[models.Priority]models.QuotaLimit{
+		models.PriorityLow: {Min: min, Max: max},
+	}
+}
+
+func TestTeamQuotaMaxEnforced(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 8, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.BatchQuota = 8
+
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team:      "vision",
+		QuotaSpec: models.QuotaSpec{Limits: batchLimit(0, 3)},
+		Projects: map[string]models.QuotaSpec{
+			"detector": {Limits: batchLimit(0, 1)},
+		},
+	})
+
+	first := newQuotaTask("first", "vision", "", 2)
+	stateManager.AddTask(first)
+	if err := engine.scheduleTask(first); err != nil {
+		t.Fatalf("Failed to schedule first task: %v", err)
+	}
+
+	over := newQuotaTask("over", "vision", "", 2)
+	stateManager.AddTask(over)
+	if err := engine.scheduleTask(over); err != errInsufficientQuota {
+		t.Errorf("Expected team max to block task, got %v", err)
+	}
+
+	project := newQuotaTask("project", "vision", "detector", 2)
+	stateManager.AddTask(project)
+	if err := engine.scheduleTask(project); err != errInsufficientQuota {
+		t.Errorf("Expected project max to block task, got %v", err)
+	}
+
+	fits := newQuotaTask("fits", "vision", "detector", 1)
+	stateManager.AddTask(fits)
+	if err := engine.scheduleTask(fits); err != nil {
+		t.Errorf("Expected task within project max to run, got %v", err)
+	}
+}
+
+func TestQuotaMinIsGuaranteedToSiblings(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 8, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.BatchQuota = 8
+
+	stateManager.SetOrgQuota(&models.OrgQuota{
+		Organization: "research",
+		QuotaSpec:    models.QuotaSpec{Limits: batchLimit(0, 6)},
+	})
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team:         "nlp",
+		Organization: "research",
+		QuotaSpec:    models.QuotaSpec{Limits: batchLimit(4, 0)},
+	})
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team:         "vision",
+		Organization: "research",
+	})
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team:      "infra",
+		QuotaSpec: models.QuotaSpec{Limits: batchLimit(2, 0)},
+	})
+
+	// 6 GPUs in the org, 4 of them guaranteed to nlp
+	greedy := newQuotaTask("greedy", "vision", "", 3)
+	if engine.checkQuota(greedy, stateManager.GetState().Quota) {
+		t.Error("Expected vision to be kept out of nlp's guaranteed GPUs")
+	}
+	if !engine.checkQuota(newQuotaTask("small", "vision", "", 2), stateManager.GetState().Quota) {
+		t.Error("Expected vision to use GPUs beyond nlp's minimum")
+	}
+
+	// 8 batch GPUs in the cluster, 2 of them guaranteed to infra
+	if engine.checkQuota(newQuotaTask("outsider", "other", "", 7), stateManager.GetState().Quota) {
+		t.Error("Expected team without quota to be kept out of guaranteed GPUs")
+	}
+	if !engine.checkQuota(newQuotaTask("nlp", "nlp", "", 4), stateManager.GetState().Quota) {
+		t.Error("Expected nlp to use its guaranteed GPUs")
+	}
+}
+
+func TestQuotaPerGPUModel(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.GetState().Quota.BatchQuota = 4
+
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team: "vision",
+		QuotaSpec: models.QuotaSpec{
+			Models: map[string]map[models.Priority]models.QuotaLimit{
+				"TestGPU": batchLimit(0, 1),
+			},
+		},
+	})
+
+	model := "TestGPU"
+	pinned := newQuotaTask("pinned", "vision", "", 2)
+	pinned.GPUModel = &model
+	stateManager.AddTask(pinned)
+	if err := engine.scheduleTask(pinned); err != errInsufficientQuota {
+		t.Errorf("Expected model max to block task asking for the model, got %v", err)
+	}
+
+	anyModel := newQuotaTask("any", "vision", "", 2)
+	stateManager.AddTask(anyModel)
+	if err := engine.scheduleTask(anyModel); err != errInsufficientQuota {
+		t.Errorf("Expected model max to block task placed on the model, got %v", err)
+	}
+	if anyModel.Status != models.TaskStatusPending {
+		t.Errorf("Expected blocked task to stay pending, got %s", anyModel.Status)
+	}
+}
+
+func TestQuotasPersistInSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	stateManager := NewStateManager(dir)
+	stateManager.SetOrgQuota(&models.OrgQuota{
+		Organization: "research",
+		QuotaSpec:    models.QuotaSpec{Limits: batchLimit(0, 6)},
+	})
+	stateManager.SetTeamQuota(&models.TeamQuota{
+		Team:         "nlp",
+		Organization: "research",
+		QuotaSpec:    models.QuotaSpec{Limits: batchLimit(4, 0)},
+		Projects: map[string]models.QuotaSpec{
+			"llm": {Limits: batchLimit(0, 2)},
+		},
+	})
+	if err := stateManager.SaveSnapshot(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored := NewStateManager(dir)
+	if err := restored.LoadSnapshot(); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	team, err := restored.GetTeamQuota("nlp")
+	if err != nil {
+		t.Fatalf("Expected team quota to be restored: %v", err)
+	}
+	if team.Organization != "research" || team.Limits[models.PriorityLow].Min != 4 {
+		t.Errorf("Unexpected restored team quota: %+v", team)
+	}
+	if team.Projects["llm"].Limits[models.PriorityLow].Max != 2 {
+		t.Errorf("Expected project limits to be restored, got %+v", team.Projects)
+	}
+	if _, err := restored.GetOrgQuota("research"); err != nil {
+		t.Errorf("Expected organization quota to be restored: %v", err)
+	}
+}
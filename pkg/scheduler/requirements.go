@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// ParseComputeCapability parses a CUDA compute capability such as "8.6"
+func ParseComputeCapability(s string) (major, minor int, err error) {
+	majorStr, minorStr, found := strings.Cut(s, ".")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid compute capability %q", s)
+	}
+	major, err = strconv.Atoi(majorStr)
+	if err != nil || major < 0 {
+		return 0, 0, fmt.Errorf("invalid compute capability %q", s)
+	}
+	minor, err = strconv.Atoi(minorStr)
+	if err != nil || minor < 0 {
+		return 0, 0, fmt.Errorf("invalid compute capability %q", s)
+	}
+	return major, minor, nil
+}
+
+// computeCapAtLeast reports whether a GPU's compute capability meets a
+// minimum. GPUs that didn't report one never do.
+func computeCapAtLeast(have, want string) bool {
+	haveMajor, haveMinor, err := ParseComputeCapability(have)
+	if err != nil {
+		return false
+	}
+	wantMajor, wantMinor, err := ParseComputeCapability(want)
+	if err != nil {
+		return false
+	}
+	if haveMajor != wantMajor {
+		return haveMajor > wantMajor
+	}
+	return haveMinor >= wantMinor
+}
+
+// unmetRequirement checks a GPU against the task's model, memory and
+// compute capability requirements, returning the filter of the first one
+// it doesn't meet or "" if it meets them all
+func unmetRequirement(task *models.Task, gpu *models.GPU) string {
+	if task.GPUModel != nil && *task.GPUModel != gpu.Model {
+		return FilterModel
+	}
+	if len(task.GPUModels) > 0 && modelPreference(task, gpu.Model) < 0 {
+		return FilterModel
+	}
+	if task.MinMemoryMB > 0 && gpu.Memory < task.MinMemoryMB {
+		return FilterMemory
+	}
+	if task.MinComputeCap != "" && !computeCapAtLeast(gpu.ComputeCap, task.MinComputeCap) {
+		return FilterComputeCap
+	}
+	return ""
+}
+
+// modelPreference returns the position of a model in the task's list of
+// acceptable models, or -1 if the task doesn't accept it
+func modelPreference(task *models.Task, model string) int {
+	for i, m := range task.GPUModels {
+		if m == model {
+			return i
+		}
+	}
+	return -1
+}
+
+// commonModel returns the model shared by all the GPUs, or "" if they are
+// of different models
+func commonModel(gpus []*models.GPU) string {
+	if len(gpus) == 0 {
+		return ""
+	}
+	model := gpus[0].Model
+	for _, gpu := range gpus[1:] {
+		if gpu.Model != model {
+			return ""
+		}
+	}
+	return model
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func TestComputeCapAtLeast(t *testing.T) {
+	tests := []struct {
+		have, want string
+		ok         bool
+	}{
+		{"8.0", "8.0", true},
+		{"8.6", "8.0", true},
+		{"9.0", "8.9", true},
+		{"10.0", "9.0", true},
+		{"7.5", "8.0", false},
+		{"", "7.0", false},
+		{"bad", "7.0", false},
+	}
+
+	for _, tt := range tests {
+		if got := computeCapAtLeast(tt.have, tt.want); got != tt.ok {
+			t.Errorf("computeCapAtLeast(%q, %q) = %v, want %v", tt.have, tt.want, got, tt.ok)
+		}
+	}
+}
+
+// newMixedCluster returns one node of 2 A100s (80GB, 8.0) and one of 4
+// V100s (32GB, 7.0)
+func newMixedCluster() map[string]*models.GPU {
+	gpus := newTestCluster([]string{"node-a100", "node-v100"}, 4, []int{2, 0})
+	for _, gpu := range gpus {
+		if gpu.NodeID == "node-a100" {
+			gpu.Model, gpu.Memory, gpu.ComputeCap = "A100", 81920, "8.0"
+		} else {
+			gpu.Model, gpu.Memory, gpu.ComputeCap = "V100", 32768, "7.0"
+		}
+	}
+	return gpus
+}
+
+func TestFindGPUsFallsBackThroughModels(t *testing.T) {
+	gpus := newMixedCluster()
+	engine, _ := newTestEngine(t, gpus)
+
+	tests := []struct {
+		name  string
+		task  *models.Task
+		model string // expected model, "" if the task can't be placed
+	}{
+		{
+			name:  "first choice fits",
+			task:  &models.Task{GPUCount: 2, GPUModels: []string{"A100", "V100"}},
+			model: "A100",
+		},
+		{
+			name:  "falls back to the second choice",
+			task:  &models.Task{GPUCount: 4, GPUModels: []string{"A100", "V100"}},
+			model: "V100",
+		},
+		{
+			name: "no acceptable model fits",
+			task: &models.Task{GPUCount: 4, GPUModels: []string{"A100", "H100"}},
+		},
+		{
+			name:  "memory requirement",
+			task:  &models.Task{GPUCount: 1, MinMemoryMB: 40000},
+			model: "A100",
+		},
+		{
+			name:  "compute capability requirement",
+			task:  &models.Task{GPUCount: 2, MinComputeCap: "7.5"},
+			model: "A100",
+		},
+		{
+			name: "requirements exclude every alternative",
+			task: &models.Task{GPUCount: 1, GPUModels: []string{"V100"}, MinMemoryMB: 40000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked, err := engine.findAvailableGPUs(tt.task, gpus, nil)
+			if tt.model == "" {
+				if err == nil {
+					t.Errorf("Expected no placement, got %d GPUs", len(picked))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected a placement: %v", err)
+			}
+			if got := commonModel(picked); got != tt.model {
+				t.Errorf("Expected %s GPUs, got %q", tt.model, got)
+			}
+		})
+	}
+}
+
+func TestChosenModelIsRecorded(t *testing.T) {
+	gpus := newMixedCluster()
+	engine, stateManager := newTestEngine(t, gpus)
+
+	task := &models.Task{
+		ID:        "train-1",
+		Priority:  models.PriorityHigh,
+		GPUCount:  3,
+		GPUModels: []string{"A100", "V100"},
+		Command:   "train",
+		Status:    models.TaskStatusPending,
+	}
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule task: %v", err)
+	}
+
+	if task.AssignedModel != "V100" {
+		t.Errorf("Expected the fallback model to be recorded, got %q", task.AssignedModel)
+	}
+}
@@ -0,0 +1,367 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// StopReasonReserved marks tasks stopped because an advance reservation of
+// their GPUs began
+const StopReasonReserved = "reserved"
+
+// reservationGracePeriod is the time tasks stopped for a reservation get
+// between SIGTERM and SIGKILL
+const reservationGracePeriod = 30 * time.Second
+
+// ErrReservationConflict is returned when overlapping reservations hold
+// the GPUs a reservation would need
+var ErrReservationConflict = errors.New("reservation conflict")
+
+// CreateReservation sets GPUs aside for a reservation's window
+func (e *Engine) CreateReservation(reservation *models.Reservation) error {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if _, exists := state.Reservations[reservation.ID]; exists {
+		return fmt.Errorf("reservation already exists: %s", reservation.ID)
+	}
+	if err := e.bookLocked(reservation, nil); err != nil {
+		return err
+	}
+	state.Reservations[reservation.ID] = reservation
+
+	e.logger.Info("Reservation created",
+		zap.String("reservation_id", reservation.ID),
+		zap.String("owner", reservation.Owner),
+		zap.Strings("gpus", reservation.GPUs),
+		zap.Time("start", reservation.Start),
+		zap.Time("end", reservation.End),
+	)
+
+	e.state.incrementVersion()
+	e.state.triggerSnapshot()
+	return nil
+}
+
+// UpdateReservation replaces a reservation, picking its GPUs anew. GPUs it
+// already held are kept where they still match.
+func (e *Engine) UpdateReservation(reservation *models.Reservation) error {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	existing, exists := state.Reservations[reservation.ID]
+	if !exists {
+		return fmt.Errorf("reservation not found: %s", reservation.ID)
+	}
+	if err := e.bookLocked(reservation, existing.GPUs); err != nil {
+		return err
+	}
+	reservation.CreatedAt = existing.CreatedAt
+	state.Reservations[reservation.ID] = reservation
+
+	e.logger.Info("Reservation updated",
+		zap.String("reservation_id", reservation.ID),
+		zap.Strings("gpus", reservation.GPUs),
+	)
+
+	e.state.incrementVersion()
+	e.state.triggerSnapshot()
+	return nil
+}
+
+// GetReservation retrieves a reservation by ID
+func (sm *StateManager) GetReservation(reservationID string) (*models.Reservation, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	reservation, exists := sm.state.Reservations[reservationID]
+	if !exists {
+		return nil, fmt.Errorf("reservation not found: %s", reservationID)
+	}
+	return reservation, nil
+}
+
+// ListReservations returns every reservation, earliest window first
+func (sm *StateManager) ListReservations() []*models.Reservation {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	reservations := make([]*models.Reservation, 0, len(sm.state.Reservations))
+	for _, reservation := range sm.state.Reservations {
+		reservations = append(reservations, reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].Start.Equal(reservations[j].Start) {
+			return reservations[i].Start.Before(reservations[j].Start)
+		}
+		return reservations[i].ID < reservations[j].ID
+	})
+	return reservations
+}
+
+// DeleteReservation removes a reservation and returns its GPUs to the
+// pool. Its tasks are placed like any other from then on.
+func (sm *StateManager) DeleteReservation(reservationID string) error {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	if _, exists := sm.state.Reservations[reservationID]; !exists {
+		return fmt.Errorf("reservation not found: %s", reservationID)
+	}
+	delete(sm.state.Reservations, reservationID)
+
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+	return nil
+}
+
+// overlaps reports whether a reservation's window overlaps [start, end)
+func overlaps(reservation *models.Reservation, start, end time.Time) bool {
+	return reservation.Start.Before(end) && start.Before(reservation.End)
+}
+
+// bookLocked picks the GPUs of a reservation among those matching its
+// model and node selector that no overlapping reservation holds. GPUs it
+// held before come first, then in-service GPUs expected to be free by the
+// start, keeping GPUs of a node together (must hold lock).
+func (e *Engine) bookLocked(reservation *models.Reservation, held []string) error {
+	state := e.state.state
+
+	takenBy := make(map[string]string)
+	for _, other := range state.Reservations {
+		if other.ID == reservation.ID || !overlaps(other, reservation.Start, reservation.End) {
+			continue
+		}
+		for _, gpuID := range other.GPUs {
+			takenBy[gpuID] = other.ID
+		}
+	}
+
+	wasHeld := make(map[string]bool, len(held))
+	for _, gpuID := range held {
+		wasHeld[gpuID] = true
+	}
+
+	type candidate struct {
+		gpu   *models.GPU
+		held  bool
+		ready bool
+	}
+
+	candidates := make([]candidate, 0)
+	conflicts := make(map[string]bool)
+	taken := 0
+	for _, gpu := range state.GPUs {
+		if gpu.Status == models.GPUStatusOffline {
+			continue
+		}
+		if reservation.GPUModel != "" && gpu.Model != reservation.GPUModel {
+			continue
+		}
+		if !matchesSelector(e.nodeLabelsLocked(gpu.NodeID), reservation.NodeSelector) {
+			continue
+		}
+		if owner, exists := takenBy[gpu.ID]; exists {
+			conflicts[owner] = true
+			taken++
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			gpu:   gpu,
+			held:  wasHeld[gpu.ID],
+			ready: e.freeByLocked(gpu, reservation.Start),
+		})
+	}
+
+	if len(candidates) < reservation.GPUCount {
+		if len(candidates)+taken < reservation.GPUCount {
+			return fmt.Errorf("only %d GPUs match the reservation, need %d", len(candidates)+taken, reservation.GPUCount)
+		}
+		owners := make([]string, 0, len(conflicts))
+		for owner := range conflicts {
+			owners = append(owners, owner)
+		}
+		sort.Strings(owners)
+		return fmt.Errorf("%w: %d of %d GPUs free in the window, held by %s",
+			ErrReservationConflict, len(candidates), reservation.GPUCount, strings.Join(owners, ", "))
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.held != cj.held {
+			return ci.held
+		}
+		if ci.ready != cj.ready {
+			return ci.ready
+		}
+		if ci.gpu.NodeID != cj.gpu.NodeID {
+			return ci.gpu.NodeID < cj.gpu.NodeID
+		}
+		return ci.gpu.ID < cj.gpu.ID
+	})
+
+	reservation.GPUs = make([]string, reservation.GPUCount)
+	for i := range reservation.GPUs {
+		reservation.GPUs[i] = candidates[i].gpu.ID
+	}
+	sort.Strings(reservation.GPUs)
+	return nil
+}
+
+// freeByLocked reports whether a GPU is in service and expected to be free
+// at the given time (must hold lock)
+func (e *Engine) freeByLocked(gpu *models.GPU, at time.Time) bool {
+	state := e.state.state
+	if agent, exists := state.Agents[gpu.NodeID]; exists && agent.Maintenance != "" {
+		return false
+	}
+
+	switch gpu.Status {
+	case models.GPUStatusIdle:
+		return true
+	case models.GPUStatusBusy:
+		if gpu.CurrentTask == nil {
+			return false
+		}
+		running, exists := state.Tasks[*gpu.CurrentTask]
+		if !exists || running.StartedAt == nil {
+			return false
+		}
+		runtime := e.expectedRuntime(running)
+		return runtime > 0 && !running.StartedAt.Add(runtime).After(at)
+	default:
+		return false
+	}
+}
+
+// reservationFit is the outcome of the advance reservations for a task:
+// the GPUs it is kept off, or the only GPUs it may use
+type reservationFit struct {
+	only   map[string]bool
+	hidden map[string]bool
+}
+
+// allows reports whether the task may use a GPU
+func (f *reservationFit) allows(gpuID string) bool {
+	if f == nil {
+		return true
+	}
+	if f.only != nil {
+		return f.only[gpuID]
+	}
+	return !f.hidden[gpuID]
+}
+
+// reservationFitLocked returns the GPUs the advance reservations leave a
+// task, or nil if none apply. Tasks of a reservation wait for its window
+// and run on its GPUs. Other tasks keep off reserved GPUs unless they are
+// expected to finish before the window (must hold lock).
+func (e *Engine) reservationFitLocked(task *models.Task, now time.Time) *reservationFit {
+	state := e.state.state
+	if len(state.Reservations) == 0 {
+		return nil
+	}
+
+	// Once its window ended, a reservation's tasks go anywhere
+	if own, exists := state.Reservations[task.ReservationID]; exists && now.Before(own.End) {
+		fit := &reservationFit{only: make(map[string]bool)}
+		if !now.Before(own.Start) {
+			for _, gpuID := range own.GPUs {
+				fit.only[gpuID] = true
+			}
+		}
+		return fit
+	}
+
+	runtime := e.expectedRuntime(task)
+	var fit *reservationFit
+	for _, reservation := range state.Reservations {
+		if !now.Before(reservation.End) {
+			continue
+		}
+		// Tasks of unknown runtime might run into any future window
+		if runtime > 0 && !now.Add(runtime).After(reservation.Start) {
+			continue
+		}
+		if fit == nil {
+			fit = &reservationFit{hidden: make(map[string]bool)}
+		}
+		for _, gpuID := range reservation.GPUs {
+			fit.hidden[gpuID] = true
+		}
+	}
+	return fit
+}
+
+// enforceReservations drops reservations whose window ended and stops the
+// tasks of others still running on the GPUs of open ones, which get
+// requeued
+func (e *Engine) enforceReservations(now time.Time) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	changed := false
+	for id, reservation := range state.Reservations {
+		if !now.Before(reservation.End) {
+			delete(state.Reservations, id)
+			changed = true
+
+			e.logger.Info("Reservation ended", zap.String("reservation_id", id))
+			continue
+		}
+		if now.Before(reservation.Start) {
+			continue
+		}
+
+		reserved := make(map[string]bool, len(reservation.GPUs))
+		for _, gpuID := range reservation.GPUs {
+			reserved[gpuID] = true
+		}
+		for _, task := range state.Tasks {
+			if task.Status != models.TaskStatusRunning || task.StopRequest != nil || task.ReservationID == id {
+				continue
+			}
+			if !anyIn(task.AssignedGPUs, reserved) {
+				continue
+			}
+
+			task.StopRequest = &models.StopRequest{
+				Reason:      StopReasonReserved,
+				GracePeriod: int(reservationGracePeriod.Seconds()),
+				RequestedAt: now,
+				RequestedBy: id,
+			}
+			changed = true
+
+			e.logger.Info("Stopping task for reservation",
+				zap.String("task_id", task.ID),
+				zap.String("reservation_id", id),
+			)
+		}
+	}
+
+	if changed {
+		e.state.incrementVersion()
+		e.state.triggerSnapshot()
+	}
+}
+
+// anyIn reports whether any of the IDs is in the set
+func anyIn(ids []string, set map[string]bool) bool {
+	for _, id := range ids {
+		if set[id] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newTestReservation(id string, gpuCount int, start, end time.Time) *models.Reservation {
+	return &models.Reservation{
+		ID:        id,
+		Owner:     "online",
+		GPUCount:  gpuCount,
+		Start:     start,
+		End:       end,
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestReservationConflicts(t *testing.T) {
+	engine, _ := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": {"zone": "a"},
+		"node-b": {"zone": "b"},
+	})
+	start := time.Now().Add(24 * time.Hour)
+	end := start.Add(2 * time.Hour)
+
+	launch := newTestReservation("launch", 3, start, end)
+	if err := engine.CreateReservation(launch); err != nil {
+		t.Fatalf("Failed to create reservation: %v", err)
+	}
+	if len(launch.GPUs) != 3 {
+		t.Fatalf("Expected 3 GPUs set aside, got %v", launch.GPUs)
+	}
+
+	// Only one GPU is left in the window
+	demo := newTestReservation("demo", 2, start.Add(time.Hour), end.Add(time.Hour))
+	err := engine.CreateReservation(demo)
+	if !errors.Is(err, ErrReservationConflict) || !strings.Contains(err.Error(), "launch") {
+		t.Fatalf("Expected a conflict with launch, got %v", err)
+	}
+
+	// Back-to-back windows don't overlap
+	demo.Start, demo.End = end, end.Add(time.Hour)
+	if err := engine.CreateReservation(demo); err != nil {
+		t.Fatalf("Failed to create a reservation after the window: %v", err)
+	}
+
+	// More GPUs than match is not a conflict
+	zoned := newTestReservation("zoned", 3, start, end)
+	zoned.NodeSelector = map[string]string{"zone": "b"}
+	if err := engine.CreateReservation(zoned); err == nil || errors.Is(err, ErrReservationConflict) {
+		t.Errorf("Expected too few matching GPUs, got %v", err)
+	}
+
+	// Updates keep the GPUs they still can
+	held := append([]string(nil), launch.GPUs...)
+	update := newTestReservation("launch", 2, start, end)
+	if err := engine.UpdateReservation(update); err != nil {
+		t.Fatalf("Failed to update reservation: %v", err)
+	}
+	for _, gpuID := range update.GPUs {
+		if !slices.Contains(held, gpuID) {
+			t.Errorf("Expected the update to keep held GPUs %v, got %v", held, update.GPUs)
+		}
+	}
+}
+
+func TestReservedGPUsDuringAndBeforeWindow(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": {"pool": "launch"},
+		"node-b": nil,
+	})
+	start := time.Now().Add(time.Hour)
+
+	launch := newTestReservation("launch", 2, start, start.Add(time.Hour))
+	launch.NodeSelector = map[string]string{"pool": "launch"}
+	if err := engine.CreateReservation(launch); err != nil {
+		t.Fatalf("Failed to create reservation: %v", err)
+	}
+
+	// Tasks of the reservation wait for the window
+	tagged := newAffinityTask("tagged")
+	tagged.ReservationID = "launch"
+	tagged.GPUCount = 2
+	stateManager.AddTask(tagged)
+	if err := engine.scheduleTask(tagged); err == nil {
+		t.Fatal("Expected the reservation's task to wait for the window")
+	}
+
+	// A task done before the window may use the reserved GPUs, one that
+	// might run into it may not
+	short := newAffinityTask("short")
+	short.ExpectedRuntime = 600
+	short.NodeSelector = map[string]string{"pool": "launch"}
+	unknown := newAffinityTask("unknown")
+	unknown.NodeSelector = map[string]string{"pool": "launch"}
+	for _, task := range []*models.Task{short, unknown} {
+		stateManager.AddTask(task)
+	}
+	if err := engine.scheduleTask(short); err != nil {
+		t.Fatalf("Expected the short task on a reserved GPU: %v", err)
+	}
+	if err := engine.scheduleTask(unknown); err == nil {
+		t.Error("Expected a task of unknown runtime to keep off reserved GPUs")
+	}
+	if err := engine.ReleaseTask(short.ID, TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+
+	// Once the window opens, only the reservation's tasks get its GPUs
+	state := stateManager.GetState()
+	state.mu.Lock()
+	launch.Start = time.Now().Add(-time.Minute)
+	state.mu.Unlock()
+
+	if err := engine.scheduleTask(tagged); err != nil {
+		t.Fatalf("Failed to schedule the reservation's task: %v", err)
+	}
+	if node := nodeOf(stateManager, tagged); node != "node-a" {
+		t.Errorf("Expected the reservation's task on node-a, got %s", node)
+	}
+}
+
+func TestEnforceReservations(t *testing.T) {
+	engine, stateManager := newLabeledEngine(t, map[string]map[string]string{
+		"node-a": nil,
+	})
+	now := time.Now()
+
+	// A task placed before the reservation was made
+	running := newAffinityTask("running")
+	running.GPUCount = 2
+	stateManager.AddTask(running)
+	if err := engine.scheduleTask(running); err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+
+	launch := newTestReservation("launch", 2, now.Add(time.Hour), now.Add(2*time.Hour))
+	if err := engine.CreateReservation(launch); err != nil {
+		t.Fatalf("Failed to create reservation: %v", err)
+	}
+
+	engine.enforceReservations(now)
+	if running.StopRequest != nil {
+		t.Fatal("Expected the task left alone before the window")
+	}
+
+	engine.enforceReservations(launch.Start)
+	if running.StopRequest == nil || running.StopRequest.Reason != StopReasonReserved {
+		t.Fatalf("Expected the task stopped for the reservation, got %+v", running.StopRequest)
+	}
+
+	engine.enforceReservations(launch.End)
+	if _, err := stateManager.GetReservation("launch"); err == nil {
+		t.Error("Expected the reservation dropped after its window")
+	}
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"math"
+	"slices"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// hostUsage is the CPU and memory running tasks take on a node
+type hostUsage struct {
+	milliCPU int64
+	memoryMB int64
+}
+
+// milliCPU converts CPU cores to thousandths of a core, rounding up
+func milliCPU(cores float64) int64 {
+	return int64(math.Ceil(cores * 1000))
+}
+
+// requestsHost reports whether a task asks for CPU or host memory
+func requestsHost(task *models.Task) bool {
+	return task.CPU > 0 || task.MemoryMB > 0
+}
+
+// hostHas reports whether a node has the CPU and memory a task asks for
+// free on top of what is used, nil meaning nothing. Nodes whose agents
+// didn't report a capacity aren't limited.
+func hostHas(agent *models.Agent, used *hostUsage, task *models.Task) bool {
+	if used == nil {
+		used = &hostUsage{}
+	}
+	if agent.CPUCores > 0 && used.milliCPU+milliCPU(task.CPU) > int64(agent.CPUCores)*1000 {
+		return false
+	}
+	if agent.MemoryMB > 0 && used.memoryMB+task.MemoryMB > agent.MemoryMB {
+		return false
+	}
+	return true
+}
+
+// taskNodesLocked returns the nodes a task's GPUs are on (must hold lock)
+func taskNodesLocked(state *State, task *models.Task) []string {
+	nodes := make([]string, 0, 1)
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := state.GPUs[gpuID]; exists && !slices.Contains(nodes, gpu.NodeID) {
+			nodes = append(nodes, gpu.NodeID)
+		}
+	}
+	return nodes
+}
+
+// hostUsageOf counts the CPU and memory running tasks take on every node
+// (must hold lock)
+func hostUsageOf(state *State) map[string]*hostUsage {
+	usage := make(map[string]*hostUsage)
+	for _, task := range state.Tasks {
+		if task.Status == models.TaskStatusRunning {
+			addHostUsage(usage, state, task)
+		}
+	}
+	return usage
+}
+
+// addHostUsage counts a running task's CPU and memory on each of its nodes
+// (must hold lock)
+func addHostUsage(usage map[string]*hostUsage, state *State, task *models.Task) {
+	shiftHostUsage(usage, state, task, 1)
+}
+
+// removeHostUsage takes a task's CPU and memory off each of its nodes, e.g.
+// as a simulation frees its GPUs (must hold lock)
+func removeHostUsage(usage map[string]*hostUsage, state *State, task *models.Task) {
+	shiftHostUsage(usage, state, task, -1)
+}
+
+// shiftHostUsage adds a task's CPU and memory to its nodes' usage sign
+// times (must hold lock)
+func shiftHostUsage(usage map[string]*hostUsage, state *State, task *models.Task, sign int64) {
+	if !requestsHost(task) {
+		return
+	}
+	for _, nodeID := range taskNodesLocked(state, task) {
+		used, exists := usage[nodeID]
+		if !exists {
+			used = &hostUsage{}
+			usage[nodeID] = used
+		}
+		used.milliCPU += sign * milliCPU(task.CPU)
+		used.memoryMB += sign * task.MemoryMB
+	}
+}
+
+// simHostUsageLocked returns a copy of the CPU and memory in use that a
+// simulation may change (must hold lock)
+func (e *Engine) simHostUsageLocked() map[string]*hostUsage {
+	if e.hosts == nil {
+		return hostUsageOf(e.state.state)
+	}
+	usage := make(map[string]*hostUsage, len(e.hosts))
+	for nodeID, used := range e.hosts {
+		copied := *used
+		usage[nodeID] = &copied
+	}
+	return usage
+}
+
+// countHostUsage counts the CPU and memory in use once for the tasks a
+// cycle places, the way countQuotaUsage counts quota usage
+func (e *Engine) countHostUsage() {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	e.hosts = hostUsageOf(state)
+}
+
+// forgetHostUsage drops the CPU and memory usage counted for a cycle
+func (e *Engine) forgetHostUsage() {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	e.hosts = nil
+}
+
+// hostFit is the outcome of a task's CPU and memory requests: the nodes
+// lacking the free CPU or memory it asks for
+type hostFit struct {
+	short map[string]bool
+}
+
+// allows reports whether a node has the CPU and memory the task asks for
+func (f *hostFit) allows(nodeID string) bool {
+	return f == nil || !f.short[nodeID]
+}
+
+// hostFitLocked checks the task's CPU and memory requests against every
+// node, or returns nil if the task has none (must hold lock)
+func (e *Engine) hostFitLocked(task *models.Task) *hostFit {
+	if !requestsHost(task) {
+		return nil
+	}
+	usage := e.hosts
+	if usage == nil {
+		usage = hostUsageOf(e.state.state)
+	}
+	return e.hostFitFor(task, usage)
+}
+
+// hostFitFor checks the task's CPU and memory requests against every node
+// given the usage, e.g. of a simulation, or returns nil if the task has
+// none (must hold lock)
+func (e *Engine) hostFitFor(task *models.Task, usage map[string]*hostUsage) *hostFit {
+	if !requestsHost(task) {
+		return nil
+	}
+
+	fit := &hostFit{short: make(map[string]bool)}
+	for nodeID, agent := range e.state.state.Agents {
+		if !hostHas(agent, usage[nodeID], task) {
+			fit.short[nodeID] = true
+		}
+	}
+	return fit
+}
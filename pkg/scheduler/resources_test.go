@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// newHostTask builds a 1-GPU task asking for CPU and memory
+func newHostTask(id string, cpu float64, memoryMB int64) *models.Task {
+	return &models.Task{
+		ID:       id,
+		Priority: models.PriorityHigh,
+		GPUCount: 1,
+		CPU:      cpu,
+		MemoryMB: memoryMB,
+		Command:  "train",
+		Status:   models.TaskStatusPending,
+	}
+}
+
+// newHostEngine builds two 4-GPU nodes with 8 cores and 32GB each
+func newHostEngine(t *testing.T) (*Engine, *StateManager) {
+	t.Helper()
+
+	gpus := newTestCluster([]string{"node-a", "node-b"}, 4, []int{0, 0})
+	engine, stateManager := newTestEngine(t, gpus)
+	for _, nodeID := range []string{"node-a", "node-b"} {
+		stateManager.RegisterAgent(&models.Agent{
+			ID:       nodeID,
+			Status:   models.AgentStatusOnline,
+			CPUCores: 8,
+			MemoryMB: 32000,
+		})
+	}
+	return engine, stateManager
+}
+
+func TestHostResourcesLimitPlacement(t *testing.T) {
+	engine, stateManager := newHostEngine(t)
+
+	tasks := []*models.Task{
+		newHostTask("cpu-1", 6, 0),
+		newHostTask("cpu-2", 5.5, 0),
+		newHostTask("cpu-3", 4, 0),
+		newHostTask("memory-1", 0, 20000),
+	}
+	for _, task := range tasks {
+		stateManager.AddTask(task)
+	}
+
+	engine.runSchedulingCycle()
+
+	// The CPU counted within the cycle spreads the first two tasks, leaving
+	// no node the cores of the third
+	nodes := make(map[string]bool)
+	for _, task := range tasks[:2] {
+		if task.Status != models.TaskStatusRunning {
+			t.Fatalf("Expected %s to run, got %s", task.ID, task.Status)
+		}
+		nodes[taskNodesLocked(stateManager.GetState(), task)[0]] = true
+	}
+	if len(nodes) != 2 {
+		t.Errorf("Expected the CPU-heavy tasks on different nodes, got %v", nodes)
+	}
+	if tasks[2].Status != models.TaskStatusPending {
+		t.Errorf("Expected cpu-3 to wait for cores, got %s", tasks[2].Status)
+	}
+	if tasks[3].Status != models.TaskStatusRunning {
+		t.Errorf("Expected memory-1 to run on the cores left, got %s", tasks[3].Status)
+	}
+
+	explanation, err := engine.Explain("cpu-3")
+	if err != nil {
+		t.Fatalf("Failed to explain: %v", err)
+	}
+	diag := explanation.Diagnostics
+	if diag == nil || diag.Rejected[FilterResources] != 5 {
+		t.Errorf("Expected the 5 idle GPUs rejected by %s, got %+v", FilterResources, diag)
+	}
+
+	// A second memory-heavy task fits only on the node the first isn't on
+	memory := newHostTask("memory-2", 0, 20000)
+	stateManager.AddTask(memory)
+	engine.runSchedulingCycle()
+	if memory.Status != models.TaskStatusRunning {
+		t.Fatalf("Expected memory-2 to run, got %s", memory.Status)
+	}
+	state := stateManager.GetState()
+	if taskNodesLocked(state, memory)[0] == taskNodesLocked(state, tasks[3])[0] {
+		t.Error("Expected the memory-heavy tasks on different nodes")
+	}
+}
+
+func TestHostResourcesUnreportedCapacity(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	stateManager.RegisterAgent(&models.Agent{ID: "node-a", Status: models.AgentStatusOnline})
+
+	// Agents reporting no capacity don't limit the tasks on them
+	tasks := []*models.Task{newHostTask("cpu-1", 64, 1<<20), newHostTask("cpu-2", 64, 1<<20)}
+	for _, task := range tasks {
+		stateManager.AddTask(task)
+	}
+	engine.runSchedulingCycle()
+
+	for _, task := range tasks {
+		if task.Status != models.TaskStatusRunning {
+			t.Errorf("Expected %s to run, got %s", task.ID, task.Status)
+		}
+	}
+}
+
+func TestAdmitRejectsHostRequestsBeyondNodes(t *testing.T) {
+	engine, _ := newHostEngine(t)
+	engine.SetAdmission(AdmissionConfig{Mode: AdmissionReject})
+
+	if _, err := engine.Admit(newHostTask("fits", 8, 32000), false); err != nil {
+		t.Errorf("Expected a task asking for a whole node to be admitted, got %v", err)
+	}
+
+	_, err := engine.Admit(newHostTask("too-large", 16, 0), false)
+	if !errors.Is(err, ErrUnsatisfiable) {
+		t.Fatalf("Expected ErrUnsatisfiable, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "host_resources 8") {
+		t.Errorf("Expected the host resources filter in %q", err.Error())
+	}
+}
+
+func TestPreemptionCountsHostResources(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetPreemption(PreemptionConfig{Enabled: true, GracePeriod: 10 * time.Second})
+	stateManager.RegisterAgent(&models.Agent{ID: "node-a", Status: models.AgentStatusOnline, CPUCores: 8})
+
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 2
+	state.Quota.BatchQuota = 2
+
+	// An online task that can't be preempted holds every core, a batch task
+	// the other GPU
+	hog := newHostTask("hog", 8, 0)
+	batch := newHostTask("batch", 0, 0)
+	batch.Priority = models.PriorityLow
+	for _, task := range []*models.Task{hog, batch} {
+		stateManager.AddTask(task)
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", task.ID, err)
+		}
+	}
+
+	online := newHostTask("online", 4, 0)
+	stateManager.AddTask(online)
+	engine.processQueue([]*models.Task{online}, models.PriorityHigh, nil)
+	if batch.StopRequest != nil {
+		t.Fatal("Expected no preemption while the node has no cores to spare")
+	}
+
+	// With cores to spare the batch task's GPU is worth taking
+	hog.CPU = 4
+	engine.processQueue([]*models.Task{online}, models.PriorityHigh, nil)
+	if batch.StopRequest == nil {
+		t.Error("Expected the batch task to be preempted")
+	}
+}
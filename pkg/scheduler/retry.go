@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// maxRetryBackoff caps the delay before a retry when the task's policy
+// doesn't
+const maxRetryBackoff = 24 * time.Hour
+
+// defaultRetryOn are the failure classes retried when a policy doesn't list
+// any. GPU errors such as OOM would most likely happen again.
+var defaultRetryOn = []models.FailureClass{
+	models.FailureExit,
+	models.FailureAgentLost,
+	models.FailurePreempted,
+}
+
+// TaskExit is how a task attempt ended as reported by its agent
+type TaskExit struct {
+	Status   models.TaskStatus
+	Failure  models.FailureClass // class of a failed attempt, FailureExit if unset
+	ExitCode *int
+	Error    *string
+}
+
+// retryable reports whether a task's retry policy allows another attempt
+// after a failure of the given class
+func retryable(task *models.Task, failure models.FailureClass) bool {
+	policy := task.Retry
+	if policy == nil || len(task.Attempts) >= policy.MaxAttempts {
+		return false
+	}
+
+	classes := policy.RetryOn
+	if len(classes) == 0 {
+		classes = defaultRetryOn
+	}
+	for _, class := range classes {
+		if class == failure {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoff returns how long a task waits before its nth retry, doubling
+// the policy's backoff for each retry
+func retryBackoff(policy *models.RetryPolicy, retry int) time.Duration {
+	if policy.Backoff <= 0 {
+		return 0
+	}
+
+	limit := maxRetryBackoff
+	if policy.MaxBackoff > 0 {
+		limit = time.Duration(policy.MaxBackoff) * time.Second
+	}
+
+	backoff := time.Duration(policy.Backoff) * time.Second
+	for i := 1; i < retry && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		return limit
+	}
+	return backoff
+}
+
+// recordAttemptLocked adds the attempt that just ended to the task's
+// history (must hold lock)
+func (e *Engine) recordAttemptLocked(task *models.Task, status models.TaskStatus, failure models.FailureClass, exitCode *int, errorMsg *string) {
+	state := e.state.state
+
+	nodes := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, gpuID := range task.AssignedGPUs {
+		if gpu, exists := state.GPUs[gpuID]; exists && !seen[gpu.NodeID] {
+			seen[gpu.NodeID] = true
+			nodes = append(nodes, gpu.NodeID)
+		}
+	}
+
+	attempt := models.TaskAttempt{
+		Attempt:    len(task.Attempts) + 1,
+		Nodes:      nodes,
+		GPUs:       task.AssignedGPUs,
+		Status:     status,
+		Failure:    failure,
+		ExitCode:   exitCode,
+		StartedAt:  startedAt(task),
+		FinishedAt: time.Now(),
+	}
+	if errorMsg != nil {
+		attempt.Error = *errorMsg
+	}
+	task.Attempts = append(task.Attempts, attempt)
+}
+
+// endAttemptLocked records how a running task's attempt ended, then either
+// requeues the task for a retry after its backoff or releases it for good
+// (must hold lock)
+func (e *Engine) endAttemptLocked(task *models.Task, exit TaskExit) {
+	failure := exit.Failure
+	if exit.Status != models.TaskStatusFailed {
+		failure = ""
+	} else if failure == "" {
+		failure = models.FailureExit
+	}
+
+	e.recordAttemptLocked(task, exit.Status, failure, exit.ExitCode, exit.Error)
+
+	if exit.Status != models.TaskStatusFailed || !retryable(task, failure) {
+		e.releaseTaskLocked(task, exit.Status, exit.Error)
+		return
+	}
+
+	backoff := retryBackoff(task.Retry, len(task.Attempts))
+	e.requeueTaskLocked(task, fmt.Sprintf("retrying after %s failure", failure))
+	retryAt := time.Now().Add(backoff)
+	task.RetryAt = &retryAt
+
+	e.logger.Info("Task will be retried",
+		zap.String("task_id", task.ID),
+		zap.String("failure", string(failure)),
+		zap.Int("attempts", len(task.Attempts)),
+		zap.Duration("backoff", backoff),
+	)
+}
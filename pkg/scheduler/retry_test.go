@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// newRetryTask queues a batch task with a retry policy and schedules it
+func newRetryTask(t *testing.T, engine *Engine, stateManager *StateManager, id string, policy *models.RetryPolicy) *models.Task {
+	t.Helper()
+
+	task := &models.Task{
+		ID:       id,
+		Priority: models.PriorityLow,
+		GPUCount: 1,
+		Command:  "train",
+		Retry:    policy,
+		Status:   models.TaskStatusPending,
+	}
+	stateManager.AddTask(task)
+	if err := engine.scheduleTask(task); err != nil {
+		t.Fatalf("Failed to schedule task: %v", err)
+	}
+	return task
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &models.RetryPolicy{Backoff: 10, MaxBackoff: 60}
+
+	for retry, want := range map[int]time.Duration{
+		1: 10 * time.Second,
+		2: 20 * time.Second,
+		3: 40 * time.Second,
+		4: 60 * time.Second,
+		9: 60 * time.Second,
+	} {
+		if got := retryBackoff(policy, retry); got != want {
+			t.Errorf("Retry %d: expected %v backoff, got %v", retry, want, got)
+		}
+	}
+
+	if got := retryBackoff(&models.RetryPolicy{Backoff: 3600}, 50); got != maxRetryBackoff {
+		t.Errorf("Expected uncapped policies to stop at %v, got %v", maxRetryBackoff, got)
+	}
+}
+
+func TestFailedTaskIsRetriedAfterBackoff(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 2
+
+	task := newRetryTask(t, engine, stateManager, "flaky", &models.RetryPolicy{MaxAttempts: 3, Backoff: 60})
+	gpuID := task.AssignedGPUs[0]
+
+	exitCode := 1
+	msg := "exit status 1"
+	exit := TaskExit{Status: models.TaskStatusFailed, ExitCode: &exitCode, Error: &msg}
+	if err := engine.ReleaseTask("flaky", exit); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	if task.Status != models.TaskStatusPending {
+		t.Fatalf("Expected the task to be requeued, got %s", task.Status)
+	}
+	if task.RetryAt == nil || time.Until(*task.RetryAt) < 50*time.Second {
+		t.Errorf("Expected the retry to wait for the backoff, got %v", task.RetryAt)
+	}
+	if state.Quota.BatchUsed != 0 || state.GPUs[gpuID].Status != models.GPUStatusIdle {
+		t.Error("Expected the failed attempt's resources to be released")
+	}
+
+	if len(task.Attempts) != 1 {
+		t.Fatalf("Expected one recorded attempt, got %d", len(task.Attempts))
+	}
+	attempt := task.Attempts[0]
+	if attempt.Attempt != 1 || attempt.Status != models.TaskStatusFailed || attempt.Failure != models.FailureExit {
+		t.Errorf("Unexpected attempt: %+v", attempt)
+	}
+	if attempt.ExitCode == nil || *attempt.ExitCode != 1 || attempt.Error != msg {
+		t.Errorf("Expected exit info to be recorded, got %+v", attempt)
+	}
+	if len(attempt.Nodes) != 1 || attempt.Nodes[0] != "node-a" || len(attempt.GPUs) != 1 || attempt.GPUs[0] != gpuID {
+		t.Errorf("Expected placement to be recorded, got nodes %v gpus %v", attempt.Nodes, attempt.GPUs)
+	}
+}
+
+func TestRetryLimits(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 2
+
+	// GPU errors aren't retried unless the policy asks for it
+	gpuError := newRetryTask(t, engine, stateManager, "oom", &models.RetryPolicy{MaxAttempts: 3})
+	if err := engine.ReleaseTask("oom", TaskExit{Status: models.TaskStatusFailed, Failure: models.FailureGPUError}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+
+	// The last attempt fails for good
+	exhausted := newRetryTask(t, engine, stateManager, "exhausted", &models.RetryPolicy{MaxAttempts: 2})
+	state.mu.Lock()
+	exhausted.Attempts = []models.TaskAttempt{{Attempt: 1, Status: models.TaskStatusFailed, Failure: models.FailureExit}}
+	state.mu.Unlock()
+	if err := engine.ReleaseTask("exhausted", TaskExit{Status: models.TaskStatusFailed}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	if gpuError.Status != models.TaskStatusFailed {
+		t.Errorf("Expected the GPU error not to be retried, got %s", gpuError.Status)
+	}
+	if exhausted.Status != models.TaskStatusFailed || len(exhausted.Attempts) != 2 {
+		t.Errorf("Expected the task to fail after 2 attempts, got %s with %d attempts", exhausted.Status, len(exhausted.Attempts))
+	}
+}
+
+func TestLostAgentRetriesTasks(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 2, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetAgentTimeout(15 * time.Second)
+	state := stateManager.GetState()
+	state.Quota.BatchQuota = 2
+
+	state.mu.Lock()
+	state.Agents["node-a"] = &models.Agent{
+		ID:            "node-a",
+		Status:        models.AgentStatusOnline,
+		LastHeartbeat: time.Now().Add(-time.Minute),
+	}
+	state.mu.Unlock()
+
+	task := newRetryTask(t, engine, stateManager, "stranded", &models.RetryPolicy{MaxAttempts: 2, Backoff: 60})
+	gpuID := task.AssignedGPUs[0]
+
+	engine.detectLostAgents(time.Now())
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	if state.Agents["node-a"].Status != models.AgentStatusOffline {
+		t.Error("Expected the silent agent to be marked offline")
+	}
+	if state.GPUs[gpuID].Status != models.GPUStatusOffline {
+		t.Errorf("Expected the agent's GPUs to go offline, got %s", state.GPUs[gpuID].Status)
+	}
+	if task.Status != models.TaskStatusPending {
+		t.Errorf("Expected the task to be requeued, got %s", task.Status)
+	}
+	if len(task.Attempts) != 1 || task.Attempts[0].Failure != models.FailureAgentLost {
+		t.Errorf("Expected an agent_lost attempt, got %+v", task.Attempts)
+	}
+}
@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// nextRun returns when a schedule should next create a task, after the
+// given time and not before its start time, or nil if it never will
+func nextRun(schedule *models.ScheduledTask, after time.Time) (*time.Time, error) {
+	// One-shot schedules run once at their start time
+	if schedule.Cron == "" {
+		if schedule.NotBefore == nil || schedule.LastRunAt != nil {
+			return nil, nil
+		}
+		next := *schedule.NotBefore
+		return &next, nil
+	}
+
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.NotBefore != nil && schedule.NotBefore.After(after) {
+		// Next is exclusive, step back so a run at the start time counts
+		after = schedule.NotBefore.Add(-time.Nanosecond)
+	}
+	next := cron.Next(after)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// AddSchedule adds a scheduled task and computes its first run
+func (sm *StateManager) AddSchedule(schedule *models.ScheduledTask) error {
+	if schedule.Cron == "" && schedule.NotBefore == nil {
+		return fmt.Errorf("schedule needs a cron expression or a start time")
+	}
+
+	next, err := nextRun(schedule, schedule.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	schedule.NextRunAt = next
+	sm.state.Schedules[schedule.ID] = schedule
+
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+	return nil
+}
+
+// GetSchedule retrieves a scheduled task by ID
+func (sm *StateManager) GetSchedule(scheduleID string) (*models.ScheduledTask, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	schedule, exists := sm.state.Schedules[scheduleID]
+	if !exists {
+		return nil, fmt.Errorf("schedule not found: %s", scheduleID)
+	}
+	return schedule, nil
+}
+
+// ListSchedules returns every scheduled task
+func (sm *StateManager) ListSchedules() []*models.ScheduledTask {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	schedules := make([]*models.ScheduledTask, 0, len(sm.state.Schedules))
+	for _, schedule := range sm.state.Schedules {
+		schedules = append(schedules, schedule)
+	}
+	return schedules
+}
+
+// DeleteSchedule removes a scheduled task. Tasks it already created keep
+// running.
+func (sm *StateManager) DeleteSchedule(scheduleID string) error {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	if _, exists := sm.state.Schedules[scheduleID]; !exists {
+		return fmt.Errorf("schedule not found: %s", scheduleID)
+	}
+	delete(sm.state.Schedules, scheduleID)
+
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+	return nil
+}
+
+// SetSchedulePaused pauses or resumes a scheduled task. Runs missed while
+// paused are skipped, a resumed schedule waits for its next due time.
+func (sm *StateManager) SetSchedulePaused(scheduleID string, paused bool) (*models.ScheduledTask, error) {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	schedule, exists := sm.state.Schedules[scheduleID]
+	if !exists {
+		return nil, fmt.Errorf("schedule not found: %s", scheduleID)
+	}
+
+	if schedule.Paused && !paused {
+		next, err := nextRun(schedule, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		schedule.NextRunAt = next
+	}
+	schedule.Paused = paused
+
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+	return schedule, nil
+}
+
+// runDueSchedules creates a task for every schedule whose next run is due.
+// Runs missed while the scheduler was down are made up by a single run.
+func (e *Engine) runDueSchedules(now time.Time) {
+	state := e.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	changed := false
+	for _, schedule := range state.Schedules {
+		if schedule.Paused || schedule.NextRunAt == nil || schedule.NextRunAt.After(now) {
+			continue
+		}
+		changed = true
+
+		e.runScheduleLocked(schedule, now)
+
+		next, err := nextRun(schedule, now)
+		if err != nil {
+			// Validated when the schedule was added
+			e.logger.Error("Invalid schedule, pausing it",
+				zap.String("schedule_id", schedule.ID),
+				zap.Error(err),
+			)
+			schedule.Paused = true
+		}
+		schedule.NextRunAt = next
+	}
+
+	if changed {
+		e.state.incrementVersion()
+		e.state.triggerSnapshot()
+	}
+}
+
+// runScheduleLocked applies a schedule's concurrency policy and creates its
+// next task (must hold lock)
+func (e *Engine) runScheduleLocked(schedule *models.ScheduledTask, now time.Time) {
+	state := e.state.state
+	due := *schedule.NextRunAt
+
+	active := make([]*models.Task, 0)
+	for _, taskID := range schedule.Runs {
+		if task, exists := state.Tasks[taskID]; exists && !task.Status.IsFinal() {
+			active = append(active, task)
+		}
+	}
+
+	if len(active) > 0 {
+		switch schedule.ConcurrencyPolicy {
+		case models.ConcurrencyForbid:
+			e.logger.Info("Scheduled run skipped, previous run still active",
+				zap.String("schedule_id", schedule.ID),
+				zap.Int("active", len(active)),
+			)
+			return
+		case models.ConcurrencyReplace:
+			for _, task := range active {
+				e.cancelTaskLocked(task, now)
+			}
+		}
+	}
+
+	task := schedule.Template
+	task.ID = fmt.Sprintf("%s-%d", schedule.ID, due.Unix())
+	task.ScheduleID = schedule.ID
+	task.Status = models.TaskStatusPending
+	task.CreatedAt = now
+	if schedule.Template.Env != nil {
+		task.Env = make(map[string]string, len(schedule.Template.Env))
+		for k, v := range schedule.Template.Env {
+			task.Env[k] = v
+		}
+	}
+
+	state.Tasks[task.ID] = &task
+	e.state.syncTaskLocked(&task)
+
+	schedule.LastRunAt = &now
+	schedule.Runs = append(schedule.Runs, task.ID)
+
+	e.logger.Info("Scheduled task created",
+		zap.String("schedule_id", schedule.ID),
+		zap.String("task_id", task.ID),
+		zap.Time("due", due),
+	)
+}
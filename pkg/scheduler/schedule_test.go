@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newTestSchedule(id, cron string, policy models.ConcurrencyPolicy, createdAt time.Time) *models.ScheduledTask {
+	return &models.ScheduledTask{
+		ID:                id,
+		Cron:              cron,
+		ConcurrencyPolicy: policy,
+		Template: models.Task{
+			Priority: models.PriorityLow,
+			GPUCount: 1,
+			Command:  "nightly",
+			Env:      map[string]string{"MODE": "eval"},
+		},
+		CreatedAt: createdAt,
+	}
+}
+
+func TestScheduleCreatesTasksWhenDue(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	created := time.Date(2025, 1, 15, 10, 7, 0, 0, time.UTC)
+	schedule := newTestSchedule("nightly", "0 2 * * *", models.ConcurrencyAllow, created)
+	if err := stateManager.AddSchedule(schedule); err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+	if want := time.Date(2025, 1, 16, 2, 0, 0, 0, time.UTC); !schedule.NextRunAt.Equal(want) {
+		t.Fatalf("Expected first run at %v, got %v", want, schedule.NextRunAt)
+	}
+
+	// Not due yet
+	engine.runDueSchedules(created.Add(time.Hour))
+	if len(schedule.Runs) != 0 {
+		t.Fatalf("Expected no run before the due time, got %v", schedule.Runs)
+	}
+
+	// Two missed runs are made up by a single one
+	now := time.Date(2025, 1, 17, 9, 0, 0, 0, time.UTC)
+	engine.runDueSchedules(now)
+	if len(schedule.Runs) != 1 {
+		t.Fatalf("Expected one run, got %v", schedule.Runs)
+	}
+	if want := time.Date(2025, 1, 18, 2, 0, 0, 0, time.UTC); !schedule.NextRunAt.Equal(want) {
+		t.Errorf("Expected next run at %v, got %v", want, schedule.NextRunAt)
+	}
+
+	task, err := stateManager.GetTask(schedule.Runs[0])
+	if err != nil {
+		t.Fatalf("Expected the run's task to exist: %v", err)
+	}
+	if task.Status != models.TaskStatusPending || task.ScheduleID != "nightly" {
+		t.Errorf("Unexpected task: status %s, schedule %q", task.Status, task.ScheduleID)
+	}
+	task.Env["MODE"] = "changed"
+	if schedule.Template.Env["MODE"] != "eval" {
+		t.Error("Expected the task to get its own copy of the template environment")
+	}
+	if stateManager.GetState().Queues[models.PriorityLow].Len() != 1 {
+		t.Error("Expected the task to be queued")
+	}
+}
+
+func TestScheduleConcurrencyPolicies(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	created := time.Date(2025, 1, 15, 10, 7, 0, 0, time.UTC)
+	forbid := newTestSchedule("forbid", "*/10 * * * *", models.ConcurrencyForbid, created)
+	replace := newTestSchedule("replace", "*/10 * * * *", models.ConcurrencyReplace, created)
+	for _, schedule := range []*models.ScheduledTask{forbid, replace} {
+		if err := stateManager.AddSchedule(schedule); err != nil {
+			t.Fatalf("Failed to add schedule: %v", err)
+		}
+	}
+
+	engine.runDueSchedules(created.Add(5 * time.Minute))
+	engine.runDueSchedules(created.Add(15 * time.Minute))
+
+	// The first forbid run is still pending, the second run is skipped
+	if len(forbid.Runs) != 1 {
+		t.Errorf("Expected forbid to skip the overlapping run, got %v", forbid.Runs)
+	}
+
+	// The first replace run is cancelled in favor of the second
+	if len(replace.Runs) != 2 {
+		t.Fatalf("Expected replace to start a second run, got %v", replace.Runs)
+	}
+	first, _ := stateManager.GetTask(replace.Runs[0])
+	second, _ := stateManager.GetTask(replace.Runs[1])
+	if first.Status != models.TaskStatusCancelled {
+		t.Errorf("Expected the replaced run to be cancelled, got %s", first.Status)
+	}
+	if second.Status != models.TaskStatusPending {
+		t.Errorf("Expected the new run to be pending, got %s", second.Status)
+	}
+}
+
+func TestOneShotScheduleAndPause(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+
+	created := time.Date(2025, 1, 15, 10, 7, 0, 0, time.UTC)
+	start := created.Add(2 * time.Hour)
+	schedule := newTestSchedule("later", "", models.ConcurrencyAllow, created)
+	schedule.NotBefore = &start
+	if err := stateManager.AddSchedule(schedule); err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+
+	if _, err := stateManager.SetSchedulePaused("later", true); err != nil {
+		t.Fatalf("Failed to pause schedule: %v", err)
+	}
+	engine.runDueSchedules(start.Add(time.Minute))
+	if len(schedule.Runs) != 0 {
+		t.Fatalf("Expected a paused schedule not to run, got %v", schedule.Runs)
+	}
+
+	if _, err := stateManager.SetSchedulePaused("later", false); err != nil {
+		t.Fatalf("Failed to resume schedule: %v", err)
+	}
+	engine.runDueSchedules(time.Now())
+	engine.runDueSchedules(time.Now())
+	if len(schedule.Runs) != 1 {
+		t.Errorf("Expected a one-shot schedule to run exactly once, got %v", schedule.Runs)
+	}
+	if schedule.NextRunAt != nil {
+		t.Errorf("Expected no further run, got %v", schedule.NextRunAt)
+	}
+}
+
+func TestSchedulesPersistInSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	stateManager := NewStateManager(dir)
+	schedule := newTestSchedule("nightly", "0 2 * * *", models.ConcurrencyForbid, time.Now())
+	if err := stateManager.AddSchedule(schedule); err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+	if err := stateManager.SaveSnapshot(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored := NewStateManager(dir)
+	if err := restored.LoadSnapshot(); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	got, err := restored.GetSchedule("nightly")
+	if err != nil {
+		t.Fatalf("Expected schedule to be restored: %v", err)
+	}
+	if got.Cron != "0 2 * * *" || got.ConcurrencyPolicy != models.ConcurrencyForbid {
+		t.Errorf("Unexpected restored schedule: %+v", got)
+	}
+	if got.NextRunAt == nil || !got.NextRunAt.Equal(*schedule.NextRunAt) {
+		t.Errorf("Expected next run %v to be restored, got %v", schedule.NextRunAt, got.NextRunAt)
+	}
+	if got.Template.Command != "nightly" {
+		t.Errorf("Expected template to be restored, got %+v", got.Template)
+	}
+}
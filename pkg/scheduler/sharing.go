@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// GPUSharingConfig controls which GPUs tasks asking for a GPU slice may
+// share
+type GPUSharingConfig struct {
+	// Models are the GPU models that may be shared, none by default
+	Models []string
+}
+
+// SetGPUSharing configures GPU sharing
+func (e *Engine) SetGPUSharing(cfg GPUSharingConfig) {
+	e.sharedModels = make(map[string]bool, len(cfg.Models))
+	for _, model := range cfg.Models {
+		e.sharedModels[model] = true
+	}
+}
+
+// sliceOf returns the compute share and memory a task gets on a GPU,
+// deriving whichever the task didn't ask for from the other
+func sliceOf(share *models.GPUShare, gpu *models.GPU) (int, int64) {
+	percent, memory := share.Percent, share.MemoryMB
+	if percent == 0 && gpu.Memory > 0 {
+		// Round up so the share covers the memory
+		percent = int((memory*100 + gpu.Memory - 1) / gpu.Memory)
+	}
+	if memory == 0 {
+		memory = gpu.Memory * int64(percent) / 100
+	}
+	return max(percent, 1), memory
+}
+
+// freeSlice returns the compute share and memory left on a GPU
+func freeSlice(gpu *models.GPU) (int, int64) {
+	percent, memory := 100, gpu.Memory
+	for _, tenant := range gpu.Tenants {
+		percent -= tenant.Percent
+		memory -= tenant.MemoryMB
+	}
+	return percent, memory
+}
+
+// isShared reports whether a GPU is busy with tasks sharing it
+func isShared(gpu *models.GPU) bool {
+	return gpu.Status == models.GPUStatusBusy && gpu.CurrentTask == nil && len(gpu.Tenants) > 0
+}
+
+// findSharedGPU picks the GPU a task asking for a slice runs on. After the
+// task's node preferences, GPUs that are already shared are filled up
+// first, so idle GPUs stay whole for tasks that need them.
+func (e *Engine) findSharedGPU(task *models.Task, allGPUs map[string]*models.GPU, fit *nodeFit, booked *reservationFit, hosts *hostFit) ([]*models.GPU, error) {
+	type candidate struct {
+		gpu   *models.GPU
+		score float64
+		free  int
+	}
+
+	candidates := make([]candidate, 0)
+	for _, gpu := range allGPUs {
+		if e.gpuFilterLocked(task, gpu, fit, booked, hosts) != "" {
+			continue
+		}
+
+		freePercent, _ := freeSlice(gpu)
+		c := candidate{gpu: gpu, free: freePercent}
+		if fit != nil {
+			c.score = fit.scores[gpu.NodeID]
+		}
+		candidates = append(candidates, c)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no shareable GPU has room for the task's slice")
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if candidates[i].free != candidates[j].free {
+			return candidates[i].free < candidates[j].free
+		}
+		return candidates[i].gpu.ID < candidates[j].gpu.ID
+	})
+	return []*models.GPU{candidates[0].gpu}, nil
+}
+
+// addTenant gives a task a slice of a shared GPU
+func addTenant(gpu *models.GPU, task *models.Task, now time.Time) {
+	percent, memory := sliceOf(task.GPUShare, gpu)
+	gpu.Tenants = append(gpu.Tenants, models.GPUTenant{
+		TaskID:   task.ID,
+		Percent:  percent,
+		MemoryMB: memory,
+	})
+	gpu.Status = models.GPUStatusBusy
+	gpu.CurrentTask = nil
+	gpu.UpdatedAt = now
+}
+
+// freeGPU takes a task off a GPU, which goes idle once no task is left on
+// it. Tenant lists are replaced rather than modified, so copies of the GPU
+// never see the change.
+func freeGPU(gpu *models.GPU, taskID string, now time.Time) {
+	if len(gpu.Tenants) > 0 {
+		tenants := make([]models.GPUTenant, 0, len(gpu.Tenants))
+		for _, tenant := range gpu.Tenants {
+			if tenant.TaskID != taskID {
+				tenants = append(tenants, tenant)
+			}
+		}
+		if len(tenants) > 0 {
+			gpu.Tenants = tenants
+			gpu.UpdatedAt = now
+			return
+		}
+	}
+
+	gpu.Tenants = nil
+	gpu.Status = models.GPUStatusIdle
+	gpu.CurrentTask = nil
+	gpu.UpdatedAt = now
+}
+
+// TenantOf returns a task's slice of a shared GPU
+func TenantOf(gpu *models.GPU, taskID string) (models.GPUTenant, bool) {
+	for _, tenant := range gpu.Tenants {
+		if tenant.TaskID == taskID {
+			return tenant, true
+		}
+	}
+	return models.GPUTenant{}, false
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newSharedTask(id string, share models.GPUShare) *models.Task {
+	return &models.Task{
+		ID:       id,
+		Priority: models.PriorityHigh,
+		GPUCount: 1,
+		GPUShare: &share,
+		Command:  "serve",
+		Status:   models.TaskStatusPending,
+	}
+}
+
+func TestSharedTasksPackOntoOneGPU(t *testing.T) {
+	gpus := newMixedCluster()
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetGPUSharing(GPUSharingConfig{Models: []string{"A100"}})
+	state := stateManager.GetState()
+	state.Quota.OnlineQuota = 8
+
+	first := newSharedTask("infer-1", models.GPUShare{Percent: 25})
+	second := newSharedTask("infer-2", models.GPUShare{MemoryMB: 8192})
+	large := newSharedTask("infer-3", models.GPUShare{Percent: 80})
+	for _, task := range []*models.Task{first, second, large} {
+		stateManager.AddTask(task)
+		if err := engine.scheduleTask(task); err != nil {
+			t.Fatalf("Failed to schedule %s: %v", task.ID, err)
+		}
+	}
+
+	shared := gpus[first.AssignedGPUs[0]]
+	if shared.Model != "A100" {
+		t.Fatalf("Expected a GPU of a shareable model, got %s", shared.Model)
+	}
+	if second.AssignedGPUs[0] != shared.ID {
+		t.Error("Expected the second task to share the first task's GPU")
+	}
+	if large.AssignedGPUs[0] == shared.ID {
+		t.Error("Expected the large slice to need another GPU")
+	}
+
+	// A memory slice gets the matching compute share
+	tenant, ok := TenantOf(shared, "infer-2")
+	if !ok || tenant.Percent != 10 || tenant.MemoryMB != 8192 {
+		t.Errorf("Unexpected tenant: %+v", tenant)
+	}
+	if percent, memory := freeSlice(shared); percent != 65 || memory != 81920-20480-8192 {
+		t.Errorf("Expected 65%% and %d MiB left, got %d%% and %d MiB", 81920-20480-8192, percent, memory)
+	}
+
+	// No shareable GPU has room left for another large slice
+	if err := engine.scheduleTask(newSharedTask("infer-4", models.GPUShare{Percent: 70})); err == nil {
+		t.Error("Expected the V100s not to be shared")
+	}
+
+	// The GPU stays busy until its last tenant is gone
+	if err := engine.ReleaseTask("infer-1", TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+	state.mu.RLock()
+	if shared.Status != models.GPUStatusBusy || len(shared.Tenants) != 1 {
+		t.Errorf("Expected the GPU to keep its other tenant, got %s with %d tenants", shared.Status, len(shared.Tenants))
+	}
+	state.mu.RUnlock()
+
+	if err := engine.ReleaseTask("infer-2", TaskExit{Status: models.TaskStatusSuccess}); err != nil {
+		t.Fatalf("Failed to release task: %v", err)
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	if shared.Status != models.GPUStatusIdle || shared.Tenants != nil {
+		t.Errorf("Expected the GPU to go idle, got %s with %d tenants", shared.Status, len(shared.Tenants))
+	}
+}
+
+func TestExclusiveTasksSkipSharedGPUs(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 1, []int{0})
+	engine, stateManager := newTestEngine(t, gpus)
+	engine.SetGPUSharing(GPUSharingConfig{Models: []string{"TestGPU"}})
+	stateManager.GetState().Quota.OnlineQuota = 2
+
+	slice := newSharedTask("infer-1", models.GPUShare{Percent: 10})
+	stateManager.AddTask(slice)
+	if err := engine.scheduleTask(slice); err != nil {
+		t.Fatalf("Failed to schedule shared task: %v", err)
+	}
+
+	exclusive := &models.Task{ID: "train-1", Priority: models.PriorityHigh, GPUCount: 1, Status: models.TaskStatusPending}
+	stateManager.AddTask(exclusive)
+	if err := engine.scheduleTask(exclusive); err == nil {
+		t.Error("Expected an exclusive task not to be placed on a shared GPU")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,19 +19,45 @@ type State struct {
 	// GPU resources
 	GPUs map[string]*models.GPU // GPU ID -> GPU
 
-	// Task queues
-	HighPriorityQueue []*models.Task
-	LowPriorityQueue  []*models.Task
+	// Pending task queues per priority class, rebuilt from Tasks when a
+	// snapshot is loaded
+	Queues map[models.Priority]*TaskQueue `json:"-"`
+
+	// Queue places kept by unfinished tasks, in submission order
+	queueSeq map[string]uint64
+	nextSeq  uint64
+
+	// Idle GPUs and node loads
+	gpuIndex *gpuIndex
 
 	// All tasks (for tracking)
 	Tasks map[string]*models.Task // Task ID -> Task
 
+	// Workflows
+	Workflows map[string]*models.Workflow // Workflow ID -> Workflow
+
+	// Task arrays
+	Arrays map[string]*models.TaskArray // Array ID -> TaskArray
+
+	// Scheduled tasks
+	Schedules map[string]*models.ScheduledTask // Schedule ID -> ScheduledTask
+
+	// Advance GPU reservations
+	Reservations map[string]*models.Reservation // Reservation ID -> Reservation
+
 	// Agents
 	Agents map[string]*models.Agent // Agent ID -> Agent
 
 	// Quota
 	Quota *models.Quota
 
+	// Hierarchical quotas
+	OrgQuotas  map[string]*models.OrgQuota  // Organization -> quota
+	TeamQuotas map[string]*models.TeamQuota // Team -> quota
+
+	// Recent GPU usage per team for fair-share scheduling
+	TeamUsage map[string]*models.TeamUsage // Team -> usage
+
 	// Metadata
 	Version   int64     // State version for replication
 	UpdatedAt time.Time // Last update time
@@ -48,11 +75,16 @@ type StateManager struct {
 func NewStateManager(snapshotDir string) *StateManager {
 	return &StateManager{
 		state: &State{
-			GPUs:              make(map[string]*models.GPU),
-			HighPriorityQueue: make([]*models.Task, 0),
-			LowPriorityQueue:  make([]*models.Task, 0),
-			Tasks:             make(map[string]*models.Task),
-			Agents:            make(map[string]*models.Agent),
+			GPUs:         make(map[string]*models.GPU),
+			Queues:       make(map[models.Priority]*TaskQueue),
+			queueSeq:     make(map[string]uint64),
+			gpuIndex:     newGPUIndex(),
+			Tasks:        make(map[string]*models.Task),
+			Workflows:    make(map[string]*models.Workflow),
+			Arrays:       make(map[string]*models.TaskArray),
+			Schedules:    make(map[string]*models.ScheduledTask),
+			Reservations: make(map[string]*models.Reservation),
+			Agents:       make(map[string]*models.Agent),
 			Quota: &models.Quota{
 				TotalGPUs:   0,
 				OnlineQuota: 0,
@@ -60,8 +92,11 @@ func NewStateManager(snapshotDir string) *StateManager {
 				OnlineUsed:  0,
 				BatchUsed:   0,
 			},
-			Version:   0,
-			UpdatedAt: time.Now(),
+			OrgQuotas:  make(map[string]*models.OrgQuota),
+			TeamQuotas: make(map[string]*models.TeamQuota),
+			TeamUsage:  make(map[string]*models.TeamUsage),
+			Version:    0,
+			UpdatedAt:  time.Now(),
 		},
 		snapshotDir:  snapshotDir,
 		snapshotChan: make(chan struct{}, 1),
@@ -85,6 +120,7 @@ func (sm *StateManager) AddGPU(gpu *models.GPU) {
 
 	sm.state.GPUs[gpu.ID] = gpu
 	sm.state.Quota.TotalGPUs++
+	sm.syncGPULocked(gpu)
 	sm.incrementVersion()
 	sm.triggerSnapshot()
 }
@@ -96,6 +132,7 @@ func (sm *StateManager) RemoveGPU(gpuID string) {
 
 	if _, exists := sm.state.GPUs[gpuID]; exists {
 		delete(sm.state.GPUs, gpuID)
+		sm.state.gpuIndex.remove(gpuID)
 		sm.state.Quota.TotalGPUs--
 		sm.incrementVersion()
 		sm.triggerSnapshot()
@@ -114,28 +151,99 @@ func (sm *StateManager) UpdateGPUStatus(gpuID string, status models.GPUStatus) e
 
 	gpu.Status = status
 	gpu.UpdatedAt = time.Now()
+	sm.syncGPULocked(gpu)
 	sm.incrementVersion()
 	return nil
 }
 
+// ReportGPUStatus applies the status an agent reports for one of its GPUs
+// and returns whether it changed anything. Whether a GPU is idle or busy is
+// up to the scheduler, so reports only take GPUs offline or bring offline
+// GPUs back.
+func (sm *StateManager) ReportGPUStatus(gpuID string, status models.GPUStatus) (bool, error) {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	gpu, exists := sm.state.GPUs[gpuID]
+	if !exists {
+		return false, fmt.Errorf("GPU not found: %s", gpuID)
+	}
+
+	switch {
+	case status == models.GPUStatusOffline && gpu.Status != models.GPUStatusOffline:
+		gpu.Status = models.GPUStatusOffline
+	case status != models.GPUStatusOffline && gpu.Status == models.GPUStatusOffline:
+		gpu.Status = models.GPUStatusIdle
+	default:
+		return false, nil
+	}
+
+	gpu.UpdatedAt = time.Now()
+	sm.syncGPULocked(gpu)
+	sm.incrementVersion()
+	return true, nil
+}
+
 // AddTask adds a task to the appropriate queue
 func (sm *StateManager) AddTask(task *models.Task) {
 	sm.state.mu.Lock()
 	defer sm.state.mu.Unlock()
 
 	sm.state.Tasks[task.ID] = task
+	sm.syncTaskLocked(task)
 
-	// Add to priority queue
-	if task.Priority == models.PriorityHigh {
-		sm.state.HighPriorityQueue = append(sm.state.HighPriorityQueue, task)
-	} else {
-		sm.state.LowPriorityQueue = append(sm.state.LowPriorityQueue, task)
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+}
+
+// AddWorkflow adds a workflow and its tasks. Tasks without dependencies are
+// queued right away, the others wait for their upstream tasks.
+func (sm *StateManager) AddWorkflow(workflow *models.Workflow, tasks []*models.Task) {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	sm.state.Workflows[workflow.ID] = workflow
+	for _, task := range tasks {
+		sm.state.Tasks[task.ID] = task
+		if len(task.DependsOn) == 0 {
+			task.Status = models.TaskStatusPending
+			sm.syncTaskLocked(task)
+		} else {
+			task.Status = models.TaskStatusWaiting
+		}
 	}
 
 	sm.incrementVersion()
 	sm.triggerSnapshot()
 }
 
+// AddTaskArray adds a task array and queues all of its tasks
+func (sm *StateManager) AddTaskArray(array *models.TaskArray, tasks []*models.Task) {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	sm.state.Arrays[array.ID] = array
+	for _, task := range tasks {
+		sm.state.Tasks[task.ID] = task
+		sm.syncTaskLocked(task)
+	}
+
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+}
+
+// GetWorkflow retrieves a workflow by ID
+func (sm *StateManager) GetWorkflow(workflowID string) (*models.Workflow, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	workflow, exists := sm.state.Workflows[workflowID]
+	if !exists {
+		return nil, fmt.Errorf("workflow not found: %s", workflowID)
+	}
+	return workflow, nil
+}
+
 // GetTask retrieves a task by ID
 func (sm *StateManager) GetTask(taskID string) (*models.Task, error) {
 	sm.state.mu.RLock()
@@ -163,9 +271,10 @@ func (sm *StateManager) UpdateTaskStatus(taskID string, status models.TaskStatus
 
 	if status == models.TaskStatusRunning && task.StartedAt == nil {
 		task.StartedAt = &now
-	} else if (status == models.TaskStatusSuccess || status == models.TaskStatusFailed) && task.FinishedAt == nil {
+	} else if status.IsFinal() && task.FinishedAt == nil {
 		task.FinishedAt = &now
 	}
+	sm.syncTaskLocked(task)
 
 	sm.incrementVersion()
 	return nil
@@ -176,21 +285,62 @@ func (sm *StateManager) RegisterAgent(agent *models.Agent) {
 	sm.state.mu.Lock()
 	defer sm.state.mu.Unlock()
 
+	// Taints and maintenance are set by admins and survive agent restarts
+	if existing, exists := sm.state.Agents[agent.ID]; exists {
+		agent.Taints = existing.Taints
+		agent.Maintenance = existing.Maintenance
+		agent.DrainDeadline = existing.DrainDeadline
+	}
 	sm.state.Agents[agent.ID] = agent
 
-	// Add agent's GPUs to the global GPU pool
+	// Add agent's GPUs to the global GPU pool. GPUs of an agent that
+	// reconnects keep the tasks placed on them.
 	for i := range agent.GPUs {
 		gpu := &agent.GPUs[i]
+		if existing, exists := sm.state.GPUs[gpu.ID]; exists {
+			if existing.Status == models.GPUStatusBusy {
+				gpu.Status = existing.Status
+				gpu.CurrentTask = existing.CurrentTask
+				gpu.Tenants = existing.Tenants
+			}
+		} else {
+			sm.state.Quota.TotalGPUs++
+		}
 		sm.state.GPUs[gpu.ID] = gpu
-		sm.state.Quota.TotalGPUs++
+		sm.syncGPULocked(gpu)
 	}
 
 	sm.incrementVersion()
 	sm.triggerSnapshot()
 }
 
-// UpdateAgentHeartbeat updates agent's last heartbeat time
-func (sm *StateManager) UpdateAgentHeartbeat(agentID string) error {
+// GetAgent retrieves an agent by ID
+func (sm *StateManager) GetAgent(agentID string) (*models.Agent, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	agent, exists := sm.state.Agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+	return agent, nil
+}
+
+// ListAgents returns every registered agent
+func (sm *StateManager) ListAgents() []*models.Agent {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	agents := make([]*models.Agent, 0, len(sm.state.Agents))
+	for _, agent := range sm.state.Agents {
+		agents = append(agents, agent)
+	}
+	return agents
+}
+
+// UpdateAgentHeartbeat updates agent's last heartbeat time and the tasks it
+// reported running
+func (sm *StateManager) UpdateAgentHeartbeat(agentID string, runningTasks []string) error {
 	sm.state.mu.Lock()
 	defer sm.state.mu.Unlock()
 
@@ -200,6 +350,7 @@ func (sm *StateManager) UpdateAgentHeartbeat(agentID string) error {
 	}
 
 	agent.LastHeartbeat = time.Now()
+	agent.RunningTasks = runningTasks
 	agent.Status = models.AgentStatusOnline
 	sm.incrementVersion()
 	return nil
@@ -218,6 +369,80 @@ func (sm *StateManager) SetQuota(onlinePercent, batchPercent float64) {
 	sm.triggerSnapshot()
 }
 
+// SetTeamQuota creates or replaces a team's quota
+func (sm *StateManager) SetTeamQuota(quota *models.TeamQuota) {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	sm.state.TeamQuotas[quota.Team] = quota
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+}
+
+// GetTeamQuota retrieves a team's quota
+func (sm *StateManager) GetTeamQuota(team string) (*models.TeamQuota, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	quota, exists := sm.state.TeamQuotas[team]
+	if !exists {
+		return nil, fmt.Errorf("team quota not found: %s", team)
+	}
+	return quota, nil
+}
+
+// DeleteTeamQuota removes a team's quota
+func (sm *StateManager) DeleteTeamQuota(team string) error {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	if _, exists := sm.state.TeamQuotas[team]; !exists {
+		return fmt.Errorf("team quota not found: %s", team)
+	}
+
+	delete(sm.state.TeamQuotas, team)
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+	return nil
+}
+
+// SetOrgQuota creates or replaces an organization's quota
+func (sm *StateManager) SetOrgQuota(quota *models.OrgQuota) {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	sm.state.OrgQuotas[quota.Organization] = quota
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+}
+
+// GetOrgQuota retrieves an organization's quota
+func (sm *StateManager) GetOrgQuota(org string) (*models.OrgQuota, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	quota, exists := sm.state.OrgQuotas[org]
+	if !exists {
+		return nil, fmt.Errorf("organization quota not found: %s", org)
+	}
+	return quota, nil
+}
+
+// DeleteOrgQuota removes an organization's quota
+func (sm *StateManager) DeleteOrgQuota(org string) error {
+	sm.state.mu.Lock()
+	defer sm.state.mu.Unlock()
+
+	if _, exists := sm.state.OrgQuotas[org]; !exists {
+		return fmt.Errorf("organization quota not found: %s", org)
+	}
+
+	delete(sm.state.OrgQuotas, org)
+	sm.incrementVersion()
+	sm.triggerSnapshot()
+	return nil
+}
+
 // incrementVersion increments the state version (must hold lock)
 func (sm *StateManager) incrementVersion() {
 	sm.state.Version++
@@ -281,6 +506,28 @@ func (sm *StateManager) LoadSnapshot() error {
 		return fmt.Errorf("failed to unmarshal state: %w", err)
 	}
 
+	// Queue the tasks that are pending or may be requeued, in submission
+	// order
+	tasks := make([]*models.Task, 0)
+	for _, task := range sm.state.Tasks {
+		if task.Status == models.TaskStatusPending || task.Status == models.TaskStatusRunning {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	sm.state.Queues = make(map[models.Priority]*TaskQueue)
+	sm.state.queueSeq = make(map[string]uint64)
+	for _, task := range tasks {
+		sm.syncTaskLocked(task)
+	}
+
+	sm.state.gpuIndex = newGPUIndex()
+	for _, gpu := range sm.state.GPUs {
+		sm.syncGPULocked(gpu)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// linkScore rates the interconnect between two GPUs as reported by
+// `nvidia-smi topo -m`, from 1.0 (NVLink) down to 0 (unknown)
+func linkScore(link string) float64 {
+	switch {
+	case strings.HasPrefix(link, "NV"):
+		return 1.0
+	case link == "PIX":
+		return 0.8
+	case link == "PXB":
+		return 0.6
+	case link == "PHB":
+		return 0.4
+	case link == "NODE":
+		return 0.3
+	case link == "SYS":
+		return 0.2
+	default:
+		return 0
+	}
+}
+
+// pairScore rates the connection between two GPUs on the same node
+func pairScore(a, b *models.GPU) float64 {
+	if link, exists := a.Links[b.DeviceIndex]; exists {
+		return linkScore(link)
+	}
+	if link, exists := b.Links[a.DeviceIndex]; exists {
+		return linkScore(link)
+	}
+	return 0
+}
+
+// topologyScore returns the mean pairwise link score of a GPU set. It
+// returns false if the GPUs span nodes or no topology was reported.
+func topologyScore(gpus []*models.GPU) (float64, bool) {
+	if len(gpus) == 0 {
+		return 0, false
+	}
+
+	hasTopology := false
+	for _, gpu := range gpus {
+		if gpu.NodeID != gpus[0].NodeID {
+			return 0, false
+		}
+		if len(gpu.Links) > 0 {
+			hasTopology = true
+		}
+	}
+	if len(gpus) == 1 {
+		return 1.0, true
+	}
+	if !hasTopology {
+		return 0, false
+	}
+
+	total := 0.0
+	pairs := 0
+	for i := 0; i < len(gpus); i++ {
+		for j := i + 1; j < len(gpus); j++ {
+			total += pairScore(gpus[i], gpus[j])
+			pairs++
+		}
+	}
+	return total / float64(pairs), true
+}
+
+// bestConnectedSet greedily picks the count best connected GPUs of a single
+// node, trying every GPU as the seed of the set
+func bestConnectedSet(gpus []*models.GPU, count int) ([]*models.GPU, float64) {
+	if len(gpus) < count {
+		return nil, 0
+	}
+
+	var best []*models.GPU
+	bestScore := -1.0
+
+	for seed := range gpus {
+		set := []*models.GPU{gpus[seed]}
+		used := map[int]bool{seed: true}
+
+		for len(set) < count {
+			next := -1
+			nextScore := -1.0
+			for i, gpu := range gpus {
+				if used[i] {
+					continue
+				}
+				score := 0.0
+				for _, member := range set {
+					score += pairScore(member, gpu)
+				}
+				if score > nextScore {
+					next = i
+					nextScore = score
+				}
+			}
+			set = append(set, gpus[next])
+			used[next] = true
+		}
+
+		score, _ := topologyScore(set)
+		if score > bestScore {
+			best = set
+			bestScore = score
+		}
+	}
+
+	return best, bestScore
+}
+
+// placeNodeLocal picks count GPUs from a single node. Nodes are ranked by
+// the placement policy score plus the topology score of their best
+// connected GPU set, so tightly connected sets are preferred.
+func placeNodeLocal(policy PlacementPolicy, candidates []*models.GPU, count int, loads map[string]*NodeLoad) ([]*models.GPU, error) {
+	byNode := make(map[string][]*models.GPU)
+	for _, gpu := range candidates {
+		byNode[gpu.NodeID] = append(byNode[gpu.NodeID], gpu)
+	}
+	nodeIDs := make([]string, 0, len(byNode))
+	maxPerNode := 0
+	for nodeID, gpus := range byNode {
+		sort.Slice(gpus, func(i, j int) bool {
+			return gpus[i].DeviceIndex < gpus[j].DeviceIndex
+		})
+		nodeIDs = append(nodeIDs, nodeID)
+		if len(gpus) > maxPerNode {
+			maxPerNode = len(gpus)
+		}
+	}
+	sort.Strings(nodeIDs)
+
+	var best []*models.GPU
+	bestScore := 0.0
+	for _, nodeID := range nodeIDs {
+		set, topoScore := bestConnectedSet(byNode[nodeID], count)
+		if set == nil {
+			continue
+		}
+
+		load := &NodeLoad{NodeID: nodeID, Total: len(byNode[nodeID])}
+		if l, exists := loads[nodeID]; exists {
+			load = l
+		}
+
+		score := policy.ScoreNode(load) + topoScore
+		if best == nil || score > bestScore {
+			best = set
+			bestScore = score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("insufficient GPUs on a single node: need %d, max %d per node", count, maxPerNode)
+	}
+	return best, nil
+}
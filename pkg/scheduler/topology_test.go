@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+// setLinks applies a symmetric link matrix to the GPUs of a node
+func setLinks(gpus map[string]*models.GPU, nodeID string, matrix [][]string) {
+	for _, gpu := range gpus {
+		if gpu.NodeID != nodeID {
+			continue
+		}
+		gpu.Links = make(map[int]string)
+		for peer, link := range matrix[gpu.DeviceIndex] {
+			if peer != gpu.DeviceIndex {
+				gpu.Links[peer] = link
+			}
+		}
+	}
+}
+
+func TestPlaceNodeLocalPrefersNVLink(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a"}, 4, []int{0})
+	setLinks(gpus, "node-a", [][]string{
+		{"X", "PHB", "SYS", "NV2"},
+		{"PHB", "X", "NV2", "SYS"},
+		{"SYS", "NV2", "X", "PHB"},
+		{"NV2", "SYS", "PHB", "X"},
+	})
+
+	selected, err := placeNodeLocal(binPackPolicy{}, idleGPUs(gpus), 2, computeNodeLoads(gpus))
+	if err != nil {
+		t.Fatalf("placeNodeLocal() error = %v", err)
+	}
+
+	score, ok := topologyScore(selected)
+	if !ok || score != 1.0 {
+		t.Errorf("Expected NVLink pair with score 1.0, got %v (ok=%v)", score, ok)
+	}
+}
+
+func TestPlaceNodeLocalKeepsTaskOnOneNode(t *testing.T) {
+	gpus := newTestCluster([]string{"node-a", "node-b"}, 4, []int{2, 1})
+
+	selected, err := placeNodeLocal(binPackPolicy{}, idleGPUs(gpus), 3, computeNodeLoads(gpus))
+	if err != nil {
+		t.Fatalf("placeNodeLocal() error = %v", err)
+	}
+	for _, gpu := range selected {
+		if gpu.NodeID != "node-b" {
+			t.Errorf("Expected all GPUs on node-b, got %s", gpu.NodeID)
+		}
+	}
+
+	// Four idle GPUs exist in total, but no node has four
+	if _, err := placeNodeLocal(binPackPolicy{}, idleGPUs(gpus), 4, computeNodeLoads(gpus)); err == nil {
+		t.Error("Expected error when no single node has enough GPUs")
+	}
+}
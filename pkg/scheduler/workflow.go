@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+	"go.uber.org/zap"
+)
+
+// WorkflowSummary is a workflow with the aggregated state of its tasks
+type WorkflowSummary struct {
+	*models.Workflow
+	Status models.WorkflowStatus        `json:"status"`
+	Counts map[models.TaskStatus]int    `json:"counts"`
+	States map[string]models.TaskStatus `json:"task_states"` // task ID -> status
+}
+
+// dependencyMet reports whether a finished upstream task satisfies a
+// dependency condition
+func dependencyMet(condition models.DependencyCondition, upstream models.TaskStatus) bool {
+	switch condition {
+	case models.DependOnSuccess:
+		return upstream == models.TaskStatusSuccess
+	case models.DependOnFailure:
+		return upstream.IsFailure()
+	case models.DependAlways:
+		return upstream.IsFinal()
+	default:
+		return false
+	}
+}
+
+// resolveDependentsLocked queues the waiting tasks of a workflow whose
+// upstream tasks have all finished as required, and skips those whose
+// conditions can no longer be met (must hold lock)
+func (e *Engine) resolveDependentsLocked(finished *models.Task) {
+	state := e.state.state
+
+	workflow, exists := state.Workflows[finished.WorkflowID]
+	if !exists {
+		return
+	}
+
+	for _, taskID := range workflow.Tasks {
+		task, exists := state.Tasks[taskID]
+		if !exists || task.Status != models.TaskStatusWaiting || !dependsOn(task, finished.ID) {
+			continue
+		}
+
+		ready := true
+		met := true
+		for _, dep := range task.DependsOn {
+			upstream, exists := state.Tasks[dep.TaskID]
+			if !exists || !upstream.Status.IsFinal() {
+				ready = false
+				break
+			}
+			if !dependencyMet(dep.Condition, upstream.Status) {
+				met = false
+			}
+		}
+		if !ready {
+			continue
+		}
+
+		if !met {
+			now := time.Now()
+			task.Status = models.TaskStatusSkipped
+			task.StatusReason = "dependency condition not met"
+			task.FinishedAt = &now
+			e.state.syncTaskLocked(task)
+
+			e.logger.Info("Workflow task skipped",
+				zap.String("workflow_id", workflow.ID),
+				zap.String("task_id", task.ID),
+			)
+
+			// Skipping may settle tasks further downstream
+			e.resolveDependentsLocked(task)
+			continue
+		}
+
+		task.Status = models.TaskStatusPending
+		e.state.syncTaskLocked(task)
+
+		e.logger.Info("Workflow task queued",
+			zap.String("workflow_id", workflow.ID),
+			zap.String("task_id", task.ID),
+		)
+	}
+
+	state.Version++
+	state.UpdatedAt = time.Now()
+}
+
+// dependsOn reports whether a task has the given upstream task
+func dependsOn(task *models.Task, upstreamID string) bool {
+	for _, dep := range task.DependsOn {
+		if dep.TaskID == upstreamID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWorkflowSummary returns a workflow with the aggregated state of its
+// tasks. A workflow succeeds once every task finished without failing;
+// tasks that failed with an on_failure handler count as handled.
+func (sm *StateManager) GetWorkflowSummary(workflowID string) (*WorkflowSummary, error) {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	workflow, exists := sm.state.Workflows[workflowID]
+	if !exists {
+		return nil, fmt.Errorf("workflow not found: %s", workflowID)
+	}
+	return sm.workflowSummaryLocked(workflow), nil
+}
+
+// ListWorkflowSummaries returns every workflow with the aggregated state of
+// its tasks
+func (sm *StateManager) ListWorkflowSummaries() []*WorkflowSummary {
+	sm.state.mu.RLock()
+	defer sm.state.mu.RUnlock()
+
+	summaries := make([]*WorkflowSummary, 0, len(sm.state.Workflows))
+	for _, workflow := range sm.state.Workflows {
+		summaries = append(summaries, sm.workflowSummaryLocked(workflow))
+	}
+	return summaries
+}
+
+// workflowSummaryLocked aggregates the state of a workflow's tasks (must
+// hold lock)
+func (sm *StateManager) workflowSummaryLocked(workflow *models.Workflow) *WorkflowSummary {
+	summary := &WorkflowSummary{
+		Workflow: workflow,
+		Counts:   make(map[models.TaskStatus]int),
+		States:   make(map[string]models.TaskStatus, len(workflow.Tasks)),
+	}
+
+	handled := make(map[string]bool)
+	for _, taskID := range workflow.Tasks {
+		task, exists := sm.state.Tasks[taskID]
+		if !exists {
+			continue
+		}
+		summary.Counts[task.Status]++
+		summary.States[taskID] = task.Status
+
+		for _, dep := range task.DependsOn {
+			if dep.Condition == models.DependOnFailure && task.Status != models.TaskStatusSkipped {
+				handled[dep.TaskID] = true
+			}
+		}
+	}
+
+	finished := 0
+	failed := false
+	for taskID, status := range summary.States {
+		if status.IsFinal() {
+			finished++
+		}
+		if status.IsFailure() && !handled[taskID] {
+			failed = true
+		}
+	}
+
+	switch {
+	case finished == len(summary.States):
+		summary.Status = models.WorkflowStatusSuccess
+		if failed {
+			summary.Status = models.WorkflowStatusFailed
+		}
+	case finished > 0 || summary.Counts[models.TaskStatusRunning] > 0:
+		summary.Status = models.WorkflowStatusRunning
+	default:
+		summary.Status = models.WorkflowStatusPending
+	}
+
+	return summary
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/chicogong/dgpu-scheduler/pkg/models"
+)
+
+func newWorkflowTask(id string, deps ...models.Dependency) *models.Task {
+	return &models.Task{
+		ID:         id,
+		Name:       id,
+		WorkflowID: "wf",
+		Priority:   models.PriorityLow,
+		GPUCount:   1,
+		Command:    id,
+		DependsOn:  deps,
+	}
+}
+
+// finishTask records a task's outcome and resolves its dependents
+func finishTask(engine *Engine, task *models.Task, status models.TaskStatus) {
+	state := engine.state.GetState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	task.Status = status
+	engine.resolveDependentsLocked(task)
+}
+
+func TestWorkflowDependencies(t *testing.T) {
+	engine, stateManager := newTestEngine(t, nil)
+
+	preprocess := newWorkflowTask("preprocess")
+	train := newWorkflowTask("train", models.Dependency{TaskID: "preprocess", Condition: models.DependOnSuccess})
+	evaluate := newWorkflowTask("evaluate", models.Dependency{TaskID: "train", Condition: models.DependOnSuccess})
+	cleanup := newWorkflowTask("cleanup", models.Dependency{TaskID: "train", Condition: models.DependOnFailure})
+	notify := newWorkflowTask("notify", models.Dependency{TaskID: "evaluate", Condition: models.DependAlways})
+	tasks := []*models.Task{preprocess, train, evaluate, cleanup, notify}
+
+	workflow := &models.Workflow{ID: "wf"}
+	for _, task := range tasks {
+		workflow.Tasks = append(workflow.Tasks, task.ID)
+	}
+	stateManager.AddWorkflow(workflow, tasks)
+
+	state := stateManager.GetState()
+	if queued := state.Queues[models.PriorityLow].Tasks(); len(queued) != 1 || queued[0] != preprocess {
+		t.Fatalf("Expected only the root task to be queued, got %d tasks", len(queued))
+	}
+	if train.Status != models.TaskStatusWaiting {
+		t.Errorf("Expected downstream task to wait, got %s", train.Status)
+	}
+
+	finishTask(engine, preprocess, models.TaskStatusSuccess)
+	if train.Status != models.TaskStatusPending {
+		t.Errorf("Expected train to be queued after preprocess succeeded, got %s", train.Status)
+	}
+
+	finishTask(engine, train, models.TaskStatusFailed)
+	if evaluate.Status != models.TaskStatusSkipped {
+		t.Errorf("Expected evaluate to be skipped after train failed, got %s", evaluate.Status)
+	}
+	if cleanup.Status != models.TaskStatusPending {
+		t.Errorf("Expected failure handler to be queued, got %s", cleanup.Status)
+	}
+	if notify.Status != models.TaskStatusPending {
+		t.Errorf("Expected always task to run after a skipped upstream, got %s", notify.Status)
+	}
+
+	summary, err := stateManager.GetWorkflowSummary("wf")
+	if err != nil {
+		t.Fatalf("Failed to get workflow summary: %v", err)
+	}
+	if summary.Status != models.WorkflowStatusRunning {
+		t.Errorf("Expected running workflow, got %s", summary.Status)
+	}
+
+	finishTask(engine, cleanup, models.TaskStatusSuccess)
+	finishTask(engine, notify, models.TaskStatusSuccess)
+
+	summary, _ = stateManager.GetWorkflowSummary("wf")
+	if summary.Status != models.WorkflowStatusSuccess {
+		t.Errorf("Expected handled failure to let the workflow succeed, got %s", summary.Status)
+	}
+	if summary.Counts[models.TaskStatusSkipped] != 1 || summary.Counts[models.TaskStatusFailed] != 1 {
+		t.Errorf("Unexpected task counts: %v", summary.Counts)
+	}
+}
+
+func TestWorkflowUnhandledFailure(t *testing.T) {
+	engine, stateManager := newTestEngine(t, nil)
+
+	first := newWorkflowTask("first")
+	second := newWorkflowTask("second", models.Dependency{TaskID: "first", Condition: models.DependOnSuccess})
+	stateManager.AddWorkflow(&models.Workflow{ID: "wf", Tasks: []string{"first", "second"}},
+		[]*models.Task{first, second})
+
+	finishTask(engine, first, models.TaskStatusFailed)
+
+	summary, _ := stateManager.GetWorkflowSummary("wf")
+	if summary.Status != models.WorkflowStatusFailed {
+		t.Errorf("Expected failed workflow, got %s", summary.Status)
+	}
+}
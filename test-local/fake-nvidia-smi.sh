@@ -10,6 +10,25 @@ if [[ "$1" == "--query-gpu=index,name,memory.total" ]]; then
 2, Tesla V100-SXM2-32GB, 32768
 3, Tesla V100-SXM2-32GB, 32768
 EOF
+elif [[ "$1" == "topo" && "$2" == "-m" ]]; then
+    # Two NVLink pairs (0-1, 2-3) bridged over PCIe/SMP
+    printf '\tGPU0\tGPU1\tGPU2\tGPU3\tCPU Affinity\tNUMA Affinity\n'
+    printf 'GPU0\t X \tNV2\tPHB\tSYS\t0-19\t0\n'
+    printf 'GPU1\tNV2\t X \tSYS\tPHB\t0-19\t0\n'
+    printf 'GPU2\tPHB\tSYS\t X \tNV2\t20-39\t1\n'
+    printf 'GPU3\tSYS\tPHB\tNV2\t X \t20-39\t1\n'
+    cat <<EOF
+
+Legend:
+
+  X    = Self
+  SYS  = Connection traversing PCIe as well as the SMP interconnect between NUMA nodes (e.g., QPI/UPI)
+  NODE = Connection traversing PCIe as well as the interconnect between PCIe Host Bridges within a NUMA node
+  PHB  = Connection traversing PCIe as well as a PCIe Host Bridge (typically the CPU)
+  PXB  = Connection traversing multiple PCIe bridges (without traversing the PCIe Host Bridge)
+  PIX  = Connection traversing at most a single PCIe bridge
+  NV#  = Connection traversing a bonded set of # NVLinks
+EOF
 else
     echo "NVIDIA-SMI 550.54.15    Driver Version: 550.54.15    CUDA Version: 12.4"
     echo ""